@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"log"
 	"log/slog"
 	"net/http"
@@ -8,11 +9,15 @@ import (
 
 	"github.com/WilliamTrojniak/TabAppBackend/cache"
 	"github.com/WilliamTrojniak/TabAppBackend/db"
+	"github.com/WilliamTrojniak/TabAppBackend/env"
 	"github.com/WilliamTrojniak/TabAppBackend/services"
 	"github.com/WilliamTrojniak/TabAppBackend/services/auth"
+	"github.com/WilliamTrojniak/TabAppBackend/services/email"
+	"github.com/WilliamTrojniak/TabAppBackend/services/scheduler"
 	"github.com/WilliamTrojniak/TabAppBackend/services/sessions"
 	"github.com/WilliamTrojniak/TabAppBackend/services/shop"
 	"github.com/WilliamTrojniak/TabAppBackend/services/user"
+	"github.com/WilliamTrojniak/TabAppBackend/util"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -44,7 +49,31 @@ func (s *APIServer) Run() error {
 		log.Fatal("Failed to initialize auth handler")
 	}
 
-	shopHandler := shop.NewHandler(s.store, sessionManager, userHandler, services.HandleHttpError, slog.Default())
+	emailConfig := &email.Config{
+		UIURI:        env.Envs.UI_URI,
+		From:         env.Envs.EMAIL_FROM,
+		Transport:    env.Envs.EMAIL_TRANSPORT,
+		SMTPHost:     env.Envs.SMTP_HOST,
+		SMTPPort:     env.Envs.SMTP_PORT,
+		SMTPUsername: env.Envs.SMTP_USERNAME,
+		SMTPPassword: env.Envs.SMTP_PASSWORD,
+		OutboxDir:    env.Envs.EMAIL_OUTBOX_DIR,
+	}
+	emailTransport, err := email.NewTransport(emailConfig, slog.Default())
+	if err != nil {
+		log.Fatal("Failed to initialize email transport: ", err)
+	}
+	clock := util.SystemClock{}
+	emailHandler, err := email.NewHandler(s.store, emailTransport, clock, emailConfig, slog.Default())
+	if err != nil {
+		log.Fatal("Failed to initialize email handler: ", err)
+	}
+	go emailHandler.Run(context.Background(), time.Second*30)
+
+	shopHandler := shop.NewHandler(s.store, sessionManager, userHandler, emailHandler, clock, services.HandleHttpError, slog.Default())
+
+	billingScheduler := scheduler.New(s.store, clock, time.Hour, slog.Default())
+	go billingScheduler.Run(context.Background())
 
 	router := http.NewServeMux()
 	v1 := http.NewServeMux()
@@ -30,7 +30,7 @@ func CORSMiddleware(next http.Handler) http.HandlerFunc {
 			"Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PATCH, PUT, OPTIONS, DELETE")
 		w.Header().Set("Access-Control-Allow-Credentials", "true")
-		w.Header().Set("Access-Control-Expose-Headers", "X-CSRF-Token")
+		w.Header().Set("Access-Control-Expose-Headers", "X-CSRF-Token, X-Next-Cursor")
 		if r.Method == "OPTIONS" {
 			return
 		}
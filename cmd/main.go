@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"log/slog"
+	_ "time/tzdata"
 
 	"github.com/WilliamTrojniak/TabAppBackend/cmd/api"
 	"github.com/WilliamTrojniak/TabAppBackend/db"
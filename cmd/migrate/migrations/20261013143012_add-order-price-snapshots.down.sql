@@ -0,0 +1,30 @@
+DROP VIEW IF EXISTS tab_bill_totals;
+
+ALTER TABLE order_variants
+  DROP CONSTRAINT order_variants_pkey,
+  DROP COLUMN item_unit_price,
+  DROP COLUMN name,
+  DROP COLUMN unit_price;
+
+CREATE TEMPORARY TABLE _merged_order_variants AS
+SELECT shop_id, tab_id, bill_id, item_id, variant_id, SUM(quantity)::INT AS quantity
+FROM order_variants
+GROUP BY shop_id, tab_id, bill_id, item_id, variant_id;
+
+DELETE FROM order_variants;
+INSERT INTO order_variants SELECT * FROM _merged_order_variants;
+ALTER TABLE order_variants ADD PRIMARY KEY(shop_id, tab_id, bill_id, item_id, variant_id);
+
+ALTER TABLE order_items
+  DROP CONSTRAINT order_items_pkey,
+  DROP COLUMN name,
+  DROP COLUMN unit_price;
+
+CREATE TEMPORARY TABLE _merged_order_items AS
+SELECT shop_id, tab_id, bill_id, item_id, SUM(quantity)::INT AS quantity
+FROM order_items
+GROUP BY shop_id, tab_id, bill_id, item_id;
+
+DELETE FROM order_items;
+INSERT INTO order_items SELECT * FROM _merged_order_items;
+ALTER TABLE order_items ADD PRIMARY KEY(shop_id, tab_id, bill_id, item_id);
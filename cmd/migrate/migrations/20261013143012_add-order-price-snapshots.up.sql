@@ -0,0 +1,49 @@
+ALTER TABLE order_items
+  ADD COLUMN name VARCHAR(255),
+  ADD COLUMN unit_price REAL;
+
+UPDATE order_items SET
+  name = items.name,
+  unit_price = items.base_price
+FROM items
+WHERE items.shop_id = order_items.shop_id AND items.id = order_items.item_id;
+
+ALTER TABLE order_items
+  ALTER COLUMN name SET NOT NULL,
+  ALTER COLUMN unit_price SET NOT NULL,
+  DROP CONSTRAINT order_items_pkey,
+  ADD PRIMARY KEY(shop_id, tab_id, bill_id, item_id, unit_price);
+
+ALTER TABLE order_variants
+  ADD COLUMN item_unit_price REAL,
+  ADD COLUMN name VARCHAR(255),
+  ADD COLUMN unit_price REAL;
+
+UPDATE order_variants SET
+  item_unit_price = items.base_price,
+  name = item_variants.name,
+  unit_price = item_variants.price
+FROM items, item_variants
+WHERE items.shop_id = order_variants.shop_id AND items.id = order_variants.item_id
+  AND item_variants.shop_id = order_variants.shop_id AND item_variants.item_id = order_variants.item_id
+  AND item_variants.id = order_variants.variant_id;
+
+ALTER TABLE order_variants
+  ALTER COLUMN item_unit_price SET NOT NULL,
+  ALTER COLUMN name SET NOT NULL,
+  ALTER COLUMN unit_price SET NOT NULL,
+  DROP CONSTRAINT order_variants_pkey,
+  ADD PRIMARY KEY(shop_id, tab_id, bill_id, item_id, item_unit_price, variant_id, unit_price);
+
+CREATE VIEW tab_bill_totals AS
+SELECT tab_bills.shop_id, tab_bills.tab_id, tab_bills.id AS bill_id,
+  (COALESCE(
+    (SELECT SUM(oi.quantity * oi.unit_price)
+     FROM order_items AS oi
+     WHERE oi.shop_id = tab_bills.shop_id AND oi.tab_id = tab_bills.tab_id AND oi.bill_id = tab_bills.id), 0)
+  + COALESCE(
+    (SELECT SUM(ov.quantity * ov.unit_price)
+     FROM order_variants AS ov
+     WHERE ov.shop_id = tab_bills.shop_id AND ov.tab_id = tab_bills.tab_id AND ov.bill_id = tab_bills.id), 0)
+  )::REAL AS total
+FROM tab_bills;
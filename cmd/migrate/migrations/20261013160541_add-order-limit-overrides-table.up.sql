@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS order_limit_overrides (
+  shop_id INT NOT NULL,
+  tab_id INT NOT NULL,
+  id SERIAL NOT NULL,
+  user_id VARCHAR(255) NOT NULL,
+  reason VARCHAR(255) NOT NULL,
+  dollar_limit REAL NOT NULL,
+  total REAL NOT NULL,
+  order_id INT,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+  PRIMARY KEY(shop_id, tab_id, id),
+  FOREIGN KEY(shop_id, tab_id) REFERENCES tabs(shop_id, id) ON DELETE CASCADE,
+  FOREIGN KEY(user_id) REFERENCES users(id)
+);
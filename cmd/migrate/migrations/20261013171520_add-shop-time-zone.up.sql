@@ -0,0 +1,2 @@
+ALTER TABLE shops
+  ADD COLUMN time_zone VARCHAR(64) NOT NULL DEFAULT 'UTC';
@@ -0,0 +1,15 @@
+ALTER TABLE order_items
+  ADD COLUMN location_id INT,
+  ADD FOREIGN KEY(shop_id, location_id) REFERENCES locations(shop_id, id),
+  DROP CONSTRAINT order_items_pkey;
+
+CREATE UNIQUE INDEX order_items_line_key
+  ON order_items (shop_id, tab_id, bill_id, item_id, unit_price, location_id);
+
+ALTER TABLE order_variants
+  ADD COLUMN location_id INT,
+  ADD FOREIGN KEY(shop_id, location_id) REFERENCES locations(shop_id, id),
+  DROP CONSTRAINT order_variants_pkey;
+
+CREATE UNIQUE INDEX order_variants_line_key
+  ON order_variants (shop_id, tab_id, bill_id, item_id, item_unit_price, location_id, variant_id, unit_price);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS voucher_redemptions;
+DROP TABLE IF EXISTS tab_vouchers;
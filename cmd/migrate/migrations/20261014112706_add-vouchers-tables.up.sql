@@ -0,0 +1,37 @@
+CREATE TABLE IF NOT EXISTS tab_vouchers (
+  shop_id INT NOT NULL,
+  tab_id INT NOT NULL,
+  id SERIAL NOT NULL,
+  code VARCHAR(32) NOT NULL,
+  max_uses INT NOT NULL,
+  uses INT NOT NULL DEFAULT 0,
+  expires_on DATE,
+  created_by VARCHAR(255) NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+  PRIMARY KEY(shop_id, tab_id, id),
+  FOREIGN KEY(shop_id, tab_id) REFERENCES tabs(shop_id, id) ON DELETE CASCADE,
+  FOREIGN KEY(created_by) REFERENCES users(id),
+  UNIQUE(shop_id, code),
+  CHECK ( max_uses >= 1 ),
+  CHECK ( uses >= 0 AND uses <= max_uses )
+);
+
+CREATE TABLE IF NOT EXISTS voucher_redemptions (
+  shop_id INT NOT NULL,
+  tab_id INT NOT NULL,
+  voucher_id INT NOT NULL,
+  id SERIAL NOT NULL,
+  bill_id INT NOT NULL,
+  location_id INT NOT NULL,
+  order_id INT,
+  redeemed_by VARCHAR(255) NOT NULL,
+  redeemed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  released_at TIMESTAMPTZ,
+
+  PRIMARY KEY(shop_id, tab_id, voucher_id, id),
+  FOREIGN KEY(shop_id, tab_id, voucher_id) REFERENCES tab_vouchers(shop_id, tab_id, id) ON DELETE CASCADE,
+  FOREIGN KEY(shop_id, tab_id, bill_id) REFERENCES tab_bills(shop_id, tab_id, id),
+  FOREIGN KEY(shop_id, location_id) REFERENCES locations(shop_id, id),
+  FOREIGN KEY(redeemed_by) REFERENCES users(id)
+);
@@ -0,0 +1,27 @@
+DROP INDEX IF EXISTS order_variants_line_key;
+
+ALTER TABLE order_variants DROP COLUMN customer;
+
+CREATE TEMPORARY TABLE _merged_order_variants AS
+SELECT shop_id, tab_id, bill_id, item_id, variant_id, SUM(quantity)::INT AS quantity, item_unit_price, name, unit_price, location_id
+FROM order_variants
+GROUP BY shop_id, tab_id, bill_id, item_id, variant_id, item_unit_price, name, unit_price, location_id;
+
+DELETE FROM order_variants;
+INSERT INTO order_variants SELECT * FROM _merged_order_variants;
+CREATE UNIQUE INDEX order_variants_line_key
+  ON order_variants (shop_id, tab_id, bill_id, item_id, item_unit_price, location_id, variant_id, unit_price);
+
+DROP INDEX IF EXISTS order_items_line_key;
+
+ALTER TABLE order_items DROP COLUMN customer;
+
+CREATE TEMPORARY TABLE _merged_order_items AS
+SELECT shop_id, tab_id, bill_id, item_id, SUM(quantity)::INT AS quantity, name, unit_price, location_id
+FROM order_items
+GROUP BY shop_id, tab_id, bill_id, item_id, name, unit_price, location_id;
+
+DELETE FROM order_items;
+INSERT INTO order_items SELECT * FROM _merged_order_items;
+CREATE UNIQUE INDEX order_items_line_key
+  ON order_items (shop_id, tab_id, bill_id, item_id, unit_price, location_id);
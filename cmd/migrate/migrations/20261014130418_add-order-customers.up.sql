@@ -0,0 +1,11 @@
+ALTER TABLE order_items ADD COLUMN customer VARCHAR(255) NOT NULL DEFAULT '';
+
+DROP INDEX order_items_line_key;
+CREATE UNIQUE INDEX order_items_line_key
+  ON order_items (shop_id, tab_id, bill_id, item_id, unit_price, location_id, customer);
+
+ALTER TABLE order_variants ADD COLUMN customer VARCHAR(255) NOT NULL DEFAULT '';
+
+DROP INDEX order_variants_line_key;
+CREATE UNIQUE INDEX order_variants_line_key
+  ON order_variants (shop_id, tab_id, bill_id, item_id, item_unit_price, location_id, customer, variant_id, unit_price);
@@ -0,0 +1,85 @@
+ALTER TABLE order_limit_overrides
+  DROP CONSTRAINT IF EXISTS order_limit_overrides_order_key,
+  DROP CONSTRAINT IF EXISTS order_limit_overrides_order_fkey;
+
+ALTER TABLE voucher_redemptions
+  DROP CONSTRAINT IF EXISTS voucher_redemptions_order_key,
+  DROP CONSTRAINT IF EXISTS voucher_redemptions_order_fkey;
+
+DROP VIEW IF EXISTS tab_bill_totals;
+DROP VIEW IF EXISTS order_totals;
+
+CREATE TEMPORARY TABLE _merged_order_items AS
+SELECT shop_id, tab_id, bill_id, item_id, SUM(quantity)::INT AS quantity, name, unit_price, location_id, customer
+FROM bill_order_items
+GROUP BY shop_id, tab_id, bill_id, item_id, name, unit_price, location_id, customer;
+
+CREATE TEMPORARY TABLE _merged_order_variants AS
+SELECT shop_id, tab_id, bill_id, item_id, variant_id, SUM(quantity)::INT AS quantity, item_unit_price, name, unit_price, location_id, customer
+FROM bill_order_variants
+GROUP BY shop_id, tab_id, bill_id, item_id, variant_id, item_unit_price, name, unit_price, location_id, customer;
+
+DROP VIEW IF EXISTS bill_order_variants;
+DROP VIEW IF EXISTS bill_order_items;
+
+DROP TABLE order_variants;
+DROP TABLE order_items;
+DROP TABLE orders;
+
+CREATE TABLE IF NOT EXISTS order_items (
+  shop_id INT NOT NULL,
+  tab_id INT NOT NULL,
+  bill_id INT NOT NULL,
+  item_id INT NOT NULL,
+  quantity INT NOT NULL DEFAULT 0,
+  name VARCHAR(255) NOT NULL,
+  unit_price REAL NOT NULL,
+  location_id INT,
+  customer VARCHAR(255) NOT NULL DEFAULT '',
+
+  FOREIGN KEY(shop_id, tab_id, bill_id) REFERENCES tab_bills(shop_id, tab_id, id),
+  FOREIGN KEY(shop_id, item_id) REFERENCES items(shop_id, id),
+  FOREIGN KEY(shop_id, location_id) REFERENCES locations(shop_id, id),
+  CHECK ( quantity >= 0 )
+);
+
+CREATE UNIQUE INDEX order_items_line_key
+  ON order_items (shop_id, tab_id, bill_id, item_id, unit_price, location_id, customer);
+
+CREATE TABLE IF NOT EXISTS order_variants (
+  shop_id INT NOT NULL,
+  tab_id INT NOT NULL,
+  bill_id INT NOT NULL,
+  item_id INT NOT NULL,
+  variant_id INT NOT NULL,
+  quantity INT NOT NULL DEFAULT 0,
+  item_unit_price REAL NOT NULL,
+  name VARCHAR(255) NOT NULL,
+  unit_price REAL NOT NULL,
+  location_id INT,
+  customer VARCHAR(255) NOT NULL DEFAULT '',
+
+  FOREIGN KEY(shop_id, tab_id, bill_id) REFERENCES tab_bills(shop_id, tab_id, id),
+  FOREIGN KEY(shop_id, item_id, variant_id) REFERENCES item_variants(shop_id, item_id, id),
+  FOREIGN KEY(shop_id, location_id) REFERENCES locations(shop_id, id),
+  CHECK ( quantity >= 0 )
+);
+
+CREATE UNIQUE INDEX order_variants_line_key
+  ON order_variants (shop_id, tab_id, bill_id, item_id, item_unit_price, location_id, customer, variant_id, unit_price);
+
+INSERT INTO order_items SELECT * FROM _merged_order_items WHERE quantity > 0;
+INSERT INTO order_variants SELECT * FROM _merged_order_variants WHERE quantity > 0;
+
+CREATE VIEW tab_bill_totals AS
+SELECT tab_bills.shop_id, tab_bills.tab_id, tab_bills.id AS bill_id,
+  (COALESCE(
+    (SELECT SUM(oi.quantity * oi.unit_price)
+     FROM order_items AS oi
+     WHERE oi.shop_id = tab_bills.shop_id AND oi.tab_id = tab_bills.tab_id AND oi.bill_id = tab_bills.id), 0)
+  + COALESCE(
+    (SELECT SUM(ov.quantity * ov.unit_price)
+     FROM order_variants AS ov
+     WHERE ov.shop_id = tab_bills.shop_id AND ov.tab_id = tab_bills.tab_id AND ov.bill_id = tab_bills.id), 0)
+  )::REAL AS total
+FROM tab_bills;
@@ -0,0 +1,137 @@
+CREATE TABLE IF NOT EXISTS orders (
+  shop_id INT NOT NULL,
+  tab_id INT NOT NULL,
+  id SERIAL NOT NULL,
+  bill_id INT NOT NULL,
+  location_id INT,
+  customer VARCHAR(255) NOT NULL DEFAULT '',
+  user_id VARCHAR(255),
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+  PRIMARY KEY(shop_id, tab_id, id),
+  FOREIGN KEY(shop_id, tab_id, bill_id) REFERENCES tab_bills(shop_id, tab_id, id),
+  FOREIGN KEY(shop_id, location_id) REFERENCES locations(shop_id, id),
+  FOREIGN KEY(user_id) REFERENCES users(id)
+);
+
+CREATE INDEX orders_bill_idx ON orders (shop_id, tab_id, bill_id);
+
+-- Redemptions made before orders were recorded against them have no order
+ALTER TABLE voucher_redemptions
+  ADD CONSTRAINT voucher_redemptions_order_fkey FOREIGN KEY(shop_id, tab_id, order_id) REFERENCES orders(shop_id, tab_id, id),
+  ADD CONSTRAINT voucher_redemptions_order_key UNIQUE(shop_id, tab_id, order_id);
+
+-- Overrides recorded before they were linked to the order they allowed have no order
+ALTER TABLE order_limit_overrides
+  ADD CONSTRAINT order_limit_overrides_order_fkey FOREIGN KEY(shop_id, tab_id, order_id) REFERENCES orders(shop_id, tab_id, id),
+  ADD CONSTRAINT order_limit_overrides_order_key UNIQUE(shop_id, tab_id, order_id);
+
+-- Aggregated quantities recorded before the ledger become one order per bill, location and customer
+INSERT INTO orders (shop_id, tab_id, bill_id, location_id, customer, created_at)
+SELECT lines.shop_id, lines.tab_id, lines.bill_id, lines.location_id, lines.customer, tab_bills.start_date
+FROM (SELECT shop_id, tab_id, bill_id, location_id, customer FROM order_items
+      UNION
+      SELECT shop_id, tab_id, bill_id, location_id, customer FROM order_variants) AS lines
+JOIN tab_bills ON tab_bills.shop_id = lines.shop_id AND tab_bills.tab_id = lines.tab_id AND tab_bills.id = lines.bill_id
+ORDER BY lines.shop_id, lines.tab_id, lines.bill_id;
+
+DROP VIEW IF EXISTS tab_bill_totals;
+
+ALTER TABLE order_items
+  ADD COLUMN order_id INT,
+  ADD COLUMN line_no INT;
+
+UPDATE order_items SET order_id = orders.id
+FROM orders
+WHERE orders.shop_id = order_items.shop_id AND orders.tab_id = order_items.tab_id AND orders.bill_id = order_items.bill_id
+  AND orders.location_id IS NOT DISTINCT FROM order_items.location_id AND orders.customer = order_items.customer;
+
+-- Each line of an order is numbered so that an order may list the same item more than once,
+-- and variants belong to the line they were ordered on rather than to every line of the item
+UPDATE order_items SET line_no = lines.line_no
+FROM (SELECT shop_id, tab_id, order_id, item_id, unit_price,
+        ROW_NUMBER() OVER (PARTITION BY shop_id, tab_id, order_id ORDER BY item_id, unit_price)::INT AS line_no
+      FROM order_items) AS lines
+WHERE lines.shop_id = order_items.shop_id AND lines.tab_id = order_items.tab_id AND lines.order_id = order_items.order_id
+  AND lines.item_id = order_items.item_id AND lines.unit_price = order_items.unit_price;
+
+DROP INDEX order_items_line_key;
+
+ALTER TABLE order_items
+  DROP CONSTRAINT order_items_quantity_check,
+  DROP COLUMN bill_id,
+  DROP COLUMN location_id,
+  DROP COLUMN customer,
+  ALTER COLUMN order_id SET NOT NULL,
+  ALTER COLUMN line_no SET NOT NULL,
+  ADD PRIMARY KEY(shop_id, tab_id, order_id, line_no),
+  ADD FOREIGN KEY(shop_id, tab_id, order_id) REFERENCES orders(shop_id, tab_id, id);
+
+ALTER TABLE order_variants
+  ADD COLUMN order_id INT,
+  ADD COLUMN line_no INT;
+
+UPDATE order_variants SET order_id = orders.id
+FROM orders
+WHERE orders.shop_id = order_variants.shop_id AND orders.tab_id = order_variants.tab_id AND orders.bill_id = order_variants.bill_id
+  AND orders.location_id IS NOT DISTINCT FROM order_variants.location_id AND orders.customer = order_variants.customer;
+
+UPDATE order_variants SET line_no = oi.line_no
+FROM order_items AS oi
+WHERE oi.shop_id = order_variants.shop_id AND oi.tab_id = order_variants.tab_id AND oi.order_id = order_variants.order_id
+  AND oi.item_id = order_variants.item_id AND oi.unit_price = order_variants.item_unit_price;
+
+DROP INDEX order_variants_line_key;
+
+ALTER TABLE order_variants
+  DROP CONSTRAINT order_variants_quantity_check,
+  DROP COLUMN bill_id,
+  DROP COLUMN location_id,
+  DROP COLUMN customer,
+  DROP COLUMN item_unit_price,
+  ALTER COLUMN order_id SET NOT NULL,
+  ALTER COLUMN line_no SET NOT NULL,
+  ADD PRIMARY KEY(shop_id, tab_id, order_id, line_no, variant_id, unit_price),
+  ADD FOREIGN KEY(shop_id, tab_id, order_id) REFERENCES orders(shop_id, tab_id, id),
+  ADD CONSTRAINT order_variants_line_fkey FOREIGN KEY(shop_id, tab_id, order_id, line_no)
+    REFERENCES order_items(shop_id, tab_id, order_id, line_no);
+
+-- Running quantities per bill, derived from the ledger
+CREATE VIEW bill_order_items AS
+SELECT orders.shop_id, orders.tab_id, orders.bill_id, oi.item_id, oi.name, oi.unit_price,
+  orders.location_id, orders.customer, SUM(oi.quantity)::INT AS quantity
+FROM orders
+JOIN order_items AS oi ON oi.shop_id = orders.shop_id AND oi.tab_id = orders.tab_id AND oi.order_id = orders.id
+GROUP BY orders.shop_id, orders.tab_id, orders.bill_id, oi.item_id, oi.name, oi.unit_price, orders.location_id, orders.customer;
+
+CREATE VIEW bill_order_variants AS
+SELECT orders.shop_id, orders.tab_id, orders.bill_id, oi.item_id, oi.unit_price AS item_unit_price, ov.variant_id, ov.name, ov.unit_price,
+  orders.location_id, orders.customer, SUM(ov.quantity)::INT AS quantity
+FROM orders
+JOIN order_variants AS ov ON ov.shop_id = orders.shop_id AND ov.tab_id = orders.tab_id AND ov.order_id = orders.id
+JOIN order_items AS oi ON oi.shop_id = ov.shop_id AND oi.tab_id = ov.tab_id AND oi.order_id = ov.order_id AND oi.line_no = ov.line_no
+GROUP BY orders.shop_id, orders.tab_id, orders.bill_id, oi.item_id, oi.unit_price, ov.variant_id, ov.name, ov.unit_price,
+  orders.location_id, orders.customer;
+
+CREATE VIEW order_totals AS
+SELECT orders.shop_id, orders.tab_id, orders.id AS order_id,
+  (COALESCE(
+    (SELECT SUM(oi.quantity * oi.unit_price)
+     FROM order_items AS oi
+     WHERE oi.shop_id = orders.shop_id AND oi.tab_id = orders.tab_id AND oi.order_id = orders.id), 0)
+  + COALESCE(
+    (SELECT SUM(ov.quantity * ov.unit_price)
+     FROM order_variants AS ov
+     WHERE ov.shop_id = orders.shop_id AND ov.tab_id = orders.tab_id AND ov.order_id = orders.id), 0)
+  )::REAL AS total
+FROM orders;
+
+CREATE VIEW tab_bill_totals AS
+SELECT tab_bills.shop_id, tab_bills.tab_id, tab_bills.id AS bill_id,
+  COALESCE(
+    (SELECT SUM(t.total)
+     FROM orders
+     JOIN order_totals AS t ON t.shop_id = orders.shop_id AND t.tab_id = orders.tab_id AND t.order_id = orders.id
+     WHERE orders.shop_id = tab_bills.shop_id AND orders.tab_id = tab_bills.tab_id AND orders.bill_id = tab_bills.id), 0
+  )::REAL AS total
+FROM tab_bills;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS orders_voids_order_key;
+
+ALTER TABLE orders
+  DROP COLUMN void_reason,
+  DROP COLUMN voids_order_id;
@@ -0,0 +1,8 @@
+ALTER TABLE orders
+  ADD COLUMN voids_order_id INT,
+  ADD COLUMN void_reason VARCHAR(255),
+  ADD FOREIGN KEY(shop_id, tab_id, voids_order_id) REFERENCES orders(shop_id, tab_id, id),
+  ADD CHECK ( (voids_order_id IS NULL) = (void_reason IS NULL) );
+
+-- An order can only be voided once
+CREATE UNIQUE INDEX orders_voids_order_key ON orders (shop_id, tab_id, voids_order_id);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS tab_update_rejections;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS tab_update_rejections (
+  shop_id INT NOT NULL,
+  tab_id INT NOT NULL,
+  id SERIAL NOT NULL,
+  message VARCHAR(1024),
+  rejected_by VARCHAR(255) NOT NULL,
+  rejected_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+  PRIMARY KEY(shop_id, tab_id, id),
+  FOREIGN KEY(shop_id, tab_id) REFERENCES tabs(shop_id, id) ON DELETE CASCADE,
+  FOREIGN KEY(rejected_by) REFERENCES users(id)
+);
@@ -0,0 +1,12 @@
+ALTER TABLE tab_bills DROP COLUMN is_final;
+
+-- Enum values cannot be dropped, so the type is recreated without them
+ALTER TABLE tabs ALTER COLUMN status DROP DEFAULT;
+ALTER TABLE tabs ALTER COLUMN status TYPE VARCHAR(16);
+UPDATE tabs SET status = 'closed' WHERE status IN ('expired', 'rejected');
+
+DROP TYPE tab_status;
+CREATE TYPE tab_status AS ENUM ('pending', 'confirmed', 'closed');
+
+ALTER TABLE tabs ALTER COLUMN status TYPE tab_status USING status::tab_status;
+ALTER TABLE tabs ALTER COLUMN status SET DEFAULT 'pending';
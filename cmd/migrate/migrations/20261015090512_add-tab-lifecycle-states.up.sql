@@ -0,0 +1,4 @@
+ALTER TYPE tab_status ADD VALUE IF NOT EXISTS 'expired';
+ALTER TYPE tab_status ADD VALUE IF NOT EXISTS 'rejected';
+
+ALTER TABLE tab_bills ADD COLUMN is_final BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,17 @@
+ALTER TABLE tab_bills DROP COLUMN is_paid;
+ALTER TABLE tab_bills ADD COLUMN is_paid BOOLEAN NOT NULL DEFAULT FALSE;
+UPDATE tab_bills SET is_paid = TRUE WHERE status = 'paid';
+
+ALTER TABLE tab_bills
+  DROP COLUMN void_reason,
+  DROP COLUMN voided_by,
+  DROP COLUMN voided_at,
+  DROP COLUMN paid_by,
+  DROP COLUMN paid_at,
+  DROP COLUMN issued_by,
+  DROP COLUMN issued_at,
+  DROP COLUMN invoice_number,
+  DROP COLUMN status;
+
+DROP TABLE IF EXISTS shop_invoice_sequences;
+DROP TYPE IF EXISTS bill_status;
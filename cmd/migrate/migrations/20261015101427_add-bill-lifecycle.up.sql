@@ -0,0 +1,48 @@
+CREATE TYPE bill_status AS ENUM ('open', 'issued', 'paid', 'void');
+
+CREATE TABLE IF NOT EXISTS shop_invoice_sequences (
+  shop_id INT NOT NULL,
+  last_number INT NOT NULL DEFAULT 0,
+
+  PRIMARY KEY(shop_id),
+  FOREIGN KEY(shop_id) REFERENCES shops(id) ON DELETE CASCADE
+);
+
+ALTER TABLE tab_bills
+  ADD COLUMN status bill_status NOT NULL DEFAULT 'open',
+  ADD COLUMN invoice_number INT,
+  ADD COLUMN issued_at TIMESTAMPTZ,
+  ADD COLUMN issued_by VARCHAR(255),
+  ADD COLUMN paid_at TIMESTAMPTZ,
+  ADD COLUMN paid_by VARCHAR(255),
+  ADD COLUMN voided_at TIMESTAMPTZ,
+  ADD COLUMN voided_by VARCHAR(255),
+  ADD COLUMN void_reason VARCHAR(255),
+  ADD FOREIGN KEY(issued_by) REFERENCES users(id),
+  ADD FOREIGN KEY(paid_by) REFERENCES users(id),
+  ADD FOREIGN KEY(voided_by) REFERENCES users(id),
+  ADD UNIQUE(shop_id, invoice_number);
+
+-- Bills already marked paid are treated as issued and paid at the end of their period
+UPDATE tab_bills SET
+  status = 'paid',
+  issued_at = end_date,
+  paid_at = end_date
+WHERE is_paid;
+
+WITH numbered AS (
+  SELECT shop_id, tab_id, id, ROW_NUMBER() OVER (PARTITION BY shop_id ORDER BY end_date, tab_id, id) AS invoice_number
+  FROM tab_bills
+  WHERE status = 'paid'
+)
+UPDATE tab_bills SET invoice_number = numbered.invoice_number
+FROM numbered
+WHERE numbered.shop_id = tab_bills.shop_id AND numbered.tab_id = tab_bills.tab_id AND numbered.id = tab_bills.id;
+
+INSERT INTO shop_invoice_sequences (shop_id, last_number)
+SELECT shop_id, MAX(invoice_number) FROM tab_bills WHERE invoice_number IS NOT NULL GROUP BY shop_id;
+
+ALTER TABLE tab_bills
+  DROP COLUMN is_paid,
+  ADD COLUMN is_paid BOOLEAN GENERATED ALWAYS AS (status = 'paid') STORED,
+  ADD CHECK ( (status = 'open') = (invoice_number IS NULL) OR status = 'void' );
@@ -0,0 +1,2 @@
+DROP VIEW IF EXISTS tab_bill_balances;
+DROP TABLE IF EXISTS bill_payments;
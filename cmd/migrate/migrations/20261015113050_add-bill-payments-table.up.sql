@@ -0,0 +1,33 @@
+CREATE TABLE IF NOT EXISTS bill_payments (
+  shop_id INT NOT NULL,
+  tab_id INT NOT NULL,
+  bill_id INT NOT NULL,
+  id SERIAL NOT NULL,
+  amount REAL NOT NULL,
+  method payment_method NOT NULL,
+  reference VARCHAR(255),
+  paid_on DATE NOT NULL,
+  recorded_by VARCHAR(255) NOT NULL,
+  recorded_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+  PRIMARY KEY(shop_id, tab_id, bill_id, id),
+  FOREIGN KEY(shop_id, tab_id, bill_id) REFERENCES tab_bills(shop_id, tab_id, id) ON DELETE CASCADE,
+  FOREIGN KEY(recorded_by) REFERENCES users(id),
+  CHECK ( amount > 0 )
+);
+
+-- Paid and void bills owe nothing, including those marked paid before payments were recorded
+CREATE VIEW tab_bill_balances AS
+SELECT tab_bills.shop_id, tab_bills.tab_id, tab_bills.id AS bill_id, t.total,
+  p.amount_paid,
+  (CASE WHEN tab_bills.status IN ('paid', 'void') THEN 0
+        ELSE GREATEST(ROUND((t.total - p.amount_paid)::NUMERIC, 2), 0)
+   END)::REAL AS outstanding
+FROM tab_bills
+JOIN tab_bill_totals AS t ON t.shop_id = tab_bills.shop_id AND t.tab_id = tab_bills.tab_id AND t.bill_id = tab_bills.id
+CROSS JOIN LATERAL (
+  SELECT COALESCE(SUM(bill_payments.amount), 0)::REAL AS amount_paid
+  FROM bill_payments
+  WHERE bill_payments.shop_id = tab_bills.shop_id AND bill_payments.tab_id = tab_bills.tab_id
+    AND bill_payments.bill_id = tab_bills.id
+) AS p;
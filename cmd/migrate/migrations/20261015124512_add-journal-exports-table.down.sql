@@ -0,0 +1,5 @@
+ALTER TABLE tab_bills
+  DROP COLUMN payment_details,
+  DROP COLUMN payment_method,
+  DROP COLUMN journal_export_id;
+DROP TABLE IF EXISTS journal_exports;
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS journal_exports (
+  shop_id INT NOT NULL,
+  id SERIAL NOT NULL,
+  start_date DATE NOT NULL,
+  end_date DATE NOT NULL,
+  exported_by VARCHAR(255) NOT NULL,
+  exported_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+  PRIMARY KEY(shop_id, id),
+  FOREIGN KEY(shop_id) REFERENCES shops(id) ON DELETE CASCADE,
+  FOREIGN KEY(exported_by) REFERENCES users(id),
+  CHECK ( end_date >= start_date )
+);
+
+-- The payment method and details of the tab are saved when a bill is issued, so that exports of the bill
+-- are charged as invoiced even if the tab's payment later changes
+ALTER TABLE tab_bills
+  ADD COLUMN journal_export_id INT,
+  ADD COLUMN payment_method payment_method,
+  ADD COLUMN payment_details VARCHAR(255),
+  ADD FOREIGN KEY(shop_id, journal_export_id) REFERENCES journal_exports(shop_id, id);
+
+UPDATE tab_bills SET payment_method = tabs.payment_method, payment_details = tabs.payment_details
+FROM tabs
+WHERE tabs.shop_id = tab_bills.shop_id AND tabs.id = tab_bills.tab_id
+  AND tab_bills.invoice_number IS NOT NULL;
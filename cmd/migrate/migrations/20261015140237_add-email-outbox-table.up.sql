@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS email_outbox (
+  id SERIAL NOT NULL,
+  recipient VARCHAR(255) NOT NULL,
+  template VARCHAR(64) NOT NULL,
+  subject VARCHAR(255) NOT NULL,
+  text_body TEXT NOT NULL,
+  html_body TEXT NOT NULL,
+  attempts INT NOT NULL DEFAULT 0,
+  next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  last_error TEXT,
+  sent_at TIMESTAMPTZ,
+  failed_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+  PRIMARY KEY(id)
+);
+
+CREATE INDEX IF NOT EXISTS email_outbox_pending_idx ON email_outbox (next_attempt_at)
+WHERE sent_at IS NULL AND failed_at IS NULL;
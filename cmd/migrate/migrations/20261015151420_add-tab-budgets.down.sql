@@ -0,0 +1,9 @@
+DROP VIEW IF EXISTS tab_spend;
+
+ALTER TABLE tab_updates
+  DROP COLUMN IF EXISTS budget_warning_thresholds,
+  DROP COLUMN IF EXISTS total_budget;
+
+ALTER TABLE tabs
+  DROP COLUMN IF EXISTS budget_warning_thresholds,
+  DROP COLUMN IF EXISTS total_budget;
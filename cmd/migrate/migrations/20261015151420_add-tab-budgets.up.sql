@@ -0,0 +1,19 @@
+-- A budget of zero means that the tab's total spend is not limited
+ALTER TABLE tabs
+  ADD COLUMN total_budget REAL NOT NULL DEFAULT 0,
+  ADD COLUMN budget_warning_thresholds INT[] NOT NULL DEFAULT '{}',
+  ADD CONSTRAINT tabs_total_budget_check CHECK ( total_budget >= 0 );
+
+ALTER TABLE tab_updates
+  ADD COLUMN total_budget REAL NOT NULL DEFAULT 0,
+  ADD COLUMN budget_warning_thresholds INT[] NOT NULL DEFAULT '{}',
+  ADD CONSTRAINT tab_updates_total_budget_check CHECK ( total_budget >= 0 );
+
+-- Spend counts every order charged to the tab, less voids, except on bills which were voided
+CREATE VIEW tab_spend AS
+SELECT tabs.shop_id, tabs.id AS tab_id,
+  ROUND(COALESCE(SUM(t.total), 0)::NUMERIC, 2)::REAL AS spent
+FROM tabs
+LEFT JOIN tab_bills ON tab_bills.shop_id = tabs.shop_id AND tab_bills.tab_id = tabs.id AND tab_bills.status <> 'void'
+LEFT JOIN tab_bill_totals AS t ON t.shop_id = tab_bills.shop_id AND t.tab_id = tab_bills.tab_id AND t.bill_id = tab_bills.id
+GROUP BY tabs.shop_id, tabs.id;
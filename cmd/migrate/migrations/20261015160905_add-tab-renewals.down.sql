@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS tabs_renewed_from_tab_idx;
+
+ALTER TABLE tabs
+  DROP CONSTRAINT IF EXISTS tabs_renewed_from_tab_fkey,
+  DROP COLUMN IF EXISTS renewed_from_tab_id;
@@ -0,0 +1,5 @@
+ALTER TABLE tabs
+  ADD COLUMN renewed_from_tab_id INT,
+  ADD CONSTRAINT tabs_renewed_from_tab_fkey FOREIGN KEY(shop_id, renewed_from_tab_id) REFERENCES tabs(shop_id, id);
+
+CREATE INDEX IF NOT EXISTS tabs_renewed_from_tab_idx ON tabs (shop_id, renewed_from_tab_id);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS tab_ownership_transfers;
+DROP TABLE IF EXISTS tab_managers;
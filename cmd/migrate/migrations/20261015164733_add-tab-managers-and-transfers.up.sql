@@ -0,0 +1,27 @@
+-- Managers may edit a tab's verification list and view its bills, alongside the owner
+CREATE TABLE IF NOT EXISTS tab_managers (
+  shop_id INT NOT NULL,
+  tab_id INT NOT NULL,
+  email VARCHAR(255) NOT NULL,
+  added_by VARCHAR(255) NOT NULL,
+  added_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+  PRIMARY KEY(shop_id, tab_id, email),
+  FOREIGN KEY(shop_id, tab_id) REFERENCES tabs(shop_id, id) ON DELETE CASCADE,
+  FOREIGN KEY(added_by) REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS tab_managers_email_idx ON tab_managers (lower(email));
+
+-- A tab has at most one pending transfer, which takes effect once the recipient accepts it
+CREATE TABLE IF NOT EXISTS tab_ownership_transfers (
+  shop_id INT NOT NULL,
+  tab_id INT NOT NULL,
+  email VARCHAR(255) NOT NULL,
+  requested_by VARCHAR(255) NOT NULL,
+  requested_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+  PRIMARY KEY(shop_id, tab_id),
+  FOREIGN KEY(shop_id, tab_id) REFERENCES tabs(shop_id, id) ON DELETE CASCADE,
+  FOREIGN KEY(requested_by) REFERENCES users(id)
+);
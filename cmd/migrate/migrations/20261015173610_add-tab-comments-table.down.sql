@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS tab_comments;
+DROP TYPE IF EXISTS tab_comment_kind;
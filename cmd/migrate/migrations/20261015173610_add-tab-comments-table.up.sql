@@ -0,0 +1,20 @@
+CREATE TYPE tab_comment_kind AS ENUM ('comment', 'created', 'update_requested', 'approved', 'closed', 'bill_paid', 'expired', 'rejected');
+
+-- Comments are written by the tab owner or shop staff, while every other kind is recorded
+-- automatically when the event happens. Events without an author were made by the system.
+CREATE TABLE IF NOT EXISTS tab_comments (
+  shop_id INT NOT NULL,
+  tab_id INT NOT NULL,
+  id SERIAL NOT NULL,
+  kind tab_comment_kind NOT NULL DEFAULT 'comment',
+  author_id VARCHAR(255),
+  body VARCHAR(2048),
+  bill_id INT,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+  PRIMARY KEY(shop_id, tab_id, id),
+  FOREIGN KEY(shop_id, tab_id) REFERENCES tabs(shop_id, id) ON DELETE CASCADE,
+  FOREIGN KEY(shop_id, tab_id, bill_id) REFERENCES tab_bills(shop_id, tab_id, id) ON DELETE CASCADE,
+  FOREIGN KEY(author_id) REFERENCES users(id),
+  CHECK (kind <> 'comment' OR (author_id IS NOT NULL AND body IS NOT NULL))
+);
@@ -0,0 +1,23 @@
+DROP VIEW IF EXISTS bill_order_adjustments;
+
+CREATE OR REPLACE VIEW order_totals AS
+SELECT orders.shop_id, orders.tab_id, orders.id AS order_id,
+  (COALESCE(
+    (SELECT SUM(oi.quantity * oi.unit_price)
+     FROM order_items AS oi
+     WHERE oi.shop_id = orders.shop_id AND oi.tab_id = orders.tab_id AND oi.order_id = orders.id), 0)
+  + COALESCE(
+    (SELECT SUM(ov.quantity * ov.unit_price)
+     FROM order_variants AS ov
+     WHERE ov.shop_id = orders.shop_id AND ov.tab_id = orders.tab_id AND ov.order_id = orders.id), 0)
+  )::REAL AS total
+FROM orders;
+
+DROP TABLE IF EXISTS order_adjustments;
+DROP TYPE IF EXISTS order_adjustment_kind;
+DROP TABLE IF EXISTS tab_category_discounts;
+DROP TABLE IF EXISTS tab_price_overrides;
+
+ALTER TABLE tabs
+  DROP CONSTRAINT IF EXISTS tabs_discount_percent_check,
+  DROP COLUMN IF EXISTS discount_percent;
@@ -0,0 +1,75 @@
+-- A tab's pricing rules are applied when its orders are priced. Price overrides take precedence,
+-- and otherwise the greater of the tab's discount and the discounts for the item's categories applies.
+ALTER TABLE tabs
+  ADD COLUMN discount_percent REAL NOT NULL DEFAULT 0,
+  ADD CONSTRAINT tabs_discount_percent_check CHECK ( discount_percent >= 0 AND discount_percent <= 100 );
+
+-- An override without a variant sets the item's base price, and one with a variant sets the variant's price
+CREATE TABLE IF NOT EXISTS tab_price_overrides (
+  shop_id INT NOT NULL,
+  tab_id INT NOT NULL,
+  item_id INT NOT NULL,
+  variant_id INT,
+  price REAL NOT NULL,
+
+  FOREIGN KEY(shop_id, tab_id) REFERENCES tabs(shop_id, id) ON DELETE CASCADE,
+  FOREIGN KEY(shop_id, item_id) REFERENCES items(shop_id, id) ON DELETE CASCADE,
+  FOREIGN KEY(shop_id, item_id, variant_id) REFERENCES item_variants(shop_id, item_id, id) ON DELETE CASCADE,
+  CHECK ( price >= 0 )
+);
+
+CREATE UNIQUE INDEX tab_price_overrides_key ON tab_price_overrides (shop_id, tab_id, item_id, COALESCE(variant_id, 0));
+
+CREATE TABLE IF NOT EXISTS tab_category_discounts (
+  shop_id INT NOT NULL,
+  tab_id INT NOT NULL,
+  category_id INT NOT NULL,
+  discount_percent REAL NOT NULL,
+
+  PRIMARY KEY(shop_id, tab_id, category_id),
+  FOREIGN KEY(shop_id, tab_id) REFERENCES tabs(shop_id, id) ON DELETE CASCADE,
+  FOREIGN KEY(shop_id, category_id) REFERENCES item_categories(shop_id, id) ON DELETE CASCADE,
+  CHECK ( discount_percent > 0 AND discount_percent <= 100 )
+);
+
+CREATE TYPE order_adjustment_kind AS ENUM ('discount');
+
+-- Adjustments are charged alongside an order's lines, with discounts recorded as negative amounts
+CREATE TABLE IF NOT EXISTS order_adjustments (
+  shop_id INT NOT NULL,
+  tab_id INT NOT NULL,
+  order_id INT NOT NULL,
+  id SERIAL NOT NULL,
+  kind order_adjustment_kind NOT NULL,
+  description VARCHAR(255) NOT NULL,
+  item_id INT,
+  variant_id INT,
+  amount REAL NOT NULL,
+
+  PRIMARY KEY(shop_id, tab_id, order_id, id),
+  FOREIGN KEY(shop_id, tab_id, order_id) REFERENCES orders(shop_id, tab_id, id)
+);
+
+CREATE OR REPLACE VIEW order_totals AS
+SELECT orders.shop_id, orders.tab_id, orders.id AS order_id,
+  (COALESCE(
+    (SELECT SUM(oi.quantity * oi.unit_price)
+     FROM order_items AS oi
+     WHERE oi.shop_id = orders.shop_id AND oi.tab_id = orders.tab_id AND oi.order_id = orders.id), 0)
+  + COALESCE(
+    (SELECT SUM(ov.quantity * ov.unit_price)
+     FROM order_variants AS ov
+     WHERE ov.shop_id = orders.shop_id AND ov.tab_id = orders.tab_id AND ov.order_id = orders.id), 0)
+  + COALESCE(
+    (SELECT SUM(oa.amount)
+     FROM order_adjustments AS oa
+     WHERE oa.shop_id = orders.shop_id AND oa.tab_id = orders.tab_id AND oa.order_id = orders.id), 0)
+  )::REAL AS total
+FROM orders;
+
+-- Running adjustments per bill, derived from the ledger
+CREATE VIEW bill_order_adjustments AS
+SELECT orders.shop_id, orders.tab_id, orders.bill_id, oa.kind, oa.description, SUM(oa.amount)::REAL AS amount
+FROM orders
+JOIN order_adjustments AS oa ON oa.shop_id = orders.shop_id AND oa.tab_id = orders.tab_id AND oa.order_id = orders.id
+GROUP BY orders.shop_id, orders.tab_id, orders.bill_id, oa.kind, oa.description;
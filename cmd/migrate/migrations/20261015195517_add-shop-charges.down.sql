@@ -0,0 +1,7 @@
+ALTER TABLE tabs DROP COLUMN IF EXISTS is_tax_exempt;
+
+DROP TABLE IF EXISTS shop_charge_exemptions;
+DROP TABLE IF EXISTS shop_charges;
+DROP TYPE IF EXISTS shop_charge_kind;
+
+-- Values cannot be removed from an enum, so any tax and service fee adjustments are kept
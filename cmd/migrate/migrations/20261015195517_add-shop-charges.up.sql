@@ -0,0 +1,31 @@
+ALTER TYPE order_adjustment_kind ADD VALUE IF NOT EXISTS 'tax';
+ALTER TYPE order_adjustment_kind ADD VALUE IF NOT EXISTS 'service_fee';
+
+CREATE TYPE shop_charge_kind AS ENUM ('tax', 'service_fee');
+
+-- Charges are a percentage of the discounted price of each order's items, except those in exempt categories
+CREATE TABLE IF NOT EXISTS shop_charges (
+  shop_id INT NOT NULL,
+  id SERIAL NOT NULL,
+  kind shop_charge_kind NOT NULL,
+  name VARCHAR(64) NOT NULL,
+  rate_percent REAL NOT NULL,
+
+  PRIMARY KEY(shop_id, id),
+  FOREIGN KEY(shop_id) REFERENCES shops(id) ON DELETE CASCADE,
+  UNIQUE(shop_id, name),
+  CHECK ( rate_percent > 0 AND rate_percent <= 100 )
+);
+
+CREATE TABLE IF NOT EXISTS shop_charge_exemptions (
+  shop_id INT NOT NULL,
+  charge_id INT NOT NULL,
+  category_id INT NOT NULL,
+
+  PRIMARY KEY(shop_id, charge_id, category_id),
+  FOREIGN KEY(shop_id, charge_id) REFERENCES shop_charges(shop_id, id) ON DELETE CASCADE,
+  FOREIGN KEY(shop_id, category_id) REFERENCES item_categories(shop_id, id) ON DELETE CASCADE
+);
+
+-- Tax exempt tabs are not charged taxes, though service fees still apply
+ALTER TABLE tabs ADD COLUMN is_tax_exempt BOOLEAN NOT NULL DEFAULT FALSE;
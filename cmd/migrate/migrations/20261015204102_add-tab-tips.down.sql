@@ -0,0 +1,9 @@
+ALTER TABLE tab_updates
+  DROP COLUMN IF EXISTS max_tip_percent,
+  DROP COLUMN IF EXISTS allow_tips;
+
+ALTER TABLE tabs
+  DROP COLUMN IF EXISTS max_tip_percent,
+  DROP COLUMN IF EXISTS allow_tips;
+
+-- Values cannot be removed from an enum, so any tip adjustments are kept
@@ -0,0 +1,13 @@
+ALTER TYPE order_adjustment_kind ADD VALUE IF NOT EXISTS 'tip';
+
+ALTER TABLE tabs
+  ADD COLUMN allow_tips BOOLEAN NOT NULL DEFAULT FALSE,
+  ADD COLUMN max_tip_percent REAL NOT NULL DEFAULT 20,
+  ADD CONSTRAINT tabs_max_tip_percent_check CHECK ( max_tip_percent >= 0 AND max_tip_percent <= 100 ),
+  ADD CONSTRAINT tabs_max_tip_percent_allowed_check CHECK ( NOT allow_tips OR max_tip_percent > 0 );
+
+ALTER TABLE tab_updates
+  ADD COLUMN allow_tips BOOLEAN NOT NULL DEFAULT FALSE,
+  ADD COLUMN max_tip_percent REAL NOT NULL DEFAULT 20,
+  ADD CONSTRAINT tab_updates_max_tip_percent_check CHECK ( max_tip_percent >= 0 AND max_tip_percent <= 100 ),
+  ADD CONSTRAINT tab_updates_max_tip_percent_allowed_check CHECK ( NOT allow_tips OR max_tip_percent > 0 );
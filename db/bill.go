@@ -0,0 +1,555 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/WilliamTrojniak/TabAppBackend/models"
+	"github.com/WilliamTrojniak/TabAppBackend/services"
+	"github.com/jackc/pgx/v5"
+)
+
+type tabBillingPeriod struct {
+	StartDate           models.Date  `db:"start_date"`
+	EndDate             models.Date  `db:"end_date"`
+	BillingIntervalDays int          `db:"billing_interval_days"`
+	LastBillEndDate     *models.Date `db:"last_bill_end_date"`
+}
+
+// TabKey identifies a tab due for a scheduled job, along with the current date in its shop's time zone
+type TabKey struct {
+	ShopId int         `db:"shop_id"`
+	TabId  int         `db:"id"`
+	Today  models.Date `db:"today"`
+}
+
+// GetTabsDueRollOver returns the confirmed tabs with billing periods which have started on or before
+// the current date in the shop's time zone but do not yet have a bill. Tabs locked by another
+// transaction are skipped so that several instances may run concurrently.
+func (q *PgxQueries) GetTabsDueRollOver(ctx context.Context, now time.Time) ([]TabKey, error) {
+	return q.getTabsDueRollOver(ctx, now, nil, nil)
+}
+
+// RollOverTabBills opens the tab's billing periods which are due, locking the tab until the transaction
+// ends. It reports false if the tab is no longer due or is locked by another transaction.
+func (q *PgxQueries) RollOverTabBills(ctx context.Context, shopId int, tabId int, now time.Time) (bool, error) {
+	return WithTxRet(ctx, q, func(q *PgxQueries) (bool, error) {
+		tabs, err := q.getTabsDueRollOver(ctx, now, &shopId, &tabId)
+		if err != nil || len(tabs) == 0 {
+			return false, err
+		}
+
+		err = q.rolloverTabBills(ctx, shopId, tabId, tabs[0].Today)
+		if err != nil {
+			return false, err
+		}
+		return true, nil
+	})
+}
+
+func (q *PgxQueries) getTabsDueRollOver(ctx context.Context, now time.Time, shopId *int, tabId *int) ([]TabKey, error) {
+	rows, err := q.tx.Query(ctx, `
+    SELECT tabs.shop_id, tabs.id, shop_dates.today
+    FROM tabs
+    JOIN (SELECT shops.id, (@now::timestamptz AT TIME ZONE shops.time_zone)::date AS today FROM shops) AS shop_dates
+      ON shop_dates.id = tabs.shop_id
+    WHERE tabs.status = @status AND tabs.start_date <= shop_dates.today
+      AND COALESCE(
+        (SELECT MAX(b.end_date) FROM tab_bills AS b WHERE b.shop_id = tabs.shop_id AND b.tab_id = tabs.id),
+        tabs.start_date - 1) < LEAST(shop_dates.today, tabs.end_date)
+      AND (@tabId::INT IS NULL OR (tabs.shop_id = @shopId AND tabs.id = @tabId))
+    ORDER BY tabs.shop_id, tabs.id
+    FOR UPDATE OF tabs SKIP LOCKED`,
+		pgx.NamedArgs{
+			"status": models.TAB_STATUS_CONFIRMED,
+			"now":    now,
+			"shopId": shopId,
+			"tabId":  tabId,
+		})
+	if err != nil {
+		return nil, handlePgxError(err)
+	}
+
+	tabs, err := pgx.CollectRows(rows, pgx.RowToStructByName[TabKey])
+	if err != nil {
+		return nil, handlePgxError(err)
+	}
+	return tabs, nil
+}
+
+// rolloverTabBills creates bills for each billing period of the tab following its latest bill,
+// up to and including the period containing today.
+func (q *PgxQueries) rolloverTabBills(ctx context.Context, shopId int, tabId int, today models.Date) error {
+	rows, err := q.tx.Query(ctx, `
+    SELECT tabs.start_date, tabs.end_date, tabs.billing_interval_days,
+      (SELECT MAX(b.end_date) FROM tab_bills AS b WHERE b.shop_id = tabs.shop_id AND b.tab_id = tabs.id) AS last_bill_end_date
+    FROM tabs
+    WHERE tabs.shop_id = @shopId AND tabs.id = @tabId
+    FOR UPDATE`,
+		pgx.NamedArgs{
+			"shopId": shopId,
+			"tabId":  tabId,
+		})
+	if err != nil {
+		return handlePgxError(err)
+	}
+
+	tab, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[tabBillingPeriod])
+	if err != nil {
+		return handlePgxError(err)
+	}
+
+	for _, period := range tab.duePeriods(today) {
+		_, err = q.insertBill(ctx, shopId, tabId, period.StartDate, period.EndDate)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type billingPeriod struct {
+	StartDate models.Date
+	EndDate   models.Date
+}
+
+// duePeriods returns the billing periods following the tab's latest bill, up to and including
+// the period containing today. Periods are counted from the tab's start date, so a bill which was
+// issued before its period ended is followed by the rest of that period. The last period is cut
+// short at the tab's end date.
+func (t *tabBillingPeriod) duePeriods(today models.Date) []billingPeriod {
+	periods := make([]billingPeriod, 0)
+	periodStart := t.StartDate
+	if t.LastBillEndDate != nil {
+		periodStart = models.Date{Date: t.LastBillEndDate.AddDays(1)}
+	}
+
+	for !periodStart.After(today.Date) && !periodStart.After(t.EndDate.Date) {
+		elapsedPeriods := periodStart.DaysSince(t.StartDate.Date) / t.BillingIntervalDays
+		periodEnd := models.Date{Date: t.StartDate.AddDays((elapsedPeriods+1)*t.BillingIntervalDays - 1)}
+		if periodEnd.After(t.EndDate.Date) {
+			periodEnd = t.EndDate
+		}
+
+		periods = append(periods, billingPeriod{StartDate: periodStart, EndDate: periodEnd})
+		periodStart = models.Date{Date: periodEnd.AddDays(1)}
+	}
+	return periods
+}
+
+// finalizeTabBills bills the tab through its last day, cutting short the open period containing it,
+// and marks the tab's latest bill which has not been voided as its final bill. Bills which were
+// already issued keep the period they were invoiced for.
+func (q *PgxQueries) finalizeTabBills(ctx context.Context, shopId int, tabId int, lastDay models.Date) error {
+	err := q.rolloverTabBills(ctx, shopId, tabId, lastDay)
+	if err != nil {
+		return err
+	}
+
+	_, err = q.tx.Exec(ctx, `
+    UPDATE tab_bills SET end_date = @lastDay
+    WHERE shop_id = @shopId AND tab_id = @tabId AND status = @open
+      AND start_date <= @lastDay AND end_date > @lastDay`,
+		pgx.NamedArgs{
+			"shopId":  shopId,
+			"tabId":   tabId,
+			"lastDay": lastDay,
+			"open":    models.BILL_STATUS_OPEN,
+		})
+	if err != nil {
+		return handlePgxError(err)
+	}
+
+	_, err = q.tx.Exec(ctx, `
+    UPDATE tab_bills SET is_final = TRUE
+    WHERE shop_id = @shopId AND tab_id = @tabId AND id = (
+      SELECT b.id FROM tab_bills AS b
+      WHERE b.shop_id = @shopId AND b.tab_id = @tabId AND b.status <> @void
+      ORDER BY b.start_date DESC, b.id DESC
+      LIMIT 1)`,
+		pgx.NamedArgs{
+			"shopId": shopId,
+			"tabId":  tabId,
+			"void":   models.BILL_STATUS_VOID,
+		})
+	if err != nil {
+		return handlePgxError(err)
+	}
+
+	return nil
+}
+
+func (q *PgxQueries) insertBill(ctx context.Context, shopId int, tabId int, startDate models.Date, endDate models.Date) (int, error) {
+	var billId int
+	row := q.tx.QueryRow(ctx, `
+    INSERT INTO tab_bills 
+    (shop_id, tab_id, start_date, end_date) VALUES (@shopId, @tabId, @startDate, @endDate) RETURNING id
+    `, pgx.NamedArgs{
+		"shopId":    shopId,
+		"tabId":     tabId,
+		"startDate": startDate,
+		"endDate":   endDate,
+	})
+	err := row.Scan(&billId)
+	if err != nil {
+		return 0, handlePgxError(err)
+	}
+
+	return billId, nil
+}
+
+// getTargetBill returns the open bill covering the current date, rolling the tab's billing periods
+// forward first in case the scheduler has not yet done so.
+func (q *PgxQueries) getTargetBill(ctx context.Context, shopId int, tabId int, now time.Time) (int, error) {
+	today, err := q.GetShopDate(ctx, shopId, now)
+	if err != nil {
+		return 0, err
+	}
+
+	err = q.rolloverTabBills(ctx, shopId, tabId, today)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := q.tx.Query(ctx, `
+    SELECT b.*
+    FROM tab_bills AS b
+    WHERE b.shop_id = @shopId AND b.tab_id = @tabId
+      AND b.start_date <= @today AND b.end_date >= @today
+    ORDER BY b.status = @open DESC, b.start_date DESC
+    LIMIT 1`,
+		pgx.NamedArgs{
+			"shopId": shopId,
+			"tabId":  tabId,
+			"today":  today,
+			"open":   models.BILL_STATUS_OPEN,
+		})
+	if err != nil {
+		return 0, handlePgxError(err)
+	}
+
+	bill, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByNameLax[models.BillOverview])
+	if err != nil {
+		return 0, handlePgxError(err)
+	}
+
+	switch bill.Status {
+	case models.BILL_STATUS_OPEN.String():
+		return bill.Id, nil
+	case models.BILL_STATUS_VOID.String():
+		// The current period's bill was voided, so open a new bill for the remainder of it
+		return q.insertBill(ctx, shopId, tabId, today, bill.EndDate)
+	}
+
+	// The current period was invoiced early, ending its bill today, so orders are added to the bill
+	// for the rest of the period from tomorrow
+	tomorrow := models.Date{Date: today.AddDays(1)}
+	err = q.rolloverTabBills(ctx, shopId, tabId, tomorrow)
+	if err != nil {
+		return 0, err
+	}
+
+	var billId int
+	row := q.tx.QueryRow(ctx, `
+    SELECT b.id
+    FROM tab_bills AS b
+    WHERE b.shop_id = @shopId AND b.tab_id = @tabId AND b.status = @open
+      AND b.start_date = @tomorrow`,
+		pgx.NamedArgs{
+			"shopId":   shopId,
+			"tabId":    tabId,
+			"tomorrow": tomorrow,
+			"open":     models.BILL_STATUS_OPEN,
+		})
+	err = row.Scan(&billId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, services.NewDataConflictServiceError(errors.New("The tab's final bill has already been issued"))
+	}
+	if err != nil {
+		return 0, handlePgxError(err)
+	}
+	return billId, nil
+}
+
+// IssueBill sends an open bill to the tab owner, assigning it the shop's next invoice number
+func (q *PgxQueries) IssueBill(ctx context.Context, shopId int, tabId int, billId int, userId string, now time.Time) error {
+	return q.WithTx(ctx, func(q *PgxQueries) error {
+		bill, err := q.getBillForUpdate(ctx, shopId, tabId, billId)
+		if err != nil {
+			return err
+		}
+
+		if bill.Status != models.BILL_STATUS_OPEN.String() {
+			return services.NewDataConflictServiceError(fmt.Errorf("Cannot issue %v bill", bill.Status))
+		}
+
+		return q.issueBill(ctx, shopId, tabId, billId, userId, now)
+	})
+}
+
+// MarkTabBillPaid records that a bill has been paid, issuing it first if it is still open.
+// It reports whether the bill was issued.
+func (q *PgxQueries) MarkTabBillPaid(ctx context.Context, shopId int, tabId int, billId int, userId string, now time.Time) (bool, error) {
+	return WithTxRet(ctx, q, func(q *PgxQueries) (bool, error) {
+		bill, err := q.getBillForUpdate(ctx, shopId, tabId, billId)
+		if err != nil {
+			return false, err
+		}
+
+		return q.payBill(ctx, &bill, shopId, tabId, userId, now)
+	})
+}
+
+// RecordBillPayment records a full or partial payment towards an unpaid bill, marking the bill
+// paid once nothing remains outstanding. It reports whether the bill was issued by being paid.
+func (q *PgxQueries) RecordBillPayment(ctx context.Context, shopId int, tabId int, billId int, userId string, data *models.BillPaymentCreate, paidOn models.Date, now time.Time) (models.BillPayment, bool, error) {
+	type result struct {
+		payment models.BillPayment
+		issued  bool
+	}
+	r, err := WithTxRet(ctx, q, func(q *PgxQueries) (result, error) {
+		bill, err := q.getBillForUpdate(ctx, shopId, tabId, billId)
+		if err != nil {
+			return result{}, err
+		}
+
+		if bill.Status != models.BILL_STATUS_OPEN.String() && bill.Status != models.BILL_STATUS_ISSUED.String() {
+			return result{}, services.NewDataConflictServiceError(fmt.Errorf("Cannot record payment for %v bill", bill.Status))
+		}
+
+		outstanding, err := q.getBillOutstanding(ctx, shopId, tabId, billId)
+		if err != nil {
+			return result{}, err
+		}
+
+		if data.Amount > outstanding {
+			return result{}, services.NewValidationServiceError(errors.New("Payment exceeds outstanding balance"), services.ValidationErrors{
+				"amount": services.ValidationError{Value: outstanding, Error: "outstanding"},
+			})
+		}
+
+		rows, err := q.tx.Query(ctx, `
+    INSERT INTO bill_payments (shop_id, tab_id, bill_id, amount, method, reference, paid_on, recorded_by)
+    VALUES (@shopId, @tabId, @billId, @amount, @method, @reference, @paidOn, @userId)
+    RETURNING id, amount, method, reference, paid_on, recorded_by, recorded_at`,
+			pgx.NamedArgs{
+				"shopId":    shopId,
+				"tabId":     tabId,
+				"billId":    billId,
+				"amount":    data.Amount,
+				"method":    data.Method,
+				"reference": data.Reference,
+				"paidOn":    paidOn,
+				"userId":    userId,
+			})
+		if err != nil {
+			return result{}, handlePgxError(err)
+		}
+
+		payment, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[models.BillPayment])
+		if err != nil {
+			return result{}, handlePgxError(err)
+		}
+
+		outstanding, err = q.getBillOutstanding(ctx, shopId, tabId, billId)
+		if err != nil {
+			return result{}, err
+		}
+
+		issued := false
+		if outstanding <= 0 {
+			issued, err = q.payBill(ctx, &bill, shopId, tabId, userId, now)
+			if err != nil {
+				return result{}, err
+			}
+		}
+
+		return result{payment: payment, issued: issued}, nil
+	})
+	return r.payment, r.issued, err
+}
+
+func (q *PgxQueries) GetBillPayments(ctx context.Context, shopId int, tabId int, billId int) ([]models.BillPayment, error) {
+	rows, err := q.tx.Query(ctx, `
+    SELECT id, amount, method, reference, paid_on, recorded_by, recorded_at
+    FROM bill_payments
+    WHERE shop_id = @shopId AND tab_id = @tabId AND bill_id = @billId
+    ORDER BY paid_on, id`,
+		pgx.NamedArgs{
+			"shopId": shopId,
+			"tabId":  tabId,
+			"billId": billId,
+		})
+	if err != nil {
+		return nil, handlePgxError(err)
+	}
+
+	payments, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.BillPayment])
+	if err != nil {
+		return nil, handlePgxError(err)
+	}
+	return payments, nil
+}
+
+func (q *PgxQueries) getBillOutstanding(ctx context.Context, shopId int, tabId int, billId int) (float32, error) {
+	var outstanding float32
+	row := q.tx.QueryRow(ctx, `
+    SELECT outstanding FROM tab_bill_balances
+    WHERE shop_id = @shopId AND tab_id = @tabId AND bill_id = @billId`,
+		pgx.NamedArgs{
+			"shopId": shopId,
+			"tabId":  tabId,
+			"billId": billId,
+		})
+	err := row.Scan(&outstanding)
+	if err != nil {
+		return 0, handlePgxError(err)
+	}
+	return outstanding, nil
+}
+
+// payBill moves a locked bill to paid, issuing it first if it is still open and reporting whether it was
+func (q *PgxQueries) payBill(ctx context.Context, bill *models.BillOverview, shopId int, tabId int, userId string, now time.Time) (bool, error) {
+	issued := false
+	switch bill.Status {
+	case models.BILL_STATUS_OPEN.String():
+		err := q.issueBill(ctx, shopId, tabId, bill.Id, userId, now)
+		if err != nil {
+			return false, err
+		}
+		issued = true
+	case models.BILL_STATUS_ISSUED.String():
+	default:
+		return false, services.NewDataConflictServiceError(fmt.Errorf("Cannot pay %v bill", bill.Status))
+	}
+
+	_, err := q.tx.Exec(ctx, `
+    UPDATE tab_bills
+    SET (status, paid_at, paid_by) = (@status, NOW(), @userId)
+    WHERE shop_id = @shopId AND tab_id = @tabId AND id = @billId`,
+		pgx.NamedArgs{
+			"shopId": shopId,
+			"tabId":  tabId,
+			"billId": bill.Id,
+			"status": models.BILL_STATUS_PAID,
+			"userId": userId,
+		})
+	if err != nil {
+		return false, handlePgxError(err)
+	}
+
+	return issued, q.AddTabEvent(ctx, shopId, tabId, models.TAB_COMMENT_KIND_BILL_PAID, &userId, &bill.Id)
+}
+
+// VoidBill cancels a bill which has not been paid. Bills with payments recorded against them, or which have
+// been exported to a journal, cannot be voided since there is no way to reverse the money already accounted for.
+func (q *PgxQueries) VoidBill(ctx context.Context, shopId int, tabId int, billId int, userId string, reason string) error {
+	return q.WithTx(ctx, func(q *PgxQueries) error {
+		bill, err := q.getBillForUpdate(ctx, shopId, tabId, billId)
+		if err != nil {
+			return err
+		}
+
+		if bill.Status != models.BILL_STATUS_OPEN.String() && bill.Status != models.BILL_STATUS_ISSUED.String() {
+			return services.NewDataConflictServiceError(fmt.Errorf("Cannot void %v bill", bill.Status))
+		}
+		if bill.JournalExportId != nil {
+			return services.NewDataConflictServiceError(errors.New("Cannot void a bill which has been exported to a journal"))
+		}
+
+		payments, err := q.GetBillPayments(ctx, shopId, tabId, billId)
+		if err != nil {
+			return err
+		}
+		if len(payments) > 0 {
+			return services.NewDataConflictServiceError(errors.New("Cannot void a bill with recorded payments"))
+		}
+
+		_, err = q.tx.Exec(ctx, `
+    UPDATE tab_bills
+    SET (status, voided_at, voided_by, void_reason) = (@status, NOW(), @userId, @reason)
+    WHERE shop_id = @shopId AND tab_id = @tabId AND id = @billId`,
+			pgx.NamedArgs{
+				"shopId": shopId,
+				"tabId":  tabId,
+				"billId": billId,
+				"status": models.BILL_STATUS_VOID,
+				"userId": userId,
+				"reason": reason,
+			})
+		if err != nil {
+			return handlePgxError(err)
+		}
+
+		return nil
+	})
+}
+
+// issueBill assigns the bill the shop's next invoice number. A bill issued before its period has ended
+// is cut short on the date it was issued, so that later orders go on a new bill for the rest of the period.
+func (q *PgxQueries) issueBill(ctx context.Context, shopId int, tabId int, billId int, userId string, now time.Time) error {
+	today, err := q.GetShopDate(ctx, shopId, now)
+	if err != nil {
+		return err
+	}
+
+	var invoiceNumber int
+	row := q.tx.QueryRow(ctx, `
+    INSERT INTO shop_invoice_sequences (shop_id, last_number) VALUES (@shopId, 1)
+    ON CONFLICT (shop_id) DO UPDATE SET last_number = shop_invoice_sequences.last_number + 1
+    RETURNING last_number`,
+		pgx.NamedArgs{
+			"shopId": shopId,
+		})
+	err = row.Scan(&invoiceNumber)
+	if err != nil {
+		return handlePgxError(err)
+	}
+
+	_, err = q.tx.Exec(ctx, `
+    UPDATE tab_bills
+    SET (status, invoice_number, issued_at, issued_by, payment_method, payment_details, end_date) =
+      (@status, @invoiceNumber, NOW(), @userId, tabs.payment_method, tabs.payment_details,
+        LEAST(tab_bills.end_date, GREATEST(tab_bills.start_date, @today::DATE)))
+    FROM tabs
+    WHERE tabs.shop_id = tab_bills.shop_id AND tabs.id = tab_bills.tab_id
+      AND tab_bills.shop_id = @shopId AND tab_bills.tab_id = @tabId AND tab_bills.id = @billId`,
+		pgx.NamedArgs{
+			"shopId":        shopId,
+			"tabId":         tabId,
+			"billId":        billId,
+			"status":        models.BILL_STATUS_ISSUED,
+			"invoiceNumber": invoiceNumber,
+			"userId":        userId,
+			"today":         today,
+		})
+	if err != nil {
+		return handlePgxError(err)
+	}
+
+	return nil
+}
+
+func (q *PgxQueries) getBillForUpdate(ctx context.Context, shopId int, tabId int, billId int) (models.BillOverview, error) {
+	rows, err := q.tx.Query(ctx, `
+    SELECT * FROM tab_bills
+    WHERE shop_id = @shopId AND tab_id = @tabId AND id = @billId
+    FOR UPDATE`,
+		pgx.NamedArgs{
+			"shopId": shopId,
+			"tabId":  tabId,
+			"billId": billId,
+		})
+	if err != nil {
+		return models.BillOverview{}, handlePgxError(err)
+	}
+
+	bill, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByNameLax[models.BillOverview])
+	if err != nil {
+		return models.BillOverview{}, handlePgxError(err)
+	}
+	return bill, nil
+}
@@ -0,0 +1,345 @@
+package db
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"github.com/WilliamTrojniak/TabAppBackend/models"
+	"github.com/WilliamTrojniak/TabAppBackend/services"
+	"github.com/WilliamTrojniak/TabAppBackend/util"
+	"github.com/jackc/pgx/v5"
+)
+
+func date(year int, month time.Month, day int) models.Date {
+	return models.Date{Date: civil.Date{Year: year, Month: month, Day: day}}
+}
+
+func TestDuePeriodsRollOverWithClock(t *testing.T) {
+	clock := util.NewFakeClock(time.Date(2026, time.January, 5, 12, 0, 0, 0, time.UTC))
+	tab := tabBillingPeriod{
+		StartDate:           date(2026, time.January, 1),
+		EndDate:             date(2026, time.March, 20),
+		BillingIntervalDays: 14,
+	}
+
+	// rollOver mimics the scheduler, billing the periods due on the clock's current date
+	rollOver := func() []billingPeriod {
+		periods := tab.duePeriods(models.DateOf(clock.Now()))
+		if len(periods) > 0 {
+			tab.LastBillEndDate = &periods[len(periods)-1].EndDate
+		}
+		return periods
+	}
+
+	periods := rollOver()
+	if len(periods) != 1 || periods[0] != (billingPeriod{date(2026, time.January, 1), date(2026, time.January, 14)}) {
+		t.Fatalf("expected the first period to be billed, got %v", periods)
+	}
+
+	clock.Advance(time.Hour * 24 * 5)
+	if periods := rollOver(); len(periods) != 0 {
+		t.Fatalf("expected no new periods within the current period, got %v", periods)
+	}
+
+	// Moving past the end of the period opens the next one
+	clock.Advance(time.Hour * 24 * 5)
+	periods = rollOver()
+	if len(periods) != 1 || periods[0] != (billingPeriod{date(2026, time.January, 15), date(2026, time.January, 28)}) {
+		t.Fatalf("expected the bill to roll over to the second period, got %v", periods)
+	}
+
+	// Periods missed while the scheduler was not running are all billed, the last cut short at the tab's end
+	clock.Advance(time.Hour * 24 * 60)
+	periods = rollOver()
+	expected := []billingPeriod{
+		{date(2026, time.January, 29), date(2026, time.February, 11)},
+		{date(2026, time.February, 12), date(2026, time.February, 25)},
+		{date(2026, time.February, 26), date(2026, time.March, 11)},
+		{date(2026, time.March, 12), date(2026, time.March, 20)},
+	}
+	if !slices.Equal(periods, expected) {
+		t.Fatalf("expected %v, got %v", expected, periods)
+	}
+
+	clock.Advance(time.Hour * 24 * 30)
+	if periods := rollOver(); len(periods) != 0 {
+		t.Fatalf("expected no periods after the tab ends, got %v", periods)
+	}
+}
+
+func TestDuePeriodsAfterEarlyIssue(t *testing.T) {
+	// The first period's bill was issued on the 5th of January, cutting it short
+	issuedOn := date(2026, time.January, 5)
+	tab := tabBillingPeriod{
+		StartDate:           date(2026, time.January, 1),
+		EndDate:             date(2026, time.March, 20),
+		BillingIntervalDays: 14,
+		LastBillEndDate:     &issuedOn,
+	}
+
+	if periods := tab.duePeriods(issuedOn); len(periods) != 0 {
+		t.Fatalf("expected no periods on the day the bill was issued, got %v", periods)
+	}
+
+	// The rest of the period starts the next day and ends when the period would have
+	periods := tab.duePeriods(date(2026, time.January, 20))
+	expected := []billingPeriod{
+		{date(2026, time.January, 6), date(2026, time.January, 14)},
+		{date(2026, time.January, 15), date(2026, time.January, 28)},
+	}
+	if !slices.Equal(periods, expected) {
+		t.Fatalf("expected %v, got %v", expected, periods)
+	}
+
+	// Issuing on the last day of a period leaves nothing of it to bill
+	lastDay := date(2026, time.January, 14)
+	tab.LastBillEndDate = &lastDay
+	periods = tab.duePeriods(date(2026, time.January, 15))
+	expected = []billingPeriod{{date(2026, time.January, 15), date(2026, time.January, 28)}}
+	if !slices.Equal(periods, expected) {
+		t.Fatalf("expected %v, got %v", expected, periods)
+	}
+}
+
+// getTestBillPeriods returns the periods of the tab's bills in order
+func getTestBillPeriods(t *testing.T, store *PgxStore, shopId int, tabId int) []billingPeriod {
+	t.Helper()
+	ctx := context.Background()
+	periods, err := WithTxRet(ctx, store, func(q *PgxQueries) ([]billingPeriod, error) {
+		rows, err := q.tx.Query(ctx, `
+    SELECT start_date, end_date FROM tab_bills
+    WHERE shop_id = $1 AND tab_id = $2
+    ORDER BY start_date`, shopId, tabId)
+		if err != nil {
+			return nil, err
+		}
+		return pgx.CollectRows(rows, func(row pgx.CollectableRow) (billingPeriod, error) {
+			var period billingPeriod
+			err := row.Scan(&period.StartDate, &period.EndDate)
+			return period, err
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return periods
+}
+
+func TestRollOverTabBillsSkipsLockedTabs(t *testing.T) {
+	store := testStore(t)
+	ctx := context.Background()
+	now := time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC)
+	shopId, tabId := createTestTab(t, store, "UTC", models.TAB_STATUS_CONFIRMED, date(2026, time.March, 1), date(2026, time.March, 31), 7)
+
+	// Another instance is rolling over the tab
+	locker, err := store.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer locker.Rollback(ctx)
+	_, err = locker.tx.Exec(ctx, `SELECT 1 FROM tabs WHERE shop_id = $1 AND id = $2 FOR UPDATE`, shopId, tabId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = WithTx(ctx, store, func(q *PgxQueries) error {
+		tabs, err := q.GetTabsDueRollOver(ctx, now)
+		if err != nil {
+			return err
+		}
+		if containsTab(tabs, shopId, tabId) {
+			t.Errorf("expected the locked tab to be skipped, got %v", tabs)
+		}
+
+		rolled, err := q.RollOverTabBills(ctx, shopId, tabId, now)
+		if err != nil {
+			return err
+		}
+		if rolled {
+			t.Errorf("expected the locked tab not to be rolled over")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = locker.Rollback(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []bool{true, false} {
+		rolled, err := WithTxRet(ctx, store, func(q *PgxQueries) (bool, error) {
+			return q.RollOverTabBills(ctx, shopId, tabId, now)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rolled != expected {
+			t.Fatalf("expected rolled over to be %v, got %v", expected, rolled)
+		}
+	}
+
+	periods := getTestBillPeriods(t, store, shopId, tabId)
+	expected := []billingPeriod{{date(2026, time.March, 1), date(2026, time.March, 7)}}
+	if !slices.Equal(periods, expected) {
+		t.Fatalf("expected %v, got %v", expected, periods)
+	}
+}
+
+func TestRollOverTabBillsUsesShopTimeZone(t *testing.T) {
+	store := testStore(t)
+	ctx := context.Background()
+	// It is already the 2nd of March in Auckland, but still the 1st in Los Angeles
+	now := time.Date(2026, time.March, 1, 20, 0, 0, 0, time.UTC)
+	aucklandShopId, aucklandTabId := createTestTab(t, store, "Pacific/Auckland", models.TAB_STATUS_CONFIRMED, date(2026, time.March, 2), date(2026, time.March, 31), 7)
+	laShopId, laTabId := createTestTab(t, store, "America/Los_Angeles", models.TAB_STATUS_CONFIRMED, date(2026, time.March, 2), date(2026, time.March, 31), 7)
+
+	tabs, err := WithTxRet(ctx, store, func(q *PgxQueries) ([]TabKey, error) {
+		return q.GetTabsDueRollOver(ctx, now)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !containsTab(tabs, aucklandShopId, aucklandTabId) {
+		t.Errorf("expected the tab starting today in Auckland to be due, got %v", tabs)
+	}
+	if containsTab(tabs, laShopId, laTabId) {
+		t.Errorf("expected the tab starting tomorrow in Los Angeles not to be due, got %v", tabs)
+	}
+
+	for _, tab := range []struct {
+		shopId, tabId int
+		expected      []billingPeriod
+	}{
+		{aucklandShopId, aucklandTabId, []billingPeriod{{date(2026, time.March, 2), date(2026, time.March, 8)}}},
+		{laShopId, laTabId, []billingPeriod{}},
+	} {
+		_, err := WithTxRet(ctx, store, func(q *PgxQueries) (bool, error) {
+			return q.RollOverTabBills(ctx, tab.shopId, tab.tabId, now)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		periods := getTestBillPeriods(t, store, tab.shopId, tab.tabId)
+		if !slices.Equal(periods, tab.expected) {
+			t.Errorf("expected %v, got %v", tab.expected, periods)
+		}
+	}
+}
+
+func TestIssueBillEarlyEndsItsPeriod(t *testing.T) {
+	store := testStore(t)
+	ctx := context.Background()
+	now := time.Date(2026, time.March, 3, 12, 0, 0, 0, time.UTC)
+	shopId, tabId := createTestTab(t, store, "UTC", models.TAB_STATUS_CONFIRMED, date(2026, time.March, 1), date(2026, time.March, 31), 7)
+
+	err := WithTx(ctx, store, func(q *PgxQueries) error {
+		billId, err := q.getTargetBill(ctx, shopId, tabId, now)
+		if err != nil {
+			return err
+		}
+
+		var ownerId string
+		err = q.tx.QueryRow(ctx, `SELECT owner_id FROM tabs WHERE shop_id = $1 AND id = $2`, shopId, tabId).Scan(&ownerId)
+		if err != nil {
+			return err
+		}
+
+		err = q.IssueBill(ctx, shopId, tabId, billId, ownerId, now)
+		if err != nil {
+			return err
+		}
+
+		nextBillId, err := q.getTargetBill(ctx, shopId, tabId, now)
+		if err != nil {
+			return err
+		}
+		if nextBillId == billId {
+			t.Errorf("expected orders after the bill was issued to go on a new bill")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	periods := getTestBillPeriods(t, store, shopId, tabId)
+	expected := []billingPeriod{
+		{date(2026, time.March, 1), date(2026, time.March, 3)},
+		{date(2026, time.March, 4), date(2026, time.March, 7)},
+	}
+	if !slices.Equal(periods, expected) {
+		t.Fatalf("expected %v, got %v", expected, periods)
+	}
+}
+
+// Money received on a bill, or already sent to a journal, cannot be reversed by voiding the bill
+func TestVoidBillRejectsAccountedBills(t *testing.T) {
+	store := testStore(t)
+	ctx := context.Background()
+	now := time.Date(2026, time.March, 3, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		prepare string
+		allowed bool
+	}{
+		{"open bill", ``, true},
+		{"bill with a payment", `
+    INSERT INTO bill_payments (shop_id, tab_id, bill_id, amount, method, paid_on, recorded_by)
+    VALUES (@shopId, @tabId, @billId, 5, 'in person', '2026-03-03', @userId)`, false},
+		{"exported bill", `
+    WITH export AS (
+      INSERT INTO journal_exports (shop_id, start_date, end_date, exported_by)
+      VALUES (@shopId, '2026-03-01', '2026-03-31', @userId) RETURNING id)
+    UPDATE tab_bills SET journal_export_id = (SELECT id FROM export)
+    WHERE shop_id = @shopId AND tab_id = @tabId AND id = @billId`, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			shopId, tabId := createTestTab(t, store, "UTC", models.TAB_STATUS_CONFIRMED, date(2026, time.March, 1), date(2026, time.March, 31), 7)
+
+			err := WithTx(ctx, store, func(q *PgxQueries) error {
+				billId, err := q.getTargetBill(ctx, shopId, tabId, now)
+				if err != nil {
+					return err
+				}
+
+				var ownerId string
+				err = q.tx.QueryRow(ctx, `SELECT owner_id FROM tabs WHERE shop_id = $1 AND id = $2`, shopId, tabId).Scan(&ownerId)
+				if err != nil {
+					return err
+				}
+
+				if test.prepare != "" {
+					_, err = q.tx.Exec(ctx, test.prepare, pgx.NamedArgs{"shopId": shopId, "tabId": tabId, "billId": billId, "userId": ownerId})
+					if err != nil {
+						return err
+					}
+				}
+
+				err = q.VoidBill(ctx, shopId, tabId, billId, ownerId, "Test void")
+				if test.allowed {
+					return err
+				}
+
+				serviceErr, ok := err.(*services.ServiceError)
+				if !ok || serviceErr.StatusCode() != http.StatusConflict {
+					t.Errorf("expected a conflict, got %v", err)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
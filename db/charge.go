@@ -0,0 +1,117 @@
+package db
+
+import (
+	"context"
+
+	"github.com/WilliamTrojniak/TabAppBackend/models"
+	"github.com/WilliamTrojniak/TabAppBackend/services"
+	"github.com/jackc/pgx/v5"
+)
+
+func (q *PgxQueries) CreateShopCharge(ctx context.Context, data *models.ShopChargeCreate) error {
+	return q.WithTx(ctx, func(q *PgxQueries) error {
+		row := q.tx.QueryRow(ctx, `
+      INSERT INTO shop_charges (shop_id, kind, name, rate_percent)
+      VALUES (@shopId, @kind, @name, @ratePercent)
+      RETURNING id`,
+			pgx.NamedArgs{
+				"shopId":      data.ShopId,
+				"kind":        data.Kind,
+				"name":        data.Name,
+				"ratePercent": data.RatePercent,
+			})
+		var chargeId int
+		err := row.Scan(&chargeId)
+		if err != nil {
+			return handlePgxError(err)
+		}
+
+		return q.setShopChargeExemptions(ctx, data.ShopId, chargeId, data.ExemptCategoryIds)
+	})
+}
+
+func (q *PgxQueries) GetShopCharges(ctx context.Context, shopId int) ([]models.ShopCharge, error) {
+	rows, err := q.tx.Query(ctx, `
+    SELECT shop_charges.*,
+      (SELECT COALESCE(array_agg(e.category_id ORDER BY e.category_id), '{}')
+       FROM shop_charge_exemptions AS e
+       WHERE e.shop_id = shop_charges.shop_id AND e.charge_id = shop_charges.id
+      ) AS exempt_category_ids
+    FROM shop_charges
+    WHERE shop_charges.shop_id = @shopId
+    ORDER BY shop_charges.kind, shop_charges.id`,
+		pgx.NamedArgs{
+			"shopId": shopId,
+		})
+	if err != nil {
+		return nil, handlePgxError(err)
+	}
+
+	charges, err := pgx.CollectRows(rows, pgx.RowToStructByNameLax[models.ShopCharge])
+	if err != nil {
+		return nil, handlePgxError(err)
+	}
+	return charges, nil
+}
+
+func (q *PgxQueries) UpdateShopCharge(ctx context.Context, shopId int, chargeId int, data *models.ShopChargeUpdate) error {
+	return q.WithTx(ctx, func(q *PgxQueries) error {
+		result, err := q.tx.Exec(ctx, `
+      UPDATE shop_charges SET (kind, name, rate_percent) = (@kind, @name, @ratePercent)
+      WHERE shop_id = @shopId AND id = @chargeId`,
+			pgx.NamedArgs{
+				"shopId":      shopId,
+				"chargeId":    chargeId,
+				"kind":        data.Kind,
+				"name":        data.Name,
+				"ratePercent": data.RatePercent,
+			})
+		if err != nil {
+			return handlePgxError(err)
+		}
+
+		if result.RowsAffected() == 0 {
+			return services.NewNotFoundServiceError(nil)
+		}
+
+		return q.setShopChargeExemptions(ctx, shopId, chargeId, data.ExemptCategoryIds)
+	})
+}
+
+func (q *PgxQueries) DeleteShopCharge(ctx context.Context, shopId int, chargeId int) error {
+	result, err := q.tx.Exec(ctx, `
+    DELETE FROM shop_charges WHERE shop_id = @shopId AND id = @chargeId`,
+		pgx.NamedArgs{
+			"shopId":   shopId,
+			"chargeId": chargeId,
+		})
+	if err != nil {
+		return handlePgxError(err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return services.NewNotFoundServiceError(nil)
+	}
+	return nil
+}
+
+func (q *PgxQueries) setShopChargeExemptions(ctx context.Context, shopId int, chargeId int, categoryIds []int) error {
+	_, err := q.tx.Exec(ctx, `
+    DELETE FROM shop_charge_exemptions WHERE shop_id = @shopId AND charge_id = @chargeId`,
+		pgx.NamedArgs{
+			"shopId":   shopId,
+			"chargeId": chargeId,
+		})
+	if err != nil {
+		return handlePgxError(err)
+	}
+
+	_, err = q.tx.CopyFrom(ctx, pgx.Identifier{"shop_charge_exemptions"},
+		[]string{"shop_id", "charge_id", "category_id"}, pgx.CopyFromSlice(len(categoryIds), func(i int) ([]any, error) {
+			return []any{shopId, chargeId, categoryIds[i]}, nil
+		}))
+	if err != nil {
+		return handlePgxError(err)
+	}
+	return nil
+}
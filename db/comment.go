@@ -0,0 +1,96 @@
+package db
+
+import (
+	"context"
+
+	"github.com/WilliamTrojniak/TabAppBackend/models"
+	"github.com/jackc/pgx/v5"
+)
+
+func (q *PgxQueries) AddTabComment(ctx context.Context, shopId int, tabId int, userId string, data *models.TabCommentCreate) (models.TabComment, error) {
+	return WithTxRet(ctx, q, func(q *PgxQueries) (models.TabComment, error) {
+		var commentId int
+		row := q.tx.QueryRow(ctx, `
+    INSERT INTO tab_comments (shop_id, tab_id, kind, author_id, body)
+    VALUES (@shopId, @tabId, @kind, @userId, @body)
+    RETURNING id`,
+			pgx.NamedArgs{
+				"shopId": shopId,
+				"tabId":  tabId,
+				"kind":   models.TAB_COMMENT_KIND_COMMENT,
+				"userId": userId,
+				"body":   data.Body,
+			})
+		err := row.Scan(&commentId)
+		if err != nil {
+			return models.TabComment{}, handlePgxError(err)
+		}
+
+		return q.getTabComment(ctx, shopId, tabId, commentId)
+	})
+}
+
+// AddTabEvent records an event in the tab's thread. Events with no user were made by the system,
+// and events about a particular bill refer to it.
+func (q *PgxQueries) AddTabEvent(ctx context.Context, shopId int, tabId int, kind models.TabCommentKind, userId *string, billId *int) error {
+	_, err := q.tx.Exec(ctx, `
+    INSERT INTO tab_comments (shop_id, tab_id, kind, author_id, bill_id)
+    VALUES (@shopId, @tabId, @kind, @userId, @billId)`,
+		pgx.NamedArgs{
+			"shopId": shopId,
+			"tabId":  tabId,
+			"kind":   kind,
+			"userId": userId,
+			"billId": billId,
+		})
+	if err != nil {
+		return handlePgxError(err)
+	}
+	return nil
+}
+
+func (q *PgxQueries) GetTabComments(ctx context.Context, shopId int, tabId int) ([]models.TabComment, error) {
+	rows, err := q.tx.Query(ctx, `
+    SELECT c.id, c.kind, c.author_id, COALESCE(users.preferred_name, users.name) AS author_name,
+      c.body, c.bill_id, c.created_at
+    FROM tab_comments AS c
+    LEFT JOIN users ON users.id = c.author_id
+    WHERE c.shop_id = @shopId AND c.tab_id = @tabId
+    ORDER BY c.created_at, c.id`,
+		pgx.NamedArgs{
+			"shopId": shopId,
+			"tabId":  tabId,
+		})
+	if err != nil {
+		return nil, handlePgxError(err)
+	}
+
+	comments, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.TabComment])
+	if err != nil {
+		return nil, handlePgxError(err)
+	}
+	return comments, nil
+}
+
+func (q *PgxQueries) getTabComment(ctx context.Context, shopId int, tabId int, commentId int) (models.TabComment, error) {
+	rows, err := q.tx.Query(ctx, `
+    SELECT c.id, c.kind, c.author_id, COALESCE(users.preferred_name, users.name) AS author_name,
+      c.body, c.bill_id, c.created_at
+    FROM tab_comments AS c
+    LEFT JOIN users ON users.id = c.author_id
+    WHERE c.shop_id = @shopId AND c.tab_id = @tabId AND c.id = @commentId`,
+		pgx.NamedArgs{
+			"shopId":    shopId,
+			"tabId":     tabId,
+			"commentId": commentId,
+		})
+	if err != nil {
+		return models.TabComment{}, handlePgxError(err)
+	}
+
+	comment, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[models.TabComment])
+	if err != nil {
+		return models.TabComment{}, handlePgxError(err)
+	}
+	return comment, nil
+}
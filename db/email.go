@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/WilliamTrojniak/TabAppBackend/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// EnqueueEmail adds an email to the outbox. It is only sent once the enclosing transaction commits.
+func (q *PgxQueries) EnqueueEmail(ctx context.Context, data *models.EmailCreate) error {
+	_, err := q.tx.Exec(ctx, `
+    INSERT INTO email_outbox (recipient, template, subject, text_body, html_body)
+    VALUES (@recipient, @template, @subject, @textBody, @htmlBody)`,
+		pgx.NamedArgs{
+			"recipient": data.Recipient,
+			"template":  data.Template,
+			"subject":   data.Subject,
+			"textBody":  data.TextBody,
+			"htmlBody":  data.HtmlBody,
+		})
+	if err != nil {
+		return handlePgxError(err)
+	}
+	return nil
+}
+
+// ClaimEmails claims up to limit emails which are due to be sent by deferring their next attempt
+// until leaseUntil, so that other senders skip them once the claim commits. Emails which are not
+// marked sent or failed by then are claimed again.
+func (q *PgxQueries) ClaimEmails(ctx context.Context, now time.Time, leaseUntil time.Time, limit int) ([]models.Email, error) {
+	rows, err := q.tx.Query(ctx, `
+    UPDATE email_outbox SET next_attempt_at = @leaseUntil
+    WHERE id IN (
+      SELECT id FROM email_outbox
+      WHERE sent_at IS NULL AND failed_at IS NULL AND next_attempt_at <= @now
+      ORDER BY next_attempt_at, id
+      LIMIT @limit
+      FOR UPDATE SKIP LOCKED)
+    RETURNING id, recipient, template, subject, text_body, html_body, attempts, created_at`,
+		pgx.NamedArgs{
+			"now":        now,
+			"leaseUntil": leaseUntil,
+			"limit":      limit,
+		})
+	if err != nil {
+		return nil, handlePgxError(err)
+	}
+
+	emails, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.Email])
+	if err != nil {
+		return nil, handlePgxError(err)
+	}
+	return emails, nil
+}
+
+func (q *PgxQueries) MarkEmailSent(ctx context.Context, emailId int, now time.Time) error {
+	_, err := q.tx.Exec(ctx, `
+    UPDATE email_outbox SET (attempts, sent_at, last_error) = (attempts + 1, @now, NULL)
+    WHERE id = @emailId`,
+		pgx.NamedArgs{
+			"emailId": emailId,
+			"now":     now,
+		})
+	if err != nil {
+		return handlePgxError(err)
+	}
+	return nil
+}
+
+// MarkEmailAttemptFailed records a failed attempt to send an email, scheduling the next attempt
+// or, when nextAttemptAt is nil, giving up on the email.
+func (q *PgxQueries) MarkEmailAttemptFailed(ctx context.Context, emailId int, now time.Time, nextAttemptAt *time.Time, sendErr string) error {
+	_, err := q.tx.Exec(ctx, `
+    UPDATE email_outbox
+    SET (attempts, last_error, next_attempt_at, failed_at) = (
+      attempts + 1, @sendErr,
+      COALESCE(@nextAttemptAt, next_attempt_at),
+      CASE WHEN @nextAttemptAt::TIMESTAMPTZ IS NULL THEN @now::TIMESTAMPTZ ELSE NULL END)
+    WHERE id = @emailId`,
+		pgx.NamedArgs{
+			"emailId":       emailId,
+			"now":           now,
+			"nextAttemptAt": nextAttemptAt,
+			"sendErr":       sendErr,
+		})
+	if err != nil {
+		return handlePgxError(err)
+	}
+	return nil
+}
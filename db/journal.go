@@ -0,0 +1,141 @@
+package db
+
+import (
+	"context"
+	"errors"
+
+	"github.com/WilliamTrojniak/TabAppBackend/models"
+	"github.com/WilliamTrojniak/TabAppBackend/services"
+	"github.com/jackc/pgx/v5"
+)
+
+// CreateJournalExport assigns every issued or paid bill invoiced to a chartstring and ending within the
+// date range which has not yet been exported to a new export batch.
+func (q *PgxQueries) CreateJournalExport(ctx context.Context, shopId int, userId string, data *models.JournalExportCreate) (models.JournalExport, error) {
+	return WithTxRet(ctx, q, func(q *PgxQueries) (models.JournalExport, error) {
+		var exportId int
+		row := q.tx.QueryRow(ctx, `
+    INSERT INTO journal_exports (shop_id, start_date, end_date, exported_by)
+    VALUES (@shopId, @startDate, @endDate, @userId)
+    RETURNING id`,
+			pgx.NamedArgs{
+				"shopId":    shopId,
+				"startDate": data.StartDate,
+				"endDate":   data.EndDate,
+				"userId":    userId,
+			})
+		err := row.Scan(&exportId)
+		if err != nil {
+			return models.JournalExport{}, handlePgxError(err)
+		}
+
+		result, err := q.tx.Exec(ctx, `
+    UPDATE tab_bills SET journal_export_id = @exportId
+    WHERE tab_bills.shop_id = @shopId
+      AND tab_bills.status IN (@issued, @paid)
+      AND tab_bills.payment_method = @chartstring
+      AND tab_bills.journal_export_id IS NULL
+      AND tab_bills.end_date BETWEEN @startDate AND @endDate`,
+			pgx.NamedArgs{
+				"shopId":      shopId,
+				"exportId":    exportId,
+				"issued":      models.BILL_STATUS_ISSUED,
+				"paid":        models.BILL_STATUS_PAID,
+				"chartstring": models.PaymentMethodChartstring,
+				"startDate":   data.StartDate,
+				"endDate":     data.EndDate,
+			})
+		if err != nil {
+			return models.JournalExport{}, handlePgxError(err)
+		}
+
+		if result.RowsAffected() == 0 {
+			return models.JournalExport{}, services.NewNotFoundServiceError(errors.New("No issued chartstring bills to export"))
+		}
+
+		return q.GetJournalExport(ctx, shopId, exportId)
+	})
+}
+
+func (q *PgxQueries) GetJournalExports(ctx context.Context, shopId int) ([]models.JournalExport, error) {
+	rows, err := q.tx.Query(ctx, `
+    SELECT journal_exports.id, journal_exports.start_date, journal_exports.end_date,
+      journal_exports.exported_by, journal_exports.exported_at,
+      (SELECT COUNT(*) FROM tab_bills
+       WHERE tab_bills.shop_id = journal_exports.shop_id AND tab_bills.journal_export_id = journal_exports.id
+      ) AS bill_count
+    FROM journal_exports
+    WHERE journal_exports.shop_id = @shopId
+    ORDER BY journal_exports.exported_at DESC, journal_exports.id DESC`,
+		pgx.NamedArgs{
+			"shopId": shopId,
+		})
+	if err != nil {
+		return nil, handlePgxError(err)
+	}
+
+	exports, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.JournalExport])
+	if err != nil {
+		return nil, handlePgxError(err)
+	}
+	return exports, nil
+}
+
+func (q *PgxQueries) GetJournalExport(ctx context.Context, shopId int, exportId int) (models.JournalExport, error) {
+	rows, err := q.tx.Query(ctx, `
+    SELECT journal_exports.id, journal_exports.start_date, journal_exports.end_date,
+      journal_exports.exported_by, journal_exports.exported_at,
+      (SELECT COUNT(*) FROM tab_bills
+       WHERE tab_bills.shop_id = journal_exports.shop_id AND tab_bills.journal_export_id = journal_exports.id
+      ) AS bill_count
+    FROM journal_exports
+    WHERE journal_exports.shop_id = @shopId AND journal_exports.id = @exportId`,
+		pgx.NamedArgs{
+			"shopId":   shopId,
+			"exportId": exportId,
+		})
+	if err != nil {
+		return models.JournalExport{}, handlePgxError(err)
+	}
+
+	export, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[models.JournalExport])
+	if err != nil {
+		return models.JournalExport{}, handlePgxError(err)
+	}
+	return export, nil
+}
+
+func (q *PgxQueries) GetJournalEntries(ctx context.Context, shopId int, exportId int) ([]models.JournalEntry, error) {
+	rows, err := q.tx.Query(ctx, `
+    SELECT tab_bills.invoice_number, tab_bills.payment_details AS chartstring, tabs.organization,
+      tabs.id AS tab_id, tabs.display_name AS tab_name, tab_bills.id AS bill_id,
+      tab_bills.start_date, tab_bills.end_date,
+      (t.total - a.discounts - a.taxes - a.service_fees - a.tips)::REAL AS subtotal,
+      a.discounts, a.taxes, a.service_fees, a.tips, t.total AS amount
+    FROM tab_bills
+    JOIN tabs ON tabs.shop_id = tab_bills.shop_id AND tabs.id = tab_bills.tab_id
+    JOIN tab_bill_totals AS t ON t.shop_id = tab_bills.shop_id AND t.tab_id = tab_bills.tab_id AND t.bill_id = tab_bills.id
+    CROSS JOIN LATERAL (
+      SELECT COALESCE(SUM(ba.amount) FILTER (WHERE ba.kind = 'discount'), 0)::REAL AS discounts,
+        COALESCE(SUM(ba.amount) FILTER (WHERE ba.kind = 'tax'), 0)::REAL AS taxes,
+        COALESCE(SUM(ba.amount) FILTER (WHERE ba.kind = 'service_fee'), 0)::REAL AS service_fees,
+        COALESCE(SUM(ba.amount) FILTER (WHERE ba.kind = 'tip'), 0)::REAL AS tips
+      FROM bill_order_adjustments AS ba
+      WHERE ba.shop_id = tab_bills.shop_id AND ba.tab_id = tab_bills.tab_id AND ba.bill_id = tab_bills.id
+    ) AS a
+    WHERE tab_bills.shop_id = @shopId AND tab_bills.journal_export_id = @exportId
+    ORDER BY tab_bills.invoice_number`,
+		pgx.NamedArgs{
+			"shopId":   shopId,
+			"exportId": exportId,
+		})
+	if err != nil {
+		return nil, handlePgxError(err)
+	}
+
+	entries, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.JournalEntry])
+	if err != nil {
+		return nil, handlePgxError(err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,297 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/WilliamTrojniak/TabAppBackend/models"
+	"github.com/WilliamTrojniak/TabAppBackend/services"
+	"github.com/jackc/pgx/v5"
+)
+
+// PriceOrder resolves the names and current prices of the items and variants of an order
+func (q *PgxQueries) PriceOrder(ctx context.Context, shopId int, data *models.BillOrderCreate) ([]models.ItemOrder, error) {
+	itemIds := make([]int, 0, len(data.Items))
+	for _, item := range data.Items {
+		itemIds = append(itemIds, item.Id)
+	}
+
+	rows, err := q.tx.Query(ctx, `
+    SELECT items.id, items.name, items.base_price,
+      (SELECT COALESCE(json_agg(item_variants) FILTER (WHERE item_variants.id IS NOT NULL), '[]')
+       FROM item_variants
+       WHERE item_variants.shop_id = items.shop_id AND item_variants.item_id = items.id
+      ) AS variants
+    FROM items
+    WHERE items.shop_id = @shopId AND items.id = ANY (@itemIds)`,
+		pgx.NamedArgs{
+			"shopId":  shopId,
+			"itemIds": itemIds,
+		})
+	if err != nil {
+		return nil, handlePgxError(err)
+	}
+
+	type pricedItem struct {
+		models.ItemOverview
+		Variants []models.ItemVariant `db:"variants"`
+	}
+	menu, err := pgx.CollectRows(rows, pgx.RowToStructByName[pricedItem])
+	if err != nil {
+		return nil, handlePgxError(err)
+	}
+
+	items := make(map[int]pricedItem, len(menu))
+	for _, item := range menu {
+		items[item.Id] = item
+	}
+
+	orders := make([]models.ItemOrder, 0, len(data.Items))
+	for _, itemOrder := range data.Items {
+		item, ok := items[itemOrder.Id]
+		if !ok {
+			return nil, services.NewNotFoundServiceError(fmt.Errorf("Item %v not found", itemOrder.Id))
+		}
+
+		variants := make([]models.ItemVariantOrder, 0, len(itemOrder.Variants))
+		for _, variantOrder := range itemOrder.Variants {
+			found := false
+			for _, variant := range item.Variants {
+				if variant.Id == variantOrder.Id {
+					variants = append(variants, models.ItemVariantOrder{ItemVariant: variant, Quantity: *variantOrder.Quantity})
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, services.NewNotFoundServiceError(fmt.Errorf("Variant %v of item %v not found", variantOrder.Id, itemOrder.Id))
+			}
+		}
+
+		orders = append(orders, models.ItemOrder{
+			ItemOverview: item.ItemOverview,
+			Quantity:     *itemOrder.Quantity,
+			Variants:     variants,
+		})
+	}
+
+	return orders, nil
+}
+
+// RecordOrderLimitOverride records the override which allowed an order over the tab's dollar limit per order
+func (q *PgxQueries) RecordOrderLimitOverride(ctx context.Context, shopId int, tabId int, orderId int, override *models.OrderLimitOverride) error {
+	_, err := q.tx.Exec(ctx, `
+    INSERT INTO order_limit_overrides (shop_id, tab_id, order_id, user_id, reason, dollar_limit, total)
+    VALUES (@shopId, @tabId, @orderId, @userId, @reason, @limit, @total)`,
+		pgx.NamedArgs{
+			"shopId":  shopId,
+			"tabId":   tabId,
+			"orderId": orderId,
+			"userId":  override.UserId,
+			"reason":  override.Reason,
+			"limit":   override.DollarLimit,
+			"total":   override.Total,
+		})
+	if err != nil {
+		return handlePgxError(err)
+	}
+
+	return nil
+}
+
+// AddOrderToTab records an order and its adjustments against the tab's bill for the current date
+func (q *PgxQueries) AddOrderToTab(ctx context.Context, shopId int, tabId int, userId string, locationId int, customer string, items []models.ItemOrder, adjustments []models.OrderAdjustment, now time.Time) (models.OrderOverview, error) {
+	return WithTxRet(ctx, q, func(q *PgxQueries) (models.OrderOverview, error) {
+		billId, err := q.getTargetBill(ctx, shopId, tabId, now)
+		if err != nil {
+			return models.OrderOverview{}, err
+		}
+
+		return q.insertOrder(ctx, shopId, tabId, billId, userId, &locationId, customer, nil, nil, items, adjustments)
+	})
+}
+
+// GetOrderForVoid returns the tab's order with its lines, locking it until the transaction ends
+func (q *PgxQueries) GetOrderForVoid(ctx context.Context, shopId int, tabId int, orderId int) (models.Order, error) {
+	rows, err := q.tx.Query(ctx, `
+    SELECT o.id, o.bill_id, o.location_id, o.customer, o.user_id, o.created_at, o.voids_order_id, o.void_reason,
+      (SELECT COALESCE(json_agg(items ORDER BY items.line_no) FILTER (WHERE items.id IS NOT NULL), '[]') AS items
+        FROM
+        (SELECT oi.line_no, oi.item_id AS id, oi.name, oi.unit_price AS base_price, oi.quantity, o.location_id, o.customer,
+          (SELECT COALESCE(json_agg(variants) FILTER (WHERE variants.id IS NOT NULL), '[]') AS variants
+            FROM
+            (SELECT ov.variant_id AS id, ov.name, ov.unit_price AS price, ov.quantity
+              FROM order_variants AS ov
+              WHERE ov.shop_id = oi.shop_id AND ov.tab_id = oi.tab_id AND ov.order_id = oi.order_id
+                AND ov.line_no = oi.line_no) AS variants
+          ) AS variants
+          FROM order_items AS oi
+          WHERE oi.shop_id = o.shop_id AND oi.tab_id = o.tab_id AND oi.order_id = o.id) AS items
+      ) AS items,
+      (SELECT COALESCE(json_agg(oa ORDER BY oa.id), '[]') AS adjustments
+        FROM
+        (SELECT oa.id, oa.kind, oa.description, oa.item_id, oa.variant_id, oa.amount
+          FROM order_adjustments AS oa
+          WHERE oa.shop_id = o.shop_id AND oa.tab_id = o.tab_id AND oa.order_id = o.id) AS oa
+      ) AS adjustments,
+      t.total,
+      EXISTS(SELECT 1 FROM orders AS v
+             WHERE v.shop_id = o.shop_id AND v.tab_id = o.tab_id AND v.voids_order_id = o.id) AS is_voided,
+      (SELECT to_jsonb(lo) AS limit_override
+        FROM
+        (SELECT lo.user_id, lo.reason, lo.dollar_limit, lo.total, lo.created_at
+          FROM order_limit_overrides AS lo
+          WHERE lo.shop_id = o.shop_id AND lo.tab_id = o.tab_id AND lo.order_id = o.id) AS lo
+      ) AS limit_override
+    FROM orders AS o
+    JOIN order_totals AS t ON t.shop_id = o.shop_id AND t.tab_id = o.tab_id AND t.order_id = o.id
+    WHERE o.shop_id = @shopId AND o.tab_id = @tabId AND o.id = @orderId
+    FOR UPDATE OF o`,
+		pgx.NamedArgs{
+			"shopId":  shopId,
+			"tabId":   tabId,
+			"orderId": orderId,
+		})
+	if err != nil {
+		return models.Order{}, handlePgxError(err)
+	}
+
+	order, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[models.Order])
+	if err != nil {
+		return models.Order{}, handlePgxError(err)
+	}
+	return order, nil
+}
+
+// VoidOrder records an order reversing every line and adjustment of the given order on the same bill
+func (q *PgxQueries) VoidOrder(ctx context.Context, shopId int, tabId int, userId string, order *models.Order, reason string) (models.OrderOverview, error) {
+	return q.insertOrder(ctx, shopId, tabId, order.BillId, userId, order.LocationId, order.Customer, &order.Id, &reason, negateItemOrders(order.Items), negateAdjustments(order.Adjustments))
+}
+
+// insertOrder appends an order, its lines and its adjustments to the ledger
+func (q *PgxQueries) insertOrder(ctx context.Context, shopId int, tabId int, billId int, userId string, locationId *int, customer string, voidsOrderId *int, voidReason *string, items []models.ItemOrder, adjustments []models.OrderAdjustment) (models.OrderOverview, error) {
+	// Hold the bill open until the order is committed
+	var billStatus string
+	row := q.tx.QueryRow(ctx, `
+    SELECT status FROM tab_bills
+    WHERE shop_id = @shopId AND tab_id = @tabId AND id = @billId
+    FOR SHARE`,
+		pgx.NamedArgs{
+			"shopId": shopId,
+			"tabId":  tabId,
+			"billId": billId,
+		})
+	err := row.Scan(&billStatus)
+	if err != nil {
+		return models.OrderOverview{}, handlePgxError(err)
+	}
+
+	if billStatus != models.BILL_STATUS_OPEN.String() {
+		return models.OrderOverview{}, services.NewDataConflictServiceError(fmt.Errorf("Cannot change orders on %v bill", billStatus))
+	}
+
+	rows, err := q.tx.Query(ctx, `
+    INSERT INTO orders (shop_id, tab_id, bill_id, location_id, customer, user_id, voids_order_id, void_reason)
+    VALUES (@shopId, @tabId, @billId, @locationId, @customer, @userId, @voidsOrderId, @voidReason)
+    RETURNING id, bill_id, location_id, customer, user_id, created_at, voids_order_id, void_reason`,
+		pgx.NamedArgs{
+			"shopId":       shopId,
+			"tabId":        tabId,
+			"billId":       billId,
+			"locationId":   locationId,
+			"customer":     customer,
+			"userId":       userId,
+			"voidsOrderId": voidsOrderId,
+			"voidReason":   voidReason,
+		})
+	if err != nil {
+		return models.OrderOverview{}, handlePgxError(err)
+	}
+
+	order, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[models.OrderOverview])
+	if isForeignKeyViolation(err, "orders_shop_id_location_id_fkey") {
+		// The location was removed from the shop since the order was checked against the tab's locations
+		return models.OrderOverview{}, services.NewDataConflictServiceError(errors.New("Unknown location"))
+	}
+	if err != nil {
+		return models.OrderOverview{}, handlePgxError(err)
+	}
+
+	// Lines are numbered in the order they were submitted, so that voids reverse them line by line
+	type variantOrder struct {
+		models.ItemVariantOrder
+		itemId int
+		lineNo int
+	}
+
+	variantOrders := make([]variantOrder, 0)
+	for lineNo, i := range items {
+		for _, v := range i.Variants {
+			variantOrders = append(variantOrders, variantOrder{ItemVariantOrder: v, itemId: i.Id, lineNo: lineNo + 1})
+		}
+	}
+
+	_, err = q.tx.CopyFrom(ctx, pgx.Identifier{"order_items"},
+		[]string{"shop_id", "tab_id", "order_id", "line_no", "item_id", "quantity", "name", "unit_price"}, pgx.CopyFromSlice(len(items), func(i int) ([]any, error) {
+			return []any{shopId, tabId, order.Id, i + 1, items[i].Id, items[i].Quantity, items[i].Name, items[i].BasePrice}, nil
+		}))
+	if err != nil {
+		return models.OrderOverview{}, handlePgxError(err)
+	}
+
+	_, err = q.tx.CopyFrom(ctx, pgx.Identifier{"order_variants"},
+		[]string{"shop_id", "tab_id", "order_id", "line_no", "item_id", "variant_id", "quantity", "name", "unit_price"}, pgx.CopyFromSlice(len(variantOrders), func(i int) ([]any, error) {
+			v := variantOrders[i]
+			return []any{shopId, tabId, order.Id, v.lineNo, v.itemId, v.Id, v.Quantity, v.Name, v.Price}, nil
+		}))
+	if err != nil {
+		return models.OrderOverview{}, handlePgxError(err)
+	}
+
+	for _, adjustment := range adjustments {
+		_, err = q.tx.Exec(ctx, `
+    INSERT INTO order_adjustments (shop_id, tab_id, order_id, kind, description, item_id, variant_id, amount)
+    VALUES (@shopId, @tabId, @orderId, @kind, @description, @itemId, @variantId, @amount)`,
+			pgx.NamedArgs{
+				"shopId":      shopId,
+				"tabId":       tabId,
+				"orderId":     order.Id,
+				"kind":        adjustment.Kind,
+				"description": adjustment.Description,
+				"itemId":      adjustment.ItemId,
+				"variantId":   adjustment.VariantId,
+				"amount":      adjustment.Amount,
+			})
+		if err != nil {
+			return models.OrderOverview{}, handlePgxError(err)
+		}
+	}
+
+	return order, nil
+}
+
+func negateItemOrders(items []models.ItemOrder) []models.ItemOrder {
+	negated := make([]models.ItemOrder, 0, len(items))
+	for _, item := range items {
+		variants := make([]models.ItemVariantOrder, 0, len(item.Variants))
+		for _, variant := range item.Variants {
+			variant.Quantity = -variant.Quantity
+			variants = append(variants, variant)
+		}
+		item.Quantity = -item.Quantity
+		item.Variants = variants
+		negated = append(negated, item)
+	}
+	return negated
+}
+
+func negateAdjustments(adjustments []models.OrderAdjustment) []models.OrderAdjustment {
+	negated := make([]models.OrderAdjustment, 0, len(adjustments))
+	for _, adjustment := range adjustments {
+		adjustment.Amount = -adjustment.Amount
+		negated = append(negated, adjustment)
+	}
+	return negated
+}
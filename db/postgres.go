@@ -114,3 +114,9 @@ func handlePgxError(err error) error {
 	slog.Warn("Database operation failed", "err", err)
 	return services.NewInternalServiceError(err)
 }
+
+// isForeignKeyViolation reports whether err was caused by the foreign key constraint referencing a row which does not exist
+func isForeignKeyViolation(err error, constraint string) bool {
+	var pgerr *pgconn.PgError
+	return errors.As(err, &pgerr) && pgerr.Code == pgerrcode.ForeignKeyViolation && pgerr.ConstraintName == constraint
+}
@@ -0,0 +1,114 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/WilliamTrojniak/TabAppBackend/models"
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// testStore connects to the database named by TEST_DATABASE_URL, migrating it to the latest schema.
+// Tests which need a database are skipped when it is not set.
+func testStore(t *testing.T) *PgxStore {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	m, err := migrate.New("file://../cmd/migrate/migrations", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Up()
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		t.Fatal(err)
+	}
+	m.Close()
+
+	config, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewPostgresStorage(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(store.pool.Close)
+	return store
+}
+
+// createTestTab creates a tab with a new owner at a new shop in the time zone
+func createTestTab(t *testing.T, store *PgxStore, timeZone string, status models.TabStatus, startDate models.Date, endDate models.Date, billingIntervalDays int) (int, int) {
+	t.Helper()
+	ctx := context.Background()
+	type ids struct{ shopId, tabId int }
+	created, err := WithTxRet(ctx, store, func(q *PgxQueries) (ids, error) {
+		userId := uuid.NewString()
+		_, err := q.CreateUser(ctx, &models.UserCreate{Id: userId, Email: userId + "@example.com", Name: "Test User"})
+		if err != nil {
+			return ids{}, err
+		}
+
+		var shopId int
+		err = q.tx.QueryRow(ctx, `
+    INSERT INTO shops (owner_id, name, time_zone) VALUES (@userId, 'Test Shop', @timeZone) RETURNING id`,
+			pgx.NamedArgs{
+				"userId":   userId,
+				"timeZone": timeZone,
+			}).Scan(&shopId)
+		if err != nil {
+			return ids{}, err
+		}
+
+		_, err = q.tx.Exec(ctx, `INSERT INTO payment_methods (shop_id, method) VALUES ($1, 'in person')`, shopId)
+		if err != nil {
+			return ids{}, err
+		}
+
+		tabId, err := q.CreateTab(ctx, &models.TabCreate{
+			TabUpdate: models.TabUpdate{
+				TabBase: models.TabBase{
+					PaymentMethod:           "in person",
+					Organization:            "Test Organization",
+					DisplayName:             "Test Tab",
+					StartDate:               startDate,
+					EndDate:                 endDate,
+					DailyStartTime:          models.Time{Duration: 0},
+					DailyEndTime:            models.Time{Duration: 23 * time.Hour},
+					ActiveDaysOfWk:          127,
+					VerificationMethod:      "specify",
+					BillingIntervalDays:     billingIntervalDays,
+					BudgetWarningThresholds: []int{},
+				},
+				VerificationList: []string{},
+				LocationIds:      []int{},
+			},
+			ShopId:  shopId,
+			OwnerId: userId,
+		}, status)
+		return ids{shopId, tabId}, err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return created.shopId, created.tabId
+}
+
+func containsTab(tabs []TabKey, shopId int, tabId int) bool {
+	for _, tab := range tabs {
+		if tab.ShopId == shopId && tab.TabId == tabId {
+			return true
+		}
+	}
+	return false
+}
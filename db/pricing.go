@@ -0,0 +1,133 @@
+package db
+
+import (
+	"context"
+
+	"github.com/WilliamTrojniak/TabAppBackend/models"
+	"github.com/WilliamTrojniak/TabAppBackend/services"
+	"github.com/jackc/pgx/v5"
+)
+
+func (q *PgxQueries) GetTabPricing(ctx context.Context, shopId int, tabId int) (models.TabPricing, error) {
+	rows, err := q.tx.Query(ctx, `
+    SELECT tabs.discount_percent, tabs.is_tax_exempt,
+      (SELECT COALESCE(json_agg(o ORDER BY o.item_id, o.variant_id NULLS FIRST), '[]')
+       FROM (SELECT item_id, variant_id, price
+             FROM tab_price_overrides
+             WHERE tab_price_overrides.shop_id = tabs.shop_id AND tab_price_overrides.tab_id = tabs.id
+            ) AS o
+      ) AS price_overrides,
+      (SELECT COALESCE(json_agg(d ORDER BY d.category_id), '[]')
+       FROM (SELECT category_id, discount_percent
+             FROM tab_category_discounts
+             WHERE tab_category_discounts.shop_id = tabs.shop_id AND tab_category_discounts.tab_id = tabs.id
+            ) AS d
+      ) AS category_discounts
+    FROM tabs
+    WHERE tabs.shop_id = @shopId AND tabs.id = @tabId`,
+		pgx.NamedArgs{
+			"shopId": shopId,
+			"tabId":  tabId,
+		})
+	if err != nil {
+		return models.TabPricing{}, handlePgxError(err)
+	}
+
+	pricing, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[models.TabPricing])
+	if err != nil {
+		return models.TabPricing{}, handlePgxError(err)
+	}
+	return pricing, nil
+}
+
+// SetTabPricing replaces the pricing rules of the tab. Rules only apply to orders made after they are set.
+func (q *PgxQueries) SetTabPricing(ctx context.Context, shopId int, tabId int, data *models.TabPricing) error {
+	return q.WithTx(ctx, func(q *PgxQueries) error {
+		result, err := q.tx.Exec(ctx, `
+      UPDATE tabs SET (discount_percent, is_tax_exempt) = (@discountPercent, @isTaxExempt)
+      WHERE shop_id = @shopId AND id = @tabId`,
+			pgx.NamedArgs{
+				"shopId":          shopId,
+				"tabId":           tabId,
+				"discountPercent": data.DiscountPercent,
+				"isTaxExempt":     data.IsTaxExempt,
+			})
+		if err != nil {
+			return handlePgxError(err)
+		}
+
+		if result.RowsAffected() == 0 {
+			return services.NewNotFoundServiceError(nil)
+		}
+
+		_, err = q.tx.Exec(ctx, `
+      DELETE FROM tab_price_overrides WHERE shop_id = @shopId AND tab_id = @tabId`,
+			pgx.NamedArgs{
+				"shopId": shopId,
+				"tabId":  tabId,
+			})
+		if err != nil {
+			return handlePgxError(err)
+		}
+
+		_, err = q.tx.Exec(ctx, `
+      DELETE FROM tab_category_discounts WHERE shop_id = @shopId AND tab_id = @tabId`,
+			pgx.NamedArgs{
+				"shopId": shopId,
+				"tabId":  tabId,
+			})
+		if err != nil {
+			return handlePgxError(err)
+		}
+
+		_, err = q.tx.CopyFrom(ctx, pgx.Identifier{"tab_price_overrides"},
+			[]string{"shop_id", "tab_id", "item_id", "variant_id", "price"}, pgx.CopyFromSlice(len(data.PriceOverrides), func(i int) ([]any, error) {
+				o := data.PriceOverrides[i]
+				return []any{shopId, tabId, o.ItemId, o.VariantId, o.Price}, nil
+			}))
+		if err != nil {
+			return handlePgxError(err)
+		}
+
+		_, err = q.tx.CopyFrom(ctx, pgx.Identifier{"tab_category_discounts"},
+			[]string{"shop_id", "tab_id", "category_id", "discount_percent"}, pgx.CopyFromSlice(len(data.CategoryDiscounts), func(i int) ([]any, error) {
+				d := data.CategoryDiscounts[i]
+				return []any{shopId, tabId, d.CategoryId, d.DiscountPercent}, nil
+			}))
+		if err != nil {
+			return handlePgxError(err)
+		}
+
+		return nil
+	})
+}
+
+// GetItemCategoryIds maps the id of each of the items to the ids of the categories the item belongs to
+func (q *PgxQueries) GetItemCategoryIds(ctx context.Context, shopId int, itemIds []int) (map[int][]int, error) {
+	rows, err := q.tx.Query(ctx, `
+    SELECT item_id, item_category_id
+    FROM items_to_categories
+    WHERE shop_id = @shopId AND item_id = ANY (@itemIds)`,
+		pgx.NamedArgs{
+			"shopId":  shopId,
+			"itemIds": itemIds,
+		})
+	if err != nil {
+		return nil, handlePgxError(err)
+	}
+
+	type itemCategory struct {
+		ItemId     int `db:"item_id"`
+		CategoryId int `db:"item_category_id"`
+	}
+	pairs, err := pgx.CollectRows(rows, pgx.RowToStructByName[itemCategory])
+	if err != nil {
+		return nil, handlePgxError(err)
+	}
+
+	categoryIds := make(map[int][]int, len(itemIds))
+	for _, pair := range pairs {
+		categoryIds[pair.ItemId] = append(categoryIds[pair.ItemId], pair.CategoryId)
+	}
+	return categoryIds, nil
+}
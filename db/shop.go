@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"time"
 
 	"github.com/WilliamTrojniak/TabAppBackend/models"
 	"github.com/WilliamTrojniak/TabAppBackend/services"
@@ -11,10 +12,11 @@ import (
 func (q *PgxQueries) CreateShop(ctx context.Context, data *models.ShopCreate) error {
 	return q.WithTx(ctx, func(q *PgxQueries) error {
 		row := q.tx.QueryRow(ctx,
-			`INSERT INTO shops (owner_id, name) VALUES (@ownerId, @name) RETURNING id`,
+			`INSERT INTO shops (owner_id, name, time_zone) VALUES (@ownerId, @name, COALESCE(NULLIF(@timeZone, ''), 'UTC')) RETURNING id`,
 			pgx.NamedArgs{
-				"ownerId": data.OwnerId,
-				"name":    data.Name,
+				"ownerId":  data.OwnerId,
+				"name":     data.Name,
+				"timeZone": data.TimeZone,
 			})
 		var shopId int
 		err := row.Scan(&shopId)
@@ -95,10 +97,11 @@ func (q *PgxQueries) GetShopById(ctx context.Context, shopId int) (models.Shop,
 func (q *PgxQueries) UpdateShop(ctx context.Context, shopId int, data *models.ShopUpdate) error {
 	return q.WithTx(ctx, func(q *PgxQueries) error {
 		_, err := q.tx.Exec(ctx,
-			`UPDATE shops SET name = @name WHERE shops.id = @shopId`,
+			`UPDATE shops SET name = @name, time_zone = COALESCE(NULLIF(@timeZone, ''), time_zone) WHERE shops.id = @shopId`,
 			pgx.NamedArgs{
-				"name":   data.Name,
-				"shopId": shopId,
+				"name":     data.Name,
+				"timeZone": data.TimeZone,
+				"shopId":   shopId,
 			})
 		if err != nil {
 			return handlePgxError(err)
@@ -272,3 +275,22 @@ func (pq *PgxQueries) GetShopUsers(ctx context.Context, shopId int) ([]models.Sh
 
 	return users, nil
 }
+
+// GetShopDate returns the calendar date at the given instant in the shop's time zone
+func (q *PgxQueries) GetShopDate(ctx context.Context, shopId int, now time.Time) (models.Date, error) {
+	row := q.tx.QueryRow(ctx, `
+    SELECT (@now::timestamptz AT TIME ZONE shops.time_zone)::date
+    FROM shops
+    WHERE shops.id = @shopId`,
+		pgx.NamedArgs{
+			"shopId": shopId,
+			"now":    now,
+		})
+
+	var date models.Date
+	err := row.Scan(&date)
+	if err != nil {
+		return models.Date{}, handlePgxError(err)
+	}
+	return date, nil
+}
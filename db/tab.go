@@ -2,7 +2,8 @@ package db
 
 import (
 	"context"
-	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/WilliamTrojniak/TabAppBackend/models"
@@ -10,51 +11,81 @@ import (
 	"github.com/jackc/pgx/v5"
 )
 
-func (q *PgxQueries) CreateTab(ctx context.Context, data *models.TabCreate, status models.TabStatus) error {
-	return q.WithTx(ctx, func(q *PgxQueries) error {
+func (q *PgxQueries) CreateTab(ctx context.Context, data *models.TabCreate, status models.TabStatus) (int, error) {
+	return WithTxRet(ctx, q, func(q *PgxQueries) (int, error) {
 		row := q.tx.QueryRow(ctx, `
     INSERT INTO tabs 
       (shop_id, owner_id, payment_method, organization, display_name,
       start_date, end_date, daily_start_time, daily_end_time, active_days_of_wk,
-      dollar_limit_per_order, verification_method, payment_details, billing_interval_days, status) 
+      dollar_limit_per_order, verification_method, payment_details, billing_interval_days,
+      total_budget, budget_warning_thresholds, allow_tips, max_tip_percent, status) 
     VALUES (@shopId, @ownerId, @paymentMethod, @organization, @displayName,
             @startDate, @endDate, @dailyStartTime, @dailyEndTime, @activeDaysOfWk,
-            @dollarLimitPerOrder, @verificationMethod, @paymentDetails, @billingIntervalDays, @status)
+            @dollarLimitPerOrder, @verificationMethod, @paymentDetails, @billingIntervalDays,
+            @totalBudget, @budgetWarningThresholds, @allowTips, @maxTipPercent, @status)
     RETURNING id`,
 			pgx.NamedArgs{
-				"shopId":              data.ShopId,
-				"ownerId":             data.OwnerId,
-				"paymentMethod":       data.PaymentMethod,
-				"organization":        data.Organization,
-				"displayName":         data.DisplayName,
-				"startDate":           data.StartDate,
-				"endDate":             data.EndDate,
-				"dailyStartTime":      data.DailyStartTime,
-				"dailyEndTime":        data.DailyEndTime,
-				"activeDaysOfWk":      data.ActiveDaysOfWk,
-				"dollarLimitPerOrder": data.DollarLimitPerOrder,
-				"verificationMethod":  data.VerificationMethod,
-				"paymentDetails":      data.PaymentDetails,
-				"billingIntervalDays": data.BillingIntervalDays,
-				"status":              status,
+				"shopId":                  data.ShopId,
+				"ownerId":                 data.OwnerId,
+				"paymentMethod":           data.PaymentMethod,
+				"organization":            data.Organization,
+				"displayName":             data.DisplayName,
+				"startDate":               data.StartDate,
+				"endDate":                 data.EndDate,
+				"dailyStartTime":          data.DailyStartTime,
+				"dailyEndTime":            data.DailyEndTime,
+				"activeDaysOfWk":          data.ActiveDaysOfWk,
+				"dollarLimitPerOrder":     data.DollarLimitPerOrder,
+				"verificationMethod":      data.VerificationMethod,
+				"paymentDetails":          data.PaymentDetails,
+				"billingIntervalDays":     data.BillingIntervalDays,
+				"totalBudget":             data.TotalBudget,
+				"budgetWarningThresholds": data.BudgetWarningThresholds,
+				"allowTips":               data.AllowTips,
+				"maxTipPercent":           data.MaxTipPercent,
+				"status":                  status,
 			})
 
 		var tabId int
 		err := row.Scan(&tabId)
 		if err != nil {
-			return handlePgxError(err)
+			return 0, handlePgxError(err)
 		}
 
 		err = q.setTabUsers(ctx, data.ShopId, tabId, data.VerificationList)
 		if err != nil {
-			return err
+			return 0, err
 		}
 
 		err = q.setTabLocations(ctx, data.ShopId, tabId, data.LocationIds)
 		if err != nil {
-			return err
+			return 0, err
 		}
-		return nil
+		return tabId, nil
+	})
+}
+
+// RenewTab creates a tab for a new term, linked to the tab it was renewed from
+func (q *PgxQueries) RenewTab(ctx context.Context, renewedFromTabId int, data *models.TabCreate, status models.TabStatus) (int, error) {
+	return WithTxRet(ctx, q, func(q *PgxQueries) (int, error) {
+		tabId, err := q.CreateTab(ctx, data, status)
+		if err != nil {
+			return 0, err
+		}
+
+		_, err = q.tx.Exec(ctx, `
+    UPDATE tabs SET renewed_from_tab_id = @renewedFromTabId
+    WHERE shop_id = @shopId AND id = @tabId`,
+			pgx.NamedArgs{
+				"shopId":           data.ShopId,
+				"tabId":            tabId,
+				"renewedFromTabId": renewedFromTabId,
+			})
+		if err != nil {
+			return 0, handlePgxError(err)
+		}
+
+		return tabId, nil
 	})
 }
 
@@ -64,26 +95,32 @@ func (q *PgxQueries) UpdateTab(ctx context.Context, shopId int, tabId int, data
     UPDATE tabs SET
       (payment_method, organization, display_name,
       start_date, end_date, daily_start_time, daily_end_time, active_days_of_wk,
-      dollar_limit_per_order, verification_method, payment_details, billing_interval_days) 
+      dollar_limit_per_order, verification_method, payment_details, billing_interval_days,
+      total_budget, budget_warning_thresholds, allow_tips, max_tip_percent) 
     = (@paymentMethod, @organization, @displayName,
             @startDate, @endDate, @dailyStartTime, @dailyEndTime, @activeDaysOfWk,
-            @dollarLimitPerOrder, @verificationMethod, @paymentDetails, @billingIntervalDays)
+            @dollarLimitPerOrder, @verificationMethod, @paymentDetails, @billingIntervalDays,
+            @totalBudget, @budgetWarningThresholds, @allowTips, @maxTipPercent)
     WHERE id = @tabId AND shop_id = @shopId`,
 			pgx.NamedArgs{
-				"shopId":              shopId,
-				"tabId":               tabId,
-				"paymentMethod":       data.PaymentMethod,
-				"organization":        data.Organization,
-				"displayName":         data.DisplayName,
-				"startDate":           data.StartDate,
-				"endDate":             data.EndDate,
-				"dailyStartTime":      data.DailyStartTime,
-				"dailyEndTime":        data.DailyEndTime,
-				"activeDaysOfWk":      data.ActiveDaysOfWk,
-				"dollarLimitPerOrder": data.DollarLimitPerOrder,
-				"verificationMethod":  data.VerificationMethod,
-				"paymentDetails":      data.PaymentDetails,
-				"billingIntervalDays": data.BillingIntervalDays,
+				"shopId":                  shopId,
+				"tabId":                   tabId,
+				"paymentMethod":           data.PaymentMethod,
+				"organization":            data.Organization,
+				"displayName":             data.DisplayName,
+				"startDate":               data.StartDate,
+				"endDate":                 data.EndDate,
+				"dailyStartTime":          data.DailyStartTime,
+				"dailyEndTime":            data.DailyEndTime,
+				"activeDaysOfWk":          data.ActiveDaysOfWk,
+				"dollarLimitPerOrder":     data.DollarLimitPerOrder,
+				"verificationMethod":      data.VerificationMethod,
+				"paymentDetails":          data.PaymentDetails,
+				"billingIntervalDays":     data.BillingIntervalDays,
+				"totalBudget":             data.TotalBudget,
+				"budgetWarningThresholds": data.BudgetWarningThresholds,
+				"allowTips":               data.AllowTips,
+				"maxTipPercent":           data.MaxTipPercent,
 			})
 		if err != nil {
 			return handlePgxError(err)
@@ -117,7 +154,11 @@ func (q *PgxQueries) ApproveTab(ctx context.Context, shopId int, tabId int) erro
       dollar_limit_per_order = u.dollar_limit_per_order,
       verification_method = u.verification_method,
       payment_details = u.payment_details,
-      billing_interval_days = u.billing_interval_days
+      billing_interval_days = u.billing_interval_days,
+      total_budget = u.total_budget,
+      budget_warning_thresholds = u.budget_warning_thresholds,
+      allow_tips = u.allow_tips,
+      max_tip_percent = u.max_tip_percent
     FROM tab_updates AS u
     WHERE tabs.id = @tabId AND tabs.shop_id = @shopId 
       AND u.shop_id = tabs.shop_id AND u.tab_id = tabs.id`,
@@ -202,17 +243,163 @@ func (q *PgxQueries) ApproveTab(ctx context.Context, shopId int, tabId int) erro
 
 }
 
-func (q *PgxQueries) CloseTab(ctx context.Context, shopId int, tabId int) error {
+func (q *PgxQueries) CloseTab(ctx context.Context, shopId int, tabId int, now time.Time) error {
 	return q.WithTx(ctx, func(q *PgxQueries) error {
-		result, err := q.tx.Exec(ctx, `
+		today, err := q.GetShopDate(ctx, shopId, now)
+		if err != nil {
+			return err
+		}
+
+		return q.endTab(ctx, shopId, tabId, models.TAB_STATUS_CLOSED, today)
+	})
+}
+
+func (q *PgxQueries) RejectTab(ctx context.Context, shopId int, tabId int, now time.Time) error {
+	return q.WithTx(ctx, func(q *PgxQueries) error {
+		today, err := q.GetShopDate(ctx, shopId, now)
+		if err != nil {
+			return err
+		}
+
+		return q.endTab(ctx, shopId, tabId, models.TAB_STATUS_REJECTED, today)
+	})
+}
+
+// GetTabsDueExpiry returns the confirmed tabs whose end date has passed, and pending tabs which were not
+// approved before their start date, according to the current date in each shop's time zone. Tabs locked
+// by another transaction are skipped so that several instances may run concurrently.
+func (q *PgxQueries) GetTabsDueExpiry(ctx context.Context, now time.Time) ([]TabKey, error) {
+	return q.getTabsDueExpiry(ctx, now, nil, nil)
+}
+
+// ExpireTab ends the tab if it is due to expire, locking it until the transaction ends. It reports
+// false if the tab is no longer due or is locked by another transaction.
+func (q *PgxQueries) ExpireTab(ctx context.Context, shopId int, tabId int, now time.Time) (bool, error) {
+	return WithTxRet(ctx, q, func(q *PgxQueries) (bool, error) {
+		tabs, err := q.getTabsDueExpiry(ctx, now, &shopId, &tabId)
+		if err != nil || len(tabs) == 0 {
+			return false, err
+		}
+
+		err = q.endTab(ctx, shopId, tabId, models.TAB_STATUS_EXPIRED, tabs[0].Today)
+		if err != nil {
+			return false, err
+		}
+
+		err = q.AddTabEvent(ctx, shopId, tabId, models.TAB_COMMENT_KIND_EXPIRED, nil, nil)
+		if err != nil {
+			return false, err
+		}
+		return true, nil
+	})
+}
+
+func (q *PgxQueries) getTabsDueExpiry(ctx context.Context, now time.Time, shopId *int, tabId *int) ([]TabKey, error) {
+	rows, err := q.tx.Query(ctx, `
+    SELECT tabs.shop_id, tabs.id, shop_dates.today
+    FROM tabs
+    JOIN (SELECT shops.id, (@now::timestamptz AT TIME ZONE shops.time_zone)::date AS today FROM shops) AS shop_dates
+      ON shop_dates.id = tabs.shop_id
+    WHERE ((tabs.status = @confirmed AND tabs.end_date < shop_dates.today)
+      OR (tabs.status = @pending AND tabs.start_date < shop_dates.today))
+      AND (@tabId::INT IS NULL OR (tabs.shop_id = @shopId AND tabs.id = @tabId))
+    ORDER BY tabs.shop_id, tabs.id
+    FOR UPDATE OF tabs SKIP LOCKED`,
+		pgx.NamedArgs{
+			"confirmed": models.TAB_STATUS_CONFIRMED,
+			"pending":   models.TAB_STATUS_PENDING,
+			"now":       now,
+			"shopId":    shopId,
+			"tabId":     tabId,
+		})
+	if err != nil {
+		return nil, handlePgxError(err)
+	}
+
+	tabs, err := pgx.CollectRows(rows, pgx.RowToStructByName[TabKey])
+	if err != nil {
+		return nil, handlePgxError(err)
+	}
+	return tabs, nil
+}
+
+// endTab moves the tab to a status in which it can no longer be charged, discarding any pending updates.
+// Confirmed tabs are billed through the earlier of the last day and their end date, ending with a final bill.
+func (q *PgxQueries) endTab(ctx context.Context, shopId int, tabId int, status models.TabStatus, lastDay models.Date) error {
+	var previousStatus string
+	var endDate models.Date
+	row := q.tx.QueryRow(ctx, `
+    SELECT status, end_date FROM tabs
+    WHERE shop_id = @shopId AND id = @tabId
+    FOR UPDATE`,
+		pgx.NamedArgs{
+			"shopId": shopId,
+			"tabId":  tabId,
+		})
+	err := row.Scan(&previousStatus, &endDate)
+	if err != nil {
+		return handlePgxError(err)
+	}
+
+	if previousStatus == models.TAB_STATUS_CONFIRMED.String() {
+		if lastDay.After(endDate.Date) {
+			lastDay = endDate
+		}
+
+		err = q.finalizeTabBills(ctx, shopId, tabId, lastDay)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = q.tx.Exec(ctx, `
     UPDATE tabs SET
       status = @status
-    WHERE tabs.id = @tabId AND tabs.shop_id = @shopId 
-    `, pgx.NamedArgs{
+    WHERE tabs.id = @tabId AND tabs.shop_id = @shopId`,
+		pgx.NamedArgs{
+			"shopId": shopId,
+			"tabId":  tabId,
+			"status": status,
+		})
+	if err != nil {
+		return handlePgxError(err)
+	}
+
+	_, err = q.tx.Exec(ctx, `
+    DELETE FROM tab_updates
+    WHERE shop_id = @shopId AND tab_id = @tabId`,
+		pgx.NamedArgs{
+			"shopId": shopId,
+			"tabId":  tabId,
+		})
+	if err != nil {
+		return handlePgxError(err)
+	}
+
+	_, err = q.tx.Exec(ctx, `
+    DELETE FROM tab_update_locations
+    WHERE shop_id = @shopId AND tab_id = @tabId`,
+		pgx.NamedArgs{
 			"shopId": shopId,
 			"tabId":  tabId,
-			"status": models.TAB_STATUS_CLOSED,
 		})
+	if err != nil {
+		return handlePgxError(err)
+	}
+
+	return nil
+}
+
+// RejectTabUpdates discards the tab's pending updates, recording who rejected them and why
+func (q *PgxQueries) RejectTabUpdates(ctx context.Context, shopId int, tabId int, userId string, message *string) error {
+	return q.WithTx(ctx, func(q *PgxQueries) error {
+		result, err := q.tx.Exec(ctx, `
+    DELETE FROM tab_updates
+    WHERE shop_id = @shopId AND tab_id = @tabId`,
+			pgx.NamedArgs{
+				"shopId": shopId,
+				"tabId":  tabId,
+			})
 		if err != nil {
 			return handlePgxError(err)
 		}
@@ -222,7 +409,7 @@ func (q *PgxQueries) CloseTab(ctx context.Context, shopId int, tabId int) error
 		}
 
 		_, err = q.tx.Exec(ctx, `
-    DELETE FROM tab_updates
+    DELETE FROM tab_update_locations
     WHERE shop_id = @shopId AND tab_id = @tabId`,
 			pgx.NamedArgs{
 				"shopId": shopId,
@@ -232,31 +419,21 @@ func (q *PgxQueries) CloseTab(ctx context.Context, shopId int, tabId int) error
 			return handlePgxError(err)
 		}
 
-		return nil
-	})
-}
+		_, err = q.tx.Exec(ctx, `
+    INSERT INTO tab_update_rejections (shop_id, tab_id, message, rejected_by)
+    VALUES (@shopId, @tabId, @message, @userId)`,
+			pgx.NamedArgs{
+				"shopId":  shopId,
+				"tabId":   tabId,
+				"message": message,
+				"userId":  userId,
+			})
+		if err != nil {
+			return handlePgxError(err)
+		}
 
-func (q *PgxQueries) MarkTabBillPaid(ctx context.Context, shopId int, tabId int, billId int) error {
-	endDate := models.DateOf(time.Now())
-	println(endDate.String())
-	result, err := q.tx.Exec(ctx, `
-    UPDATE tab_bills 
-    SET (is_paid, end_date) = (true, @endDate)
-    WHERE shop_id = @shopId AND tab_id = @tabId AND id = @billId 
-    `, pgx.NamedArgs{
-		"shopId":  shopId,
-		"tabId":   tabId,
-		"billId":  billId,
-		"endDate": endDate,
+		return nil
 	})
-	if err != nil {
-		return handlePgxError(err)
-	}
-	if result.RowsAffected() == 0 {
-		return services.NewNotFoundServiceError(nil)
-	}
-
-	return nil
 }
 
 func (q *PgxQueries) SetTabUpdates(ctx context.Context, shopId int, tabId int, data *models.TabUpdate) error {
@@ -265,32 +442,40 @@ func (q *PgxQueries) SetTabUpdates(ctx context.Context, shopId int, tabId int, d
     INSERT INTO tab_updates 
       (shop_id, tab_id, payment_method, organization, display_name,
       start_date, end_date, daily_start_time, daily_end_time, active_days_of_wk,
-      dollar_limit_per_order, verification_method, payment_details, billing_interval_days) 
+      dollar_limit_per_order, verification_method, payment_details, billing_interval_days,
+      total_budget, budget_warning_thresholds, allow_tips, max_tip_percent) 
     VALUES (@shopId, @tabId, @paymentMethod, @organization, @displayName,
             @startDate, @endDate, @dailyStartTime, @dailyEndTime, @activeDaysOfWk,
-            @dollarLimitPerOrder, @verificationMethod, @paymentDetails, @billingIntervalDays)
+            @dollarLimitPerOrder, @verificationMethod, @paymentDetails, @billingIntervalDays,
+            @totalBudget, @budgetWarningThresholds, @allowTips, @maxTipPercent)
     ON CONFLICT (shop_id, tab_id) DO UPDATE SET
       (payment_method, organization, display_name,
       start_date, end_date, daily_start_time, daily_end_time, active_days_of_wk,
-      dollar_limit_per_order, verification_method, payment_details, billing_interval_days) 
+      dollar_limit_per_order, verification_method, payment_details, billing_interval_days,
+      total_budget, budget_warning_thresholds, allow_tips, max_tip_percent) 
     = (excluded.payment_method, excluded.organization, excluded.display_name,
       excluded.start_date, excluded.end_date, excluded.daily_start_time, excluded.daily_end_time, excluded.active_days_of_wk,
-      excluded.dollar_limit_per_order, excluded.verification_method, excluded.payment_details, excluded.billing_interval_days)`,
+      excluded.dollar_limit_per_order, excluded.verification_method, excluded.payment_details, excluded.billing_interval_days,
+      excluded.total_budget, excluded.budget_warning_thresholds, excluded.allow_tips, excluded.max_tip_percent)`,
 			pgx.NamedArgs{
-				"shopId":              shopId,
-				"tabId":               tabId,
-				"paymentMethod":       data.PaymentMethod,
-				"organization":        data.Organization,
-				"displayName":         data.DisplayName,
-				"startDate":           data.StartDate,
-				"endDate":             data.EndDate,
-				"dailyStartTime":      data.DailyStartTime,
-				"dailyEndTime":        data.DailyEndTime,
-				"activeDaysOfWk":      data.ActiveDaysOfWk,
-				"dollarLimitPerOrder": data.DollarLimitPerOrder,
-				"verificationMethod":  data.VerificationMethod,
-				"paymentDetails":      data.PaymentDetails,
-				"billingIntervalDays": data.BillingIntervalDays,
+				"shopId":                  shopId,
+				"tabId":                   tabId,
+				"paymentMethod":           data.PaymentMethod,
+				"organization":            data.Organization,
+				"displayName":             data.DisplayName,
+				"startDate":               data.StartDate,
+				"endDate":                 data.EndDate,
+				"dailyStartTime":          data.DailyStartTime,
+				"dailyEndTime":            data.DailyEndTime,
+				"activeDaysOfWk":          data.ActiveDaysOfWk,
+				"dollarLimitPerOrder":     data.DollarLimitPerOrder,
+				"verificationMethod":      data.VerificationMethod,
+				"paymentDetails":          data.PaymentDetails,
+				"billingIntervalDays":     data.BillingIntervalDays,
+				"totalBudget":             data.TotalBudget,
+				"budgetWarningThresholds": data.BudgetWarningThresholds,
+				"allowTips":               data.AllowTips,
+				"maxTipPercent":           data.MaxTipPercent,
 			})
 		if err != nil {
 			return handlePgxError(err)
@@ -306,17 +491,63 @@ func (q *PgxQueries) SetTabUpdates(ctx context.Context, shopId int, tabId int, d
 			return err
 		}
 
-		err = q.Commit(ctx)
-		if err != nil {
-			return handlePgxError(err)
-		}
-
 		return nil
 	})
 }
 
-func (q *PgxQueries) GetTabs(ctx context.Context, shopId int) ([]models.TabOverview, error) {
-	rows, err := q.tx.Query(ctx, `
+// likeEscaper escapes the wildcards in text matched with LIKE
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// tabSortColumns maps each way tabs may be sorted to the column and type it is sorted by
+var tabSortColumns = map[string]struct {
+	column string
+	cast   string
+}{
+	models.TabSortDisplayName:  {"tabs.display_name", "TEXT"},
+	models.TabSortOrganization: {"tabs.organization", "TEXT"},
+	models.TabSortStartDate:    {"tabs.start_date", "DATE"},
+	models.TabSortEndDate:      {"tabs.end_date", "DATE"},
+}
+
+// GetTabs returns a page of the shop's tabs matching the filters, along with the cursor for the next page
+// if there are more tabs. Tabs are ordered by the sort column and then by id, so that the cursor can
+// resume exactly after the last tab of the page.
+func (q *PgxQueries) GetTabs(ctx context.Context, shopId int, params *models.GetTabsQueryParams) ([]models.TabOverview, *models.TabsCursor, error) {
+	if params == nil {
+		return nil, nil, services.NewInternalServiceError(nil)
+	}
+
+	sort, ok := tabSortColumns[params.Sort]
+	if !ok {
+		return nil, nil, services.NewInternalServiceError(fmt.Errorf("Unknown tab sort %q", params.Sort))
+	}
+	direction, comparison := "ASC", ">"
+	if params.Descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	var cursorValue *string
+	var cursorId *int
+	if params.Cursor != nil {
+		cursorValue = &params.Cursor.Value
+		cursorId = &params.Cursor.Id
+	}
+
+	// Without a limit every tab is returned on one page
+	var limit *int
+	if params.Limit > 0 {
+		// One extra tab is fetched to tell whether there is a next page
+		l := params.Limit + 1
+		limit = &l
+	}
+
+	var search *string
+	if params.Search != nil {
+		pattern := "%" + likeEscaper.Replace(*params.Search) + "%"
+		search = &pattern
+	}
+
+	rows, err := q.tx.Query(ctx, fmt.Sprintf(`
     SELECT 
       tabs.*, 
       (SELECT to_jsonb(tab_updates) as pending_updates
@@ -324,19 +555,27 @@ func (q *PgxQueries) GetTabs(ctx context.Context, shopId int) ([]models.TabOverv
              COALESCE(json_agg(locations.*) FILTER (WHERE locations.id IS NOT NULL), '[]') AS locations
              FROM tab_updates
              LEFT JOIN tab_update_locations ON tab_updates.shop_id = tab_update_locations.shop_id
-               AND tab_updates.tab_id = tab_update_locations.shop_id
+               AND tab_updates.tab_id = tab_update_locations.tab_id
              LEFT JOIN locations ON locations.shop_id = tabs.shop_id AND locations.id = tab_update_locations.location_id
              WHERE tab_updates.shop_id = tabs.shop_id AND tab_updates.tab_id = tabs.id
              GROUP BY tab_updates.shop_id, tab_updates.tab_id
             ) AS tab_updates
       ) AS pending_updates,
       array_remove(array_agg(tab_users.email), null) as verification_list,
+      (SELECT COALESCE(array_agg(tab_managers.email ORDER BY tab_managers.email), '{}')
+       FROM tab_managers
+       WHERE tab_managers.shop_id = tabs.shop_id AND tab_managers.tab_id = tabs.id
+      ) AS managers,
       EXISTS(
-        SELECT tab_bills.id
-        FROM tab_bills
-        WHERE tab_bills.shop_id = tabs.shop_id AND tab_bills.tab_id = tabs.id AND tab_bills.is_paid = FALSE
+        SELECT b.bill_id
+        FROM tab_bill_balances AS b
+        WHERE b.shop_id = tabs.shop_id AND b.tab_id = tabs.id AND b.outstanding > 0
         LIMIT 1
       ) as is_pending_balance,
+      (SELECT CASE WHEN tabs.total_budget > 0 THEN (tabs.total_budget - s.spent)::REAL END
+       FROM tab_spend AS s
+       WHERE s.shop_id = tabs.shop_id AND s.tab_id = tabs.id
+      ) AS remaining_budget,
       (SELECT COALESCE(json_agg(locations.*) FILTER (WHERE locations.id IS NOT NULL), '[]') AS locations
        FROM locations
        LEFT JOIN tab_locations ON tab_locations.shop_id = locations.shop_id AND tab_locations.location_id = locations.id
@@ -345,39 +584,76 @@ func (q *PgxQueries) GetTabs(ctx context.Context, shopId int) ([]models.TabOverv
     FROM tabs
     LEFT JOIN tab_users ON tabs.shop_id = tab_users.shop_id AND tabs.id = tab_users.tab_id
     WHERE tabs.shop_id = @shopId
+      AND (@statuses::TEXT[] IS NULL OR tabs.status::TEXT = ANY(@statuses::TEXT[]))
+      AND (@organization::TEXT IS NULL OR lower(tabs.organization) = lower(@organization::TEXT))
+      AND (@paymentMethod::TEXT IS NULL OR tabs.payment_method::TEXT = @paymentMethod::TEXT)
+      AND (@activeFrom::DATE IS NULL OR tabs.end_date >= @activeFrom::DATE)
+      AND (@activeTo::DATE IS NULL OR tabs.start_date <= @activeTo::DATE)
+      AND (@ownerId::TEXT IS NULL OR tabs.owner_id = @ownerId::TEXT)
+      AND (@search::TEXT IS NULL OR tabs.display_name ILIKE @search::TEXT OR tabs.organization ILIKE @search::TEXT)
+      AND (@isPendingBalance::BOOLEAN IS NULL OR @isPendingBalance::BOOLEAN = EXISTS(
+        SELECT 1 FROM tab_bill_balances AS b
+        WHERE b.shop_id = tabs.shop_id AND b.tab_id = tabs.id AND b.outstanding > 0))
+      AND (@hasPendingUpdates::BOOLEAN IS NULL OR @hasPendingUpdates::BOOLEAN = EXISTS(
+        SELECT 1 FROM tab_updates
+        WHERE tab_updates.shop_id = tabs.shop_id AND tab_updates.tab_id = tabs.id))
+      AND (@cursorId::INT IS NULL OR (%[1]v, tabs.id) %[3]v (@cursorValue::%[2]v, @cursorId::INT))
     GROUP BY tabs.shop_id, tabs.id
-    ORDER BY tabs.display_name, tabs.start_date, tabs.end_date 
-    `,
+    ORDER BY %[1]v %[4]v, tabs.id %[4]v
+    LIMIT @limit
+    `, sort.column, sort.cast, comparison, direction),
 		pgx.NamedArgs{
-			"shopId": shopId,
+			"shopId":            shopId,
+			"statuses":          params.Statuses,
+			"organization":      params.Organization,
+			"paymentMethod":     params.PaymentMethod,
+			"activeFrom":        params.ActiveFrom,
+			"activeTo":          params.ActiveTo,
+			"ownerId":           params.OwnerId,
+			"search":            search,
+			"isPendingBalance":  params.IsPendingBalance,
+			"hasPendingUpdates": params.HasPendingUpdates,
+			"cursorValue":       cursorValue,
+			"cursorId":          cursorId,
+			"limit":             limit,
 		})
 
 	if err != nil {
-		return nil, handlePgxError(err)
+		return nil, nil, handlePgxError(err)
 	}
 
 	tabs, err := pgx.CollectRows(rows, pgx.RowToStructByNameLax[models.TabOverview])
 	if err != nil {
-		return nil, handlePgxError(err)
+		return nil, nil, handlePgxError(err)
 	}
-	return tabs, nil
+
+	if params.Limit == 0 || len(tabs) <= params.Limit {
+		return tabs, nil, nil
+	}
+
+	tabs = tabs[:params.Limit]
+	return tabs, models.TabsCursorAfter(&tabs[len(tabs)-1], params.Sort, params.Descending), nil
 }
 
 func (q *PgxQueries) GetTabById(ctx context.Context, shopId int, tabId int) (models.Tab, error) {
 	rows, err := q.tx.Query(ctx, `
     SELECT tabs.*, 
       EXISTS(
-        SELECT tab_bills.id
-        FROM tab_bills
-        WHERE tab_bills.shop_id = tabs.shop_id AND tab_bills.tab_id = tabs.id AND tab_bills.is_paid = FALSE
+        SELECT b.bill_id
+        FROM tab_bill_balances AS b
+        WHERE b.shop_id = tabs.shop_id AND b.tab_id = tabs.id AND b.outstanding > 0
         LIMIT 1
       ) as is_pending_balance,
+      (SELECT CASE WHEN tabs.total_budget > 0 THEN (tabs.total_budget - s.spent)::REAL END
+       FROM tab_spend AS s
+       WHERE s.shop_id = tabs.shop_id AND s.tab_id = tabs.id
+      ) AS remaining_budget,
       (SELECT to_jsonb(tab_updates) as pending_updates
        FROM (SELECT tab_updates.*, 
              COALESCE(json_agg(locations.*) FILTER (WHERE locations.id IS NOT NULL), '[]') AS locations
              FROM tab_updates
              LEFT JOIN tab_update_locations ON tab_updates.shop_id = tab_update_locations.shop_id
-               AND tab_updates.tab_id = tab_update_locations.shop_id
+               AND tab_updates.tab_id = tab_update_locations.tab_id
              LEFT JOIN locations ON locations.shop_id = tabs.shop_id AND locations.id = tab_update_locations.location_id
              WHERE tab_updates.shop_id = tabs.shop_id AND tab_updates.tab_id = tabs.id
              GROUP BY tab_updates.shop_id, tab_updates.tab_id
@@ -393,27 +669,100 @@ func (q *PgxQueries) GetTabById(ctx context.Context, shopId int, tabId int) (mod
         (SELECT tab_bills.*, 
           (SELECT COALESCE(json_agg(items) FILTER (WHERE items.id IS NOT NULL), '[]') AS items
             FROM
-            (SELECT items.*, oi.quantity,
+            (SELECT oi.item_id AS id, oi.name, oi.unit_price AS base_price, oi.quantity, oi.location_id, oi.customer,
               (SELECT COALESCE(json_agg(variants) FILTER (WHERE variants.id IS NOT NULL), '[]') AS variants
                 FROM
-                (SELECT iv.*, ov.quantity
-                  FROM order_variants AS ov
-                  LEFT JOIN item_variants AS iv ON ov.shop_id = iv.shop_id AND iv.item_id = ov.item_id AND iv.id = ov.variant_id
-                  WHERE ov.shop_id = oi.shop_id AND ov.tab_id = oi.tab_id AND ov.bill_id = oi.bill_id AND ov.item_id = oi.item_id) AS variants
+                (SELECT ov.variant_id AS id, ov.name, ov.unit_price AS price, ov.quantity
+                  FROM bill_order_variants AS ov
+                  WHERE ov.shop_id = oi.shop_id AND ov.tab_id = oi.tab_id AND ov.bill_id = oi.bill_id
+                    AND ov.item_id = oi.item_id AND ov.item_unit_price = oi.unit_price
+                    AND ov.location_id IS NOT DISTINCT FROM oi.location_id
+                    AND ov.customer = oi.customer AND ov.quantity <> 0) AS variants
             ) AS variants
-              FROM order_items AS oi
-              LEFT JOIN items ON items.shop_id = oi.shop_id AND items.id = oi.item_id
-              WHERE oi.shop_id = tab_bills.shop_id AND oi.tab_id = tab_bills.tab_id AND oi.bill_id = tab_bills.id) AS items
-          ) AS items
+              FROM bill_order_items AS oi
+              WHERE oi.shop_id = tab_bills.shop_id AND oi.tab_id = tab_bills.tab_id AND oi.bill_id = tab_bills.id
+                AND oi.quantity <> 0) AS items
+          ) AS items,
+          (SELECT COALESCE(json_agg(a ORDER BY a.kind, a.description), '[]') AS adjustments
+            FROM
+            (SELECT ba.kind, ba.description, ba.amount
+              FROM bill_order_adjustments AS ba
+              WHERE ba.shop_id = tab_bills.shop_id AND ba.tab_id = tab_bills.tab_id AND ba.bill_id = tab_bills.id
+                AND ba.amount <> 0) AS a
+          ) AS adjustments,
+          (SELECT COALESCE(json_agg(orders ORDER BY orders.created_at, orders.id) FILTER (WHERE orders.id IS NOT NULL), '[]') AS orders
+            FROM
+            (SELECT o.id, o.bill_id, o.location_id, o.customer, o.user_id, o.created_at, o.voids_order_id, o.void_reason,
+              (SELECT COALESCE(json_agg(items ORDER BY items.line_no) FILTER (WHERE items.id IS NOT NULL), '[]') AS items
+                FROM
+                (SELECT oi.line_no, oi.item_id AS id, oi.name, oi.unit_price AS base_price, oi.quantity, o.location_id, o.customer,
+                  (SELECT COALESCE(json_agg(variants) FILTER (WHERE variants.id IS NOT NULL), '[]') AS variants
+                    FROM
+                    (SELECT ov.variant_id AS id, ov.name, ov.unit_price AS price, ov.quantity
+                      FROM order_variants AS ov
+                      WHERE ov.shop_id = oi.shop_id AND ov.tab_id = oi.tab_id AND ov.order_id = oi.order_id
+                        AND ov.line_no = oi.line_no) AS variants
+                  ) AS variants
+                  FROM order_items AS oi
+                  WHERE oi.shop_id = o.shop_id AND oi.tab_id = o.tab_id AND oi.order_id = o.id) AS items
+              ) AS items,
+              (SELECT COALESCE(json_agg(oa ORDER BY oa.id), '[]') AS adjustments
+                FROM
+                (SELECT oa.id, oa.kind, oa.description, oa.item_id, oa.variant_id, oa.amount
+                  FROM order_adjustments AS oa
+                  WHERE oa.shop_id = o.shop_id AND oa.tab_id = o.tab_id AND oa.order_id = o.id) AS oa
+              ) AS adjustments,
+              t.total,
+              EXISTS(SELECT 1 FROM orders AS v
+                     WHERE v.shop_id = o.shop_id AND v.tab_id = o.tab_id AND v.voids_order_id = o.id) AS is_voided,
+              (SELECT to_jsonb(lo) AS limit_override
+                FROM
+                (SELECT lo.user_id, lo.reason, lo.dollar_limit, lo.total, lo.created_at
+                  FROM order_limit_overrides AS lo
+                  WHERE lo.shop_id = o.shop_id AND lo.tab_id = o.tab_id AND lo.order_id = o.id) AS lo
+              ) AS limit_override
+              FROM orders AS o
+              JOIN order_totals AS t ON t.shop_id = o.shop_id AND t.tab_id = o.tab_id AND t.order_id = o.id
+              WHERE o.shop_id = tab_bills.shop_id AND o.tab_id = tab_bills.tab_id AND o.bill_id = tab_bills.id) AS orders
+          ) AS orders,
+          b.total,
+          b.amount_paid,
+          b.outstanding,
+          (SELECT COALESCE(json_agg(p ORDER BY p.paid_on, p.id) FILTER (WHERE p.id IS NOT NULL), '[]') AS payments
+            FROM
+            (SELECT bill_payments.id, bill_payments.amount, bill_payments.method, bill_payments.reference,
+              bill_payments.paid_on, bill_payments.recorded_by, bill_payments.recorded_at
+              FROM bill_payments
+              WHERE bill_payments.shop_id = tab_bills.shop_id AND bill_payments.tab_id = tab_bills.tab_id
+                AND bill_payments.bill_id = tab_bills.id) AS p
+          ) AS payments
           FROM tab_bills
+          JOIN tab_bill_balances AS b ON b.shop_id = tab_bills.shop_id AND b.tab_id = tab_bills.tab_id AND b.bill_id = tab_bills.id
           WHERE tab_bills.shop_id = tabs.shop_id AND tab_bills.tab_id = tabs.id
-          GROUP BY tab_bills.shop_id, tab_bills.tab_id, tab_bills.id
           ) as tab_bills
       ) AS bills,
       (SELECT array_remove(array_agg(tab_users.email), null)
        FROM tab_users
        WHERE tab_users.shop_id = tabs.shop_id AND tab_users.tab_id = tabs.id
-      ) AS verification_list
+      ) AS verification_list,
+      (SELECT COALESCE(array_agg(tab_managers.email ORDER BY tab_managers.email), '{}')
+       FROM tab_managers
+       WHERE tab_managers.shop_id = tabs.shop_id AND tab_managers.tab_id = tabs.id
+      ) AS managers,
+      (SELECT to_jsonb(r) AS last_update_rejection
+       FROM (SELECT message, rejected_by, rejected_at
+             FROM tab_update_rejections
+             WHERE tab_update_rejections.shop_id = tabs.shop_id AND tab_update_rejections.tab_id = tabs.id
+             ORDER BY rejected_at DESC, id DESC
+             LIMIT 1
+            ) AS r
+      ) AS last_update_rejection,
+      (SELECT to_jsonb(t) AS pending_ownership_transfer
+       FROM (SELECT email, requested_by, requested_at
+             FROM tab_ownership_transfers
+             WHERE tab_ownership_transfers.shop_id = tabs.shop_id AND tab_ownership_transfers.tab_id = tabs.id
+            ) AS t
+      ) AS pending_ownership_transfer
     FROM tabs
     WHERE tabs.shop_id = @shopId AND tabs.id = @tabId
     GROUP BY tabs.shop_id, tabs.id`,
@@ -433,6 +782,36 @@ func (q *PgxQueries) GetTabById(ctx context.Context, shopId int, tabId int) (mod
 	return tab, nil
 }
 
+// GetTabSpendForUpdate returns the total spend on a tab, locking the tab so that concurrent orders
+// are checked against its budget one at a time.
+func (q *PgxQueries) GetTabSpendForUpdate(ctx context.Context, shopId int, tabId int) (float32, error) {
+	_, err := q.tx.Exec(ctx, `
+    SELECT 1 FROM tabs
+    WHERE shop_id = @shopId AND id = @tabId
+    FOR UPDATE`,
+		pgx.NamedArgs{
+			"shopId": shopId,
+			"tabId":  tabId,
+		})
+	if err != nil {
+		return 0, handlePgxError(err)
+	}
+
+	var spent float32
+	row := q.tx.QueryRow(ctx, `
+    SELECT spent FROM tab_spend
+    WHERE shop_id = @shopId AND tab_id = @tabId`,
+		pgx.NamedArgs{
+			"shopId": shopId,
+			"tabId":  tabId,
+		})
+	err = row.Scan(&spent)
+	if err != nil {
+		return 0, handlePgxError(err)
+	}
+	return spent, nil
+}
+
 func (q *PgxQueries) SetTabUsers(ctx context.Context, shopId int, tabId int, emails []string) error {
 	return q.WithTx(ctx, func(q *PgxQueries) error {
 		return q.setTabUsers(ctx, shopId, tabId, emails)
@@ -541,200 +920,125 @@ func (q *PgxQueries) SetTabUpdateLocations(ctx context.Context, shopId int, tabI
 	return nil
 }
 
-func (q *PgxQueries) insertBill(ctx context.Context, shopId int, tabId int, startDate models.Date, endDate models.Date) (int, error) {
-	var billId int
-	println("start ", startDate.String(), "end", endDate.String())
-
-	row := q.tx.QueryRow(ctx, `
-    INSERT INTO tab_bills 
-    (shop_id, tab_id, start_date, end_date) VALUES (@shopId, @tabId, @startDate, @endDate) RETURNING id
-    `, pgx.NamedArgs{
-		"shopId":    shopId,
-		"tabId":     tabId,
-		"startDate": startDate,
-		"endDate":   endDate,
-	})
-	err := row.Scan(&billId)
+func (q *PgxQueries) AddTabManager(ctx context.Context, shopId int, tabId int, email string, addedBy string) error {
+	_, err := q.tx.Exec(ctx, `
+    INSERT INTO tab_managers (shop_id, tab_id, email, added_by)
+    VALUES (@shopId, @tabId, lower(@email), @addedBy)
+    ON CONFLICT (shop_id, tab_id, email) DO NOTHING`,
+		pgx.NamedArgs{
+			"shopId":  shopId,
+			"tabId":   tabId,
+			"email":   email,
+			"addedBy": addedBy,
+		})
 	if err != nil {
-		return 0, handlePgxError(err)
+		return handlePgxError(err)
 	}
-
-	return billId, nil
+	return nil
 }
 
-func (q *PgxQueries) getTargetBill(ctx context.Context, shopId int, tabId int) (int, error) {
-	// TODO: Implement and change to get tab overview by id
-	tab, err := q.GetTabById(ctx, shopId, tabId)
-	if err != nil {
-		return 0, err
-	}
-
-	rows, _ := q.tx.Query(ctx, `
-    SELECT b.id, b.start_date, b.end_date, b.is_paid
-    FROM tab_bills b
-    LEFT JOIN tabs ON b.shop_id = tabs.shop_id AND b.tab_id = tabs.id
-    WHERE b.shop_id = @shopId AND b.tab_id = @tabId
-    ORDER BY is_paid, end_date DESC
-    `,
+func (q *PgxQueries) RemoveTabManager(ctx context.Context, shopId int, tabId int, email string) error {
+	result, err := q.tx.Exec(ctx, `
+    DELETE FROM tab_managers
+    WHERE shop_id = @shopId AND tab_id = @tabId AND email = lower(@email)`,
 		pgx.NamedArgs{
 			"shopId": shopId,
 			"tabId":  tabId,
+			"email":  email,
 		})
-
-	bill, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.BillOverview])
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			endDate := tab.EndDate
-			if tab.StartDate.AddDays(tab.BillingIntervalDays).Before(endDate.Date) {
-				endDate = models.Date{Date: tab.StartDate.AddDays(tab.BillingIntervalDays - 1)}
-			}
-
-			id, err := q.insertBill(ctx, shopId, tabId, tab.StartDate, endDate)
-			if err != nil {
-				return 0, handlePgxError(err)
-			}
-			return id, nil
-		}
-
-		return 0, handlePgxError(err)
-	}
-
-	now := time.Now()
-	today := models.DateOf(now)
-
-	if !bill.StartDate.After(today.Date) && !bill.EndDate.Before(today.Date) && !bill.IsPaid {
-		return bill.Id, nil
-	}
-
-	endDate := tab.EndDate
-	if bill.EndDate.AddDays(tab.BillingIntervalDays - 1).Before(endDate.Date) {
-		endDate = models.Date{Date: bill.EndDate.AddDays(tab.BillingIntervalDays - 1)}
+		return handlePgxError(err)
 	}
 
-	id, err := q.insertBill(ctx, shopId, tabId, bill.EndDate, endDate)
-	if err != nil {
-		return 0, handlePgxError(err)
+	if result.RowsAffected() == 0 {
+		return services.NewNotFoundServiceError(nil)
 	}
-	return id, nil
-
+	return nil
 }
 
-func (q *PgxQueries) AddOrderToTab(ctx context.Context, shopId int, tabId int, data *models.BillOrderCreate) error {
-	err := q.updateTabOrders(ctx, func(tx pgx.Tx) error {
-		_, err := tx.Exec(ctx, `
-    INSERT INTO order_items SELECT * FROM _temp_upsert_order_items ON CONFLICT (shop_id, tab_id, bill_id, item_id) DO UPDATE
-    SET quantity = order_items.quantity + excluded.quantity`)
-		if err != nil {
-			return handlePgxError(err)
-		}
-		_, err = tx.Exec(ctx, `
-	   INSERT INTO order_variants SELECT * FROM _temp_upsert_order_variants ON CONFLICT (shop_id, tab_id, bill_id, item_id, variant_id) DO UPDATE
-	   SET quantity = order_variants.quantity + excluded.quantity`)
-		if err != nil {
-			return handlePgxError(err)
-		}
-		return nil
-	}, shopId, tabId, data)
+// RequestTabOwnershipTransfer records a transfer of the tab to the user with the email, replacing any
+// transfer which is still pending
+func (q *PgxQueries) RequestTabOwnershipTransfer(ctx context.Context, shopId int, tabId int, email string, requestedBy string) error {
+	_, err := q.tx.Exec(ctx, `
+    INSERT INTO tab_ownership_transfers (shop_id, tab_id, email, requested_by)
+    VALUES (@shopId, @tabId, lower(@email), @requestedBy)
+    ON CONFLICT (shop_id, tab_id) DO UPDATE SET
+      email = excluded.email,
+      requested_by = excluded.requested_by,
+      requested_at = excluded.requested_at`,
+		pgx.NamedArgs{
+			"shopId":      shopId,
+			"tabId":       tabId,
+			"email":       email,
+			"requestedBy": requestedBy,
+		})
 	if err != nil {
-		return err
+		return handlePgxError(err)
 	}
-
 	return nil
 }
 
-func (q *PgxQueries) RemoveOrderFromTab(ctx context.Context, shopId int, tabId int, data *models.BillOrderCreate) error {
-	err := q.updateTabOrders(ctx, func(tx pgx.Tx) error {
-		_, err := tx.Exec(ctx, `
-      UPDATE order_items SET
-        quantity = order_items.quantity - u.quantity
-      FROM _temp_upsert_order_items AS u
-      WHERE order_items.shop_id = u.shop_id
-        AND order_items.tab_id = u.tab_id 
-        AND order_items.bill_id = u.bill_id 
-        AND order_items.item_id = u.item_id`)
-
-		if err != nil {
-			return handlePgxError(err)
-		}
-		_, err = tx.Exec(ctx, `
-      UPDATE order_variants SET
-        quantity = order_variants.quantity - u.quantity
-      FROM _temp_upsert_order_variants AS u
-      WHERE order_variants.shop_id = u.shop_id
-        AND order_variants.tab_id = u.tab_id 
-        AND order_variants.bill_id = u.bill_id 
-        AND order_variants.item_id = u.item_id
-        AND order_variants.variant_id = u.variant_id`)
-		if err != nil {
-			return handlePgxError(err)
-		}
-		return nil
-	}, shopId, tabId, data)
+func (q *PgxQueries) CancelTabOwnershipTransfer(ctx context.Context, shopId int, tabId int) error {
+	result, err := q.tx.Exec(ctx, `
+    DELETE FROM tab_ownership_transfers
+    WHERE shop_id = @shopId AND tab_id = @tabId`,
+		pgx.NamedArgs{
+			"shopId": shopId,
+			"tabId":  tabId,
+		})
 	if err != nil {
-		return err
+		return handlePgxError(err)
 	}
 
+	if result.RowsAffected() == 0 {
+		return services.NewNotFoundServiceError(nil)
+	}
 	return nil
 }
 
-func (q *PgxQueries) updateTabOrders(ctx context.Context, updateFn func(pgx.Tx) error, shopId int, tabId int, data *models.BillOrderCreate) error {
+// AcceptTabOwnershipTransfer makes the user the owner of the tab if its pending transfer was requested for
+// the user's email. The new owner no longer needs to be a manager of the tab.
+func (q *PgxQueries) AcceptTabOwnershipTransfer(ctx context.Context, shopId int, tabId int, userId string, email string) error {
 	return q.WithTx(ctx, func(q *PgxQueries) error {
-		billId, err := q.getTargetBill(ctx, shopId, tabId)
-		if err != nil {
-			return err
-		}
-
-		_, err = q.tx.Exec(ctx, `
-    CREATE TEMPORARY TABLE _temp_upsert_order_items (LIKE order_items INCLUDING ALL ) ON COMMIT DROP`)
-		if err != nil {
-			return handlePgxError(err)
-		}
-		_, err = q.tx.Exec(ctx, `
-	   CREATE TEMPORARY TABLE _temp_upsert_order_variants (LIKE order_variants INCLUDING ALL ) ON COMMIT DROP`)
+		result, err := q.tx.Exec(ctx, `
+      DELETE FROM tab_ownership_transfers
+      WHERE shop_id = @shopId AND tab_id = @tabId AND email = lower(@email)`,
+			pgx.NamedArgs{
+				"shopId": shopId,
+				"tabId":  tabId,
+				"email":  email,
+			})
 		if err != nil {
 			return handlePgxError(err)
 		}
 
-		type itemOrder struct {
-			id       int
-			quantity int
-		}
-
-		type variantOrder struct {
-			itemOrder
-			variantId int
-		}
-
-		itemOrders := make([]itemOrder, 0)
-		variantOrders := make([]variantOrder, 0)
-		for _, i := range data.Items {
-			itemOrders = append(itemOrders, itemOrder{id: i.Id, quantity: *i.Quantity})
-			for _, v := range i.Variants {
-				variantOrders = append(variantOrders, variantOrder{itemOrder: itemOrder{id: i.Id, quantity: *v.Quantity}, variantId: v.Id})
-			}
+		if result.RowsAffected() == 0 {
+			return services.NewNotFoundServiceError(nil)
 		}
 
-		_, err = q.tx.CopyFrom(ctx, pgx.Identifier{"_temp_upsert_order_items"},
-			[]string{"shop_id", "tab_id", "bill_id", "item_id", "quantity"}, pgx.CopyFromSlice(len(itemOrders), func(i int) ([]any, error) {
-				return []any{shopId, tabId, billId, itemOrders[i].id, itemOrders[i].quantity}, nil
-			}))
+		_, err = q.tx.Exec(ctx, `
+      UPDATE tabs SET owner_id = @userId
+      WHERE shop_id = @shopId AND id = @tabId`,
+			pgx.NamedArgs{
+				"shopId": shopId,
+				"tabId":  tabId,
+				"userId": userId,
+			})
 		if err != nil {
 			return handlePgxError(err)
 		}
 
-		_, err = q.tx.CopyFrom(ctx, pgx.Identifier{"_temp_upsert_order_variants"},
-			[]string{"shop_id", "tab_id", "bill_id", "item_id", "variant_id", "quantity"}, pgx.CopyFromSlice(len(variantOrders), func(i int) ([]any, error) {
-				return []any{shopId, tabId, billId, variantOrders[i].id, variantOrders[i].variantId, variantOrders[i].quantity}, nil
-			}))
+		_, err = q.tx.Exec(ctx, `
+      DELETE FROM tab_managers
+      WHERE shop_id = @shopId AND tab_id = @tabId AND email = lower(@email)`,
+			pgx.NamedArgs{
+				"shopId": shopId,
+				"tabId":  tabId,
+				"email":  email,
+			})
 		if err != nil {
 			return handlePgxError(err)
 		}
-
-		err = updateFn(q.tx)
-		if err != nil {
-			return err
-		}
-
 		return nil
 	})
 }
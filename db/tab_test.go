@@ -0,0 +1,62 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/WilliamTrojniak/TabAppBackend/models"
+)
+
+func TestExpireTabUsesShopTimeZone(t *testing.T) {
+	store := testStore(t)
+	ctx := context.Background()
+	// It is already the 2nd of March in Auckland, but still the 1st in Los Angeles
+	now := time.Date(2026, time.March, 1, 20, 0, 0, 0, time.UTC)
+	aucklandShopId, aucklandTabId := createTestTab(t, store, "Pacific/Auckland", models.TAB_STATUS_PENDING, date(2026, time.March, 1), date(2026, time.March, 31), 7)
+	laShopId, laTabId := createTestTab(t, store, "America/Los_Angeles", models.TAB_STATUS_PENDING, date(2026, time.March, 1), date(2026, time.March, 31), 7)
+
+	tabs, err := WithTxRet(ctx, store, func(q *PgxQueries) ([]TabKey, error) {
+		return q.GetTabsDueExpiry(ctx, now)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !containsTab(tabs, aucklandShopId, aucklandTabId) {
+		t.Errorf("expected the pending tab which started yesterday in Auckland to be due, got %v", tabs)
+	}
+	if containsTab(tabs, laShopId, laTabId) {
+		t.Errorf("expected the pending tab starting today in Los Angeles not to be due, got %v", tabs)
+	}
+
+	for _, tab := range []struct {
+		shopId, tabId int
+		expired       bool
+		status        models.TabStatus
+	}{
+		{aucklandShopId, aucklandTabId, true, models.TAB_STATUS_EXPIRED},
+		{laShopId, laTabId, false, models.TAB_STATUS_PENDING},
+	} {
+		expired, err := WithTxRet(ctx, store, func(q *PgxQueries) (bool, error) {
+			return q.ExpireTab(ctx, tab.shopId, tab.tabId, now)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expired != tab.expired {
+			t.Errorf("expected expired to be %v, got %v", tab.expired, expired)
+		}
+
+		status, err := WithTxRet(ctx, store, func(q *PgxQueries) (string, error) {
+			var status string
+			err := q.tx.QueryRow(ctx, `SELECT status FROM tabs WHERE shop_id = $1 AND id = $2`, tab.shopId, tab.tabId).Scan(&status)
+			return status, err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status != tab.status.String() {
+			t.Errorf("expected status %v, got %v", tab.status, status)
+		}
+	}
+}
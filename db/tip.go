@@ -0,0 +1,46 @@
+package db
+
+import (
+	"context"
+
+	"github.com/WilliamTrojniak/TabAppBackend/models"
+	"github.com/jackc/pgx/v5"
+)
+
+func (q *PgxQueries) GetTipsReport(ctx context.Context, shopId int, params *models.TipsReportQueryParams) ([]models.TipsReportEntry, error) {
+	rows, err := q.tx.Query(ctx, `
+    SELECT t.user_id, COALESCE(users.preferred_name, users.name) AS user_name,
+      t.location_id, locations.name AS location_name,
+      COUNT(*) FILTER (WHERE t.voids_order_id IS NULL) AS order_count,
+      ROUND(SUM(t.amount)::NUMERIC, 2)::REAL AS tips
+    FROM (
+      SELECT COALESCE(voided.user_id, orders.user_id) AS user_id, orders.location_id,
+        orders.voids_order_id, oa.amount
+      FROM order_adjustments AS oa
+      JOIN orders ON orders.shop_id = oa.shop_id AND orders.tab_id = oa.tab_id AND orders.id = oa.order_id
+      JOIN tab_bills ON tab_bills.shop_id = orders.shop_id AND tab_bills.tab_id = orders.tab_id AND tab_bills.id = orders.bill_id
+      JOIN shops ON shops.id = orders.shop_id
+      LEFT JOIN orders AS voided ON voided.shop_id = orders.shop_id AND voided.tab_id = orders.tab_id AND voided.id = orders.voids_order_id
+      WHERE oa.shop_id = @shopId AND oa.kind = 'tip' AND tab_bills.status <> 'void'
+        AND (@from::DATE IS NULL OR (orders.created_at AT TIME ZONE shops.time_zone)::date >= @from::DATE)
+        AND (@to::DATE IS NULL OR (orders.created_at AT TIME ZONE shops.time_zone)::date <= @to::DATE)
+    ) AS t
+    LEFT JOIN users ON users.id = t.user_id
+    LEFT JOIN locations ON locations.shop_id = @shopId AND locations.id = t.location_id
+    GROUP BY t.user_id, users.preferred_name, users.name, t.location_id, locations.name
+    ORDER BY user_name NULLS LAST, t.user_id, location_name NULLS LAST, t.location_id`,
+		pgx.NamedArgs{
+			"shopId": shopId,
+			"from":   params.From,
+			"to":     params.To,
+		})
+	if err != nil {
+		return nil, handlePgxError(err)
+	}
+
+	entries, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.TipsReportEntry])
+	if err != nil {
+		return nil, handlePgxError(err)
+	}
+	return entries, nil
+}
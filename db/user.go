@@ -47,3 +47,93 @@ func (q *PgxQueries) UpdateUser(ctx context.Context, userId string, data *models
 
 	return nil
 }
+
+// GetUserTabs returns the tabs across all shops which the user owns or manages, or whose verification list includes the user's email
+func (q *PgxQueries) GetUserTabs(ctx context.Context, userId string, email string) ([]models.UserTab, error) {
+	rows, err := q.tx.Query(ctx, `
+    SELECT 
+      tabs.*, 
+      shops.name AS shop_name,
+      tabs.owner_id = @userId AS is_owner,
+      EXISTS(
+        SELECT 1 FROM tab_managers
+        WHERE tab_managers.shop_id = tabs.shop_id AND tab_managers.tab_id = tabs.id AND tab_managers.email = lower(@email)
+      ) AS is_manager,
+      (SELECT to_jsonb(tab_updates) as pending_updates
+       FROM (SELECT tab_updates.*, 
+             COALESCE(json_agg(locations.*) FILTER (WHERE locations.id IS NOT NULL), '[]') AS locations
+             FROM tab_updates
+             LEFT JOIN tab_update_locations ON tab_updates.shop_id = tab_update_locations.shop_id
+               AND tab_updates.tab_id = tab_update_locations.tab_id
+             LEFT JOIN locations ON locations.shop_id = tabs.shop_id AND locations.id = tab_update_locations.location_id
+             WHERE tab_updates.shop_id = tabs.shop_id AND tab_updates.tab_id = tabs.id
+             GROUP BY tab_updates.shop_id, tab_updates.tab_id
+            ) AS tab_updates
+      ) AS pending_updates,
+      EXISTS(
+        SELECT 1 FROM tab_updates
+        WHERE tab_updates.shop_id = tabs.shop_id AND tab_updates.tab_id = tabs.id
+      ) AS has_pending_updates,
+      (SELECT array_remove(array_agg(tab_users.email), null)
+       FROM tab_users
+       WHERE tab_users.shop_id = tabs.shop_id AND tab_users.tab_id = tabs.id
+      ) AS verification_list,
+      (SELECT COALESCE(array_agg(tab_managers.email ORDER BY tab_managers.email), '{}')
+       FROM tab_managers
+       WHERE tab_managers.shop_id = tabs.shop_id AND tab_managers.tab_id = tabs.id
+      ) AS managers,
+      EXISTS(
+        SELECT b.bill_id
+        FROM tab_bill_balances AS b
+        WHERE b.shop_id = tabs.shop_id AND b.tab_id = tabs.id AND b.outstanding > 0
+        LIMIT 1
+      ) as is_pending_balance,
+      (SELECT COALESCE(SUM(b.outstanding), 0)::REAL
+       FROM tab_bill_balances AS b
+       WHERE b.shop_id = tabs.shop_id AND b.tab_id = tabs.id
+      ) AS balance,
+      (SELECT CASE WHEN tabs.total_budget > 0 THEN (tabs.total_budget - s.spent)::REAL END
+       FROM tab_spend AS s
+       WHERE s.shop_id = tabs.shop_id AND s.tab_id = tabs.id
+      ) AS remaining_budget,
+      (SELECT to_jsonb(b) AS current_bill
+       FROM (SELECT tab_bills.*, b.total, b.outstanding
+             FROM tab_bills
+             JOIN tab_bill_balances AS b ON b.shop_id = tab_bills.shop_id AND b.tab_id = tab_bills.tab_id AND b.bill_id = tab_bills.id
+             WHERE tab_bills.shop_id = tabs.shop_id AND tab_bills.tab_id = tabs.id
+             ORDER BY tab_bills.status = 'open' DESC, tab_bills.start_date DESC
+             LIMIT 1
+            ) AS b
+      ) AS current_bill,
+      (SELECT COALESCE(json_agg(locations.*) FILTER (WHERE locations.id IS NOT NULL), '[]') AS locations
+       FROM locations
+       LEFT JOIN tab_locations ON tab_locations.shop_id = locations.shop_id AND tab_locations.location_id = locations.id
+       WHERE tab_locations.shop_id = tabs.shop_id AND tab_locations.tab_id = tabs.id
+      ) AS locations
+    FROM tabs
+    JOIN shops ON shops.id = tabs.shop_id
+    WHERE tabs.owner_id = @userId
+      OR EXISTS(
+        SELECT 1 FROM tab_users
+        WHERE tab_users.shop_id = tabs.shop_id AND tab_users.tab_id = tabs.id AND lower(tab_users.email) = lower(@email)
+      )
+      OR EXISTS(
+        SELECT 1 FROM tab_managers
+        WHERE tab_managers.shop_id = tabs.shop_id AND tab_managers.tab_id = tabs.id AND tab_managers.email = lower(@email)
+      )
+    ORDER BY tabs.start_date DESC, tabs.display_name
+    `,
+		pgx.NamedArgs{
+			"userId": userId,
+			"email":  email,
+		})
+	if err != nil {
+		return nil, handlePgxError(err)
+	}
+
+	tabs, err := pgx.CollectRows(rows, pgx.RowToStructByNameLax[models.UserTab])
+	if err != nil {
+		return nil, handlePgxError(err)
+	}
+	return tabs, nil
+}
@@ -0,0 +1,133 @@
+package db
+
+import (
+	"context"
+
+	"github.com/WilliamTrojniak/TabAppBackend/models"
+	"github.com/jackc/pgx/v5"
+)
+
+func (q *PgxQueries) CreateVouchers(ctx context.Context, shopId int, tabId int, userId string, data *models.VoucherBatchCreate, codes []string) ([]models.Voucher, error) {
+	rows, err := q.tx.Query(ctx, `
+    INSERT INTO tab_vouchers (shop_id, tab_id, code, max_uses, expires_on, created_by)
+    SELECT @shopId, @tabId, code, @maxUses, @expiresOn, @userId
+    FROM unnest(@codes::text[]) AS code
+    RETURNING id, code, max_uses, uses, expires_on, created_at`,
+		pgx.NamedArgs{
+			"shopId":    shopId,
+			"tabId":     tabId,
+			"maxUses":   data.MaxUses,
+			"expiresOn": data.ExpiresOn,
+			"userId":    userId,
+			"codes":     codes,
+		})
+	if err != nil {
+		return nil, handlePgxError(err)
+	}
+
+	vouchers, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.Voucher])
+	if err != nil {
+		return nil, handlePgxError(err)
+	}
+	return vouchers, nil
+}
+
+func (q *PgxQueries) GetVouchers(ctx context.Context, shopId int, tabId int) ([]models.Voucher, error) {
+	rows, err := q.tx.Query(ctx, `
+    SELECT id, code, max_uses, uses, expires_on, created_at
+    FROM tab_vouchers
+    WHERE shop_id = @shopId AND tab_id = @tabId
+    ORDER BY created_at DESC, id`,
+		pgx.NamedArgs{
+			"shopId": shopId,
+			"tabId":  tabId,
+		})
+	if err != nil {
+		return nil, handlePgxError(err)
+	}
+
+	vouchers, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.Voucher])
+	if err != nil {
+		return nil, handlePgxError(err)
+	}
+	return vouchers, nil
+}
+
+// GetVoucherForRedemption returns the tab's voucher with the given code, locking it until the transaction ends
+func (q *PgxQueries) GetVoucherForRedemption(ctx context.Context, shopId int, tabId int, code string) (models.Voucher, error) {
+	rows, err := q.tx.Query(ctx, `
+    SELECT id, code, max_uses, uses, expires_on, created_at
+    FROM tab_vouchers
+    WHERE shop_id = @shopId AND tab_id = @tabId AND code = upper(@code)
+    FOR UPDATE`,
+		pgx.NamedArgs{
+			"shopId": shopId,
+			"tabId":  tabId,
+			"code":   code,
+		})
+	if err != nil {
+		return models.Voucher{}, handlePgxError(err)
+	}
+
+	voucher, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[models.Voucher])
+	if err != nil {
+		return models.Voucher{}, handlePgxError(err)
+	}
+	return voucher, nil
+}
+
+func (q *PgxQueries) RedeemVoucher(ctx context.Context, shopId int, tabId int, voucherId int, order *models.OrderOverview, userId string) error {
+	return q.WithTx(ctx, func(q *PgxQueries) error {
+		_, err := q.tx.Exec(ctx, `
+    UPDATE tab_vouchers SET uses = uses + 1
+    WHERE shop_id = @shopId AND tab_id = @tabId AND id = @voucherId`,
+			pgx.NamedArgs{
+				"shopId":    shopId,
+				"tabId":     tabId,
+				"voucherId": voucherId,
+			})
+		if err != nil {
+			return handlePgxError(err)
+		}
+
+		_, err = q.tx.Exec(ctx, `
+    INSERT INTO voucher_redemptions (shop_id, tab_id, voucher_id, order_id, bill_id, location_id, redeemed_by)
+    VALUES (@shopId, @tabId, @voucherId, @orderId, @billId, @locationId, @userId)`,
+			pgx.NamedArgs{
+				"shopId":     shopId,
+				"tabId":      tabId,
+				"voucherId":  voucherId,
+				"orderId":    order.Id,
+				"billId":     order.BillId,
+				"locationId": order.LocationId,
+				"userId":     userId,
+			})
+		if err != nil {
+			return handlePgxError(err)
+		}
+
+		return nil
+	})
+}
+
+// ReleaseVoucherRedemption returns the use of a voucher redeemed by an order which has been voided
+func (q *PgxQueries) ReleaseVoucherRedemption(ctx context.Context, shopId int, tabId int, orderId int) error {
+	_, err := q.tx.Exec(ctx, `
+    WITH released AS (
+      UPDATE voucher_redemptions SET released_at = NOW()
+      WHERE shop_id = @shopId AND tab_id = @tabId AND order_id = @orderId AND released_at IS NULL
+      RETURNING voucher_id
+    )
+    UPDATE tab_vouchers SET uses = uses - 1
+    FROM released
+    WHERE tab_vouchers.shop_id = @shopId AND tab_vouchers.tab_id = @tabId AND tab_vouchers.id = released.voucher_id`,
+		pgx.NamedArgs{
+			"shopId":  shopId,
+			"tabId":   tabId,
+			"orderId": orderId,
+		})
+	if err != nil {
+		return handlePgxError(err)
+	}
+	return nil
+}
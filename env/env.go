@@ -6,6 +6,8 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"slices"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -21,6 +23,14 @@ type config struct {
 	POSTGRES_PORT               string
 	POSTGRES_DB                 string
 	REDIS_ADDR                  string
+	EMAIL_TRANSPORT             string `default:"log"`
+	// Fields tagged with transports are only required when EMAIL_TRANSPORT is one of them
+	EMAIL_FROM       string `transports:"smtp"`
+	EMAIL_OUTBOX_DIR string `transports:"file"`
+	SMTP_HOST        string `transports:"smtp"`
+	SMTP_PORT        string `transports:"smtp"`
+	SMTP_USERNAME    string `transports:"smtp"`
+	SMTP_PASSWORD    string `transports:"smtp"`
 }
 
 var Envs = getConfig()
@@ -48,7 +58,28 @@ func getConfig() config {
 	types := configStruct.Type()
 
 	for i := 0; i < configStruct.NumField(); i++ {
-		configStruct.Field(i).SetString(getEnvOrFail(types.Field(i).Name))
+		field := types.Field(i)
+		if _, ok := field.Tag.Lookup("transports"); ok {
+			continue
+		}
+		if value, ok := field.Tag.Lookup("default"); ok {
+			configStruct.Field(i).SetString(getEnvOrDefault(field.Name, value))
+			continue
+		}
+		configStruct.Field(i).SetString(getEnvOrFail(field.Name))
+	}
+
+	for i := 0; i < configStruct.NumField(); i++ {
+		field := types.Field(i)
+		transports, ok := field.Tag.Lookup("transports")
+		if !ok {
+			continue
+		}
+		if slices.Contains(strings.Split(transports, ","), configData.EMAIL_TRANSPORT) {
+			configStruct.Field(i).SetString(getEnvOrFail(field.Name))
+		} else {
+			configStruct.Field(i).SetString(os.Getenv(field.Name))
+		}
 	}
 
 	return configData
@@ -61,3 +92,11 @@ func getEnvOrFail(key string) string {
 	}
 	return val
 }
+
+func getEnvOrDefault(key string, fallback string) string {
+	val, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	return val
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"slices"
+)
+
+type ShopChargeKind int
+
+const (
+	SHOP_CHARGE_KIND_TAX ShopChargeKind = iota
+	SHOP_CHARGE_KIND_SERVICE_FEE
+)
+
+func (k ShopChargeKind) String() string {
+	switch k {
+	case SHOP_CHARGE_KIND_TAX:
+		return "tax"
+	case SHOP_CHARGE_KIND_SERVICE_FEE:
+		return "service_fee"
+	default:
+		return "unknown"
+	}
+}
+
+type ShopChargeUpdate struct {
+	Kind              string  `json:"kind" db:"kind" validate:"required,oneof=tax service_fee"`
+	Name              string  `json:"name" db:"name" validate:"required,min=1,max=64"`
+	RatePercent       float32 `json:"rate_percent" db:"rate_percent" validate:"gt=0,lte=100"`
+	ExemptCategoryIds []int   `json:"exempt_category_ids" db:"exempt_category_ids" validate:"required,dive,gte=1"`
+}
+
+type ShopChargeCreate struct {
+	ShopId int `json:"shop_id" db:"shop_id" validate:"required,gte=1"`
+	ShopChargeUpdate
+}
+
+// ShopCharge is a tax or service fee charged as a percentage of the discounted price of the items of each order
+type ShopCharge struct {
+	Id int `json:"id" db:"id" validate:"required,gte=1"`
+	ShopChargeCreate
+}
+
+// Charges returns the taxes and service fees on an order after its discounts. Items in a charge's exempt
+// categories are not charged, and no taxes are charged to tax exempt tabs.
+// itemCategoryIds maps the id of each item ordered to the ids of its categories.
+//
+// Charges are rounded to the cent on each order, as printed on the order's receipt, and a bill's charges
+// are the sum of those on its orders. A bill's charges may therefore differ by a few cents from the rate
+// applied to the bill's subtotal.
+func Charges(charges []ShopCharge, items []ItemOrder, discounts []OrderAdjustment, itemCategoryIds map[int][]int, isTaxExempt bool) []OrderAdjustment {
+	// The discounted price of each item, with its variants
+	itemTotals := make(map[int]float32, len(items))
+	itemIds := make([]int, 0, len(items))
+	for _, item := range items {
+		if _, ok := itemTotals[item.Id]; !ok {
+			itemIds = append(itemIds, item.Id)
+		}
+		itemTotals[item.Id] += item.Total()
+	}
+	for _, discount := range discounts {
+		if discount.ItemId != nil {
+			itemTotals[*discount.ItemId] += discount.Amount
+		}
+	}
+
+	adjustments := make([]OrderAdjustment, 0)
+	for _, charge := range charges {
+		var kind OrderAdjustmentKind
+		switch charge.Kind {
+		case SHOP_CHARGE_KIND_TAX.String():
+			if isTaxExempt {
+				continue
+			}
+			kind = ORDER_ADJUSTMENT_KIND_TAX
+		case SHOP_CHARGE_KIND_SERVICE_FEE.String():
+			kind = ORDER_ADJUSTMENT_KIND_SERVICE_FEE
+		default:
+			continue
+		}
+
+		var chargeable float32
+		for _, itemId := range itemIds {
+			exempt := slices.ContainsFunc(itemCategoryIds[itemId], func(categoryId int) bool {
+				return slices.Contains(charge.ExemptCategoryIds, categoryId)
+			})
+			if !exempt {
+				chargeable += itemTotals[itemId]
+			}
+		}
+
+		amount := float32(math.Round(float64(chargeable*charge.RatePercent/100)*100) / 100)
+		if amount == 0 {
+			continue
+		}
+
+		adjustments = append(adjustments, OrderAdjustment{
+			Kind:        kind.String(),
+			Description: fmt.Sprintf("%v (%v%%)", charge.Name, charge.RatePercent),
+			Amount:      amount,
+		})
+	}
+	return adjustments
+}
@@ -0,0 +1,138 @@
+package models
+
+import (
+	"slices"
+	"testing"
+)
+
+func testCharge(id int, kind ShopChargeKind, name string, ratePercent float32, exemptCategoryIds ...int) ShopCharge {
+	charge := ShopCharge{Id: id}
+	charge.Kind = kind.String()
+	charge.Name = name
+	charge.RatePercent = ratePercent
+	charge.ExemptCategoryIds = exemptCategoryIds
+	return charge
+}
+
+func TestCharges(t *testing.T) {
+	itemId := 1
+	// Coffee is a drink and a muffin is food, which is exempt from sales tax
+	itemCategoryIds := map[int][]int{1: {100}, 2: {200}}
+	salesTax := testCharge(1, SHOP_CHARGE_KIND_TAX, "Sales tax", 10, 200)
+	serviceFee := testCharge(2, SHOP_CHARGE_KIND_SERVICE_FEE, "Service fee", 5)
+
+	tests := []struct {
+		name        string
+		charges     []ShopCharge
+		items       []ItemOrder
+		discounts   []OrderAdjustment
+		isTaxExempt bool
+		expected    []string
+	}{
+		{
+			name:     "no charges",
+			items:    []ItemOrder{testItem(1, "Coffee", 3, 1)},
+			expected: []string{},
+		},
+		{
+			name:     "tax and service fee",
+			charges:  []ShopCharge{salesTax, serviceFee},
+			items:    []ItemOrder{testItem(1, "Coffee", 3, 2, testVariant(10, "Oat milk", 0.5, 2))},
+			expected: []string{"Sales tax (10%): 0.70", "Service fee (5%): 0.35"},
+		},
+		{
+			name:     "exempt category",
+			charges:  []ShopCharge{salesTax, serviceFee},
+			items:    []ItemOrder{testItem(1, "Coffee", 3, 1), testItem(2, "Muffin", 2, 1)},
+			expected: []string{"Sales tax (10%): 0.30", "Service fee (5%): 0.25"},
+		},
+		{
+			name:     "order of only exempt items",
+			charges:  []ShopCharge{salesTax},
+			items:    []ItemOrder{testItem(2, "Muffin", 2, 3)},
+			expected: []string{},
+		},
+		{
+			name:        "tax exempt tab still pays service fees",
+			charges:     []ShopCharge{salesTax, serviceFee},
+			items:       []ItemOrder{testItem(1, "Coffee", 3, 2)},
+			isTaxExempt: true,
+			expected:    []string{"Service fee (5%): 0.30"},
+		},
+		{
+			name:    "charged after discounts",
+			charges: []ShopCharge{salesTax},
+			items:   []ItemOrder{testItem(1, "Coffee", 3, 2)},
+			discounts: []OrderAdjustment{
+				{Kind: ORDER_ADJUSTMENT_KIND_DISCOUNT.String(), ItemId: &itemId, Amount: -1},
+			},
+			expected: []string{"Sales tax (10%): 0.50"},
+		},
+		{
+			name:     "lines of the same item are charged together",
+			charges:  []ShopCharge{testCharge(1, SHOP_CHARGE_KIND_TAX, "Sales tax", 8.875)},
+			items:    []ItemOrder{testItem(1, "Coffee", 1.11, 1), testItem(1, "Coffee", 1.11, 2)},
+			expected: []string{"Sales tax (8.875%): 0.30"},
+		},
+		{
+			name:     "rounded to the cent on each order",
+			charges:  []ShopCharge{testCharge(1, SHOP_CHARGE_KIND_TAX, "Sales tax", 8.875)},
+			items:    []ItemOrder{testItem(1, "Coffee", 3.33, 1)},
+			expected: []string{"Sales tax (8.875%): 0.30"},
+		},
+		{
+			name:     "charges which round to nothing are left out",
+			charges:  []ShopCharge{serviceFee},
+			items:    []ItemOrder{testItem(1, "Coffee", 0.05, 1)},
+			expected: []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := formatAdjustments(Charges(test.charges, test.items, test.discounts, itemCategoryIds, test.isTaxExempt))
+			if !slices.Equal(got, test.expected) {
+				t.Errorf("expected %q, got %q", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestChargesKinds(t *testing.T) {
+	charges := []ShopCharge{
+		testCharge(1, SHOP_CHARGE_KIND_TAX, "Sales tax", 10),
+		testCharge(2, SHOP_CHARGE_KIND_SERVICE_FEE, "Service fee", 10),
+	}
+
+	adjustments := Charges(charges, []ItemOrder{testItem(1, "Coffee", 3, 1)}, nil, nil, false)
+	if len(adjustments) != 2 {
+		t.Fatalf("expected 2 charges, got %v", adjustments)
+	}
+	if adjustments[0].Kind != ORDER_ADJUSTMENT_KIND_TAX.String() || adjustments[1].Kind != ORDER_ADJUSTMENT_KIND_SERVICE_FEE.String() {
+		t.Errorf("expected a tax and a service fee, got %v and %v", adjustments[0].Kind, adjustments[1].Kind)
+	}
+	if adjustments[0].ItemId != nil || adjustments[1].ItemId != nil {
+		t.Errorf("expected charges to apply to the whole order")
+	}
+}
+
+// A bill's charges are the sum of those rounded on each of its orders, rather than the rate applied to its subtotal
+func TestChargesRoundedPerOrder(t *testing.T) {
+	charges := []ShopCharge{testCharge(1, SHOP_CHARGE_KIND_TAX, "Sales tax", 8.875)}
+	order := []ItemOrder{testItem(1, "Coffee", 3.33, 1)}
+
+	var billTotal float32
+	for i := 0; i < 2; i++ {
+		for _, adjustment := range Charges(charges, order, nil, nil, false) {
+			billTotal += adjustment.Amount
+		}
+	}
+	if formatted := formatAdjustments([]OrderAdjustment{{Description: "Bill", Amount: billTotal}}); formatted[0] != "Bill: 0.60" {
+		t.Errorf("expected the bill to be charged 0.60 over two orders, got %v", formatted[0])
+	}
+
+	combined := Charges(charges, []ItemOrder{testItem(1, "Coffee", 3.33, 2)}, nil, nil, false)
+	if formatted := formatAdjustments(combined); formatted[0] != "Sales tax (8.875%): 0.59" {
+		t.Errorf("expected a single order of both items to be charged 0.59, got %v", formatted[0])
+	}
+}
@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+type TabCommentKind int
+
+const (
+	TAB_COMMENT_KIND_COMMENT TabCommentKind = iota
+	TAB_COMMENT_KIND_CREATED
+	TAB_COMMENT_KIND_UPDATE_REQUESTED
+	TAB_COMMENT_KIND_APPROVED
+	TAB_COMMENT_KIND_CLOSED
+	TAB_COMMENT_KIND_BILL_PAID
+	TAB_COMMENT_KIND_EXPIRED
+	TAB_COMMENT_KIND_REJECTED
+)
+
+func (k TabCommentKind) String() string {
+	switch k {
+	case TAB_COMMENT_KIND_COMMENT:
+		return "comment"
+	case TAB_COMMENT_KIND_CREATED:
+		return "created"
+	case TAB_COMMENT_KIND_UPDATE_REQUESTED:
+		return "update_requested"
+	case TAB_COMMENT_KIND_APPROVED:
+		return "approved"
+	case TAB_COMMENT_KIND_CLOSED:
+		return "closed"
+	case TAB_COMMENT_KIND_BILL_PAID:
+		return "bill_paid"
+	case TAB_COMMENT_KIND_EXPIRED:
+		return "expired"
+	case TAB_COMMENT_KIND_REJECTED:
+		return "rejected"
+	default:
+		return "unknown"
+	}
+}
+
+type TabCommentCreate struct {
+	Body string `json:"body" db:"body" validate:"required,max=2048"`
+}
+
+// TabComment is an entry in a tab's discussion thread. Entries other than comments are recorded
+// automatically for events in the tab's lifecycle, and have no author when made by the system.
+type TabComment struct {
+	Id         int       `json:"id" db:"id"`
+	Kind       string    `json:"kind" db:"kind"`
+	AuthorId   *string   `json:"author_id" db:"author_id"`
+	AuthorName *string   `json:"author_name" db:"author_name"`
+	Body       *string   `json:"body" db:"body"`
+	BillId     *int      `json:"bill_id" db:"bill_id"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
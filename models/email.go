@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+type EmailCreate struct {
+	Recipient string `json:"recipient" db:"recipient"`
+	Template  string `json:"template" db:"template"`
+	Subject   string `json:"subject" db:"subject"`
+	TextBody  string `json:"text_body" db:"text_body"`
+	HtmlBody  string `json:"html_body" db:"html_body"`
+}
+
+type Email struct {
+	EmailCreate
+	Id        int       `json:"id" db:"id"`
+	Attempts  int       `json:"attempts" db:"attempts"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
@@ -24,8 +24,18 @@ type ItemOverview struct {
 
 type ItemOrder struct {
 	ItemOverview
-	Quantity int                `json:"quantity" db:"quantity" validate:"required,gte=0"`
-	Variants []ItemVariantOrder `json:"variants" db:"variants" validate:"required,dive"`
+	Quantity   int                `json:"quantity" db:"quantity" validate:"required,gte=0"`
+	Variants   []ItemVariantOrder `json:"variants" db:"variants" validate:"required,dive"`
+	LocationId *int               `json:"location_id" db:"location_id"`
+	Customer   string             `json:"customer" db:"customer"`
+}
+
+func (order *ItemOrder) Total() float32 {
+	total := float32(order.Quantity) * *order.BasePrice
+	for _, variant := range order.Variants {
+		total += float32(variant.Quantity) * *variant.Price
+	}
+	return total
 }
 
 type Item struct {
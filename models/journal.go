@@ -0,0 +1,52 @@
+package models
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+type JournalExportCreate struct {
+	StartDate Date `json:"start_date" db:"start_date" validate:"required"`
+	EndDate   Date `json:"end_date" db:"end_date" validate:"required"`
+}
+
+type JournalExport struct {
+	Id         int       `json:"id" db:"id"`
+	StartDate  Date      `json:"start_date" db:"start_date"`
+	EndDate    Date      `json:"end_date" db:"end_date"`
+	ExportedBy string    `json:"exported_by" db:"exported_by"`
+	ExportedAt time.Time `json:"exported_at" db:"exported_at"`
+	BillCount  int       `json:"bill_count" db:"bill_count"`
+}
+
+type JournalEntry struct {
+	InvoiceNumber int     `db:"invoice_number"`
+	Chartstring   string  `db:"chartstring"`
+	Organization  string  `db:"organization"`
+	TabId         int     `db:"tab_id"`
+	TabName       string  `db:"tab_name"`
+	BillId        int     `db:"bill_id"`
+	StartDate     Date    `db:"start_date"`
+	EndDate       Date    `db:"end_date"`
+	Subtotal      float32 `db:"subtotal"`
+	Discounts     float32 `db:"discounts"`
+	Taxes         float32 `db:"taxes"`
+	ServiceFees   float32 `db:"service_fees"`
+	Tips          float32 `db:"tips"`
+	Amount        float32 `db:"amount"`
+}
+
+func JournalExportCreateStructLevelValidation(sl validator.StructLevel) {
+	data := sl.Current().Interface().(JournalExportCreate)
+
+	if data.EndDate.Before(data.StartDate.Date) {
+		field, _ := reflect.ValueOf(data).Type().FieldByName("EndDate")
+		tag, ok := field.Tag.Lookup("json")
+		if !ok {
+			tag = field.Name
+		}
+		sl.ReportError(data.EndDate, tag, field.Name, "endafterstart", "")
+	}
+}
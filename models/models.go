@@ -31,6 +31,7 @@ func init() {
 	})
 
 	Validate.RegisterStructValidation(TabUpdateStructLevelValidation, TabUpdate{})
+	Validate.RegisterStructValidation(JournalExportCreateStructLevelValidation, JournalExportCreate{})
 	Validate.RegisterValidation("future", dateFutureValidation)
 }
 
@@ -0,0 +1,70 @@
+package models
+
+import "time"
+
+type OrderOverview struct {
+	Id           int       `json:"id" db:"id"`
+	BillId       int       `json:"bill_id" db:"bill_id"`
+	LocationId   *int      `json:"location_id" db:"location_id"`
+	Customer     string    `json:"customer" db:"customer"`
+	UserId       *string   `json:"user_id" db:"user_id"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	VoidsOrderId *int      `json:"voids_order_id" db:"voids_order_id"`
+	VoidReason   *string   `json:"void_reason" db:"void_reason"`
+}
+
+type Order struct {
+	OrderOverview
+	Items         []ItemOrder         `json:"items" db:"items"`
+	Adjustments   []OrderAdjustment   `json:"adjustments" db:"adjustments"`
+	Total         float32             `json:"total" db:"total"`
+	IsVoided      bool                `json:"is_voided" db:"is_voided"`
+	LimitOverride *OrderLimitOverride `json:"limit_override" db:"limit_override"`
+}
+
+// OrderLimitOverride records why an order over the tab's dollar limit per order was allowed, and by whom
+type OrderLimitOverride struct {
+	UserId      string    `json:"user_id" db:"user_id"`
+	Reason      string    `json:"reason" db:"reason"`
+	DollarLimit float32   `json:"dollar_limit" db:"dollar_limit"`
+	Total       float32   `json:"total" db:"total"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+type OrderVoid struct {
+	Reason string `json:"reason" db:"reason" validate:"required,min=1,max=255"`
+}
+
+type OrderAdjustmentKind int
+
+const (
+	ORDER_ADJUSTMENT_KIND_DISCOUNT OrderAdjustmentKind = iota
+	ORDER_ADJUSTMENT_KIND_TAX
+	ORDER_ADJUSTMENT_KIND_SERVICE_FEE
+	ORDER_ADJUSTMENT_KIND_TIP
+)
+
+func (k OrderAdjustmentKind) String() string {
+	switch k {
+	case ORDER_ADJUSTMENT_KIND_DISCOUNT:
+		return "discount"
+	case ORDER_ADJUSTMENT_KIND_TAX:
+		return "tax"
+	case ORDER_ADJUSTMENT_KIND_SERVICE_FEE:
+		return "service_fee"
+	case ORDER_ADJUSTMENT_KIND_TIP:
+		return "tip"
+	default:
+		return "unknown"
+	}
+}
+
+// OrderAdjustment is charged alongside the lines of an order, such as a discount on one of its items
+// or a tax on the order. Discounts have negative amounts.
+type OrderAdjustment struct {
+	Kind        string  `json:"kind" db:"kind"`
+	Description string  `json:"description" db:"description"`
+	ItemId      *int    `json:"item_id" db:"item_id"`
+	VariantId   *int    `json:"variant_id" db:"variant_id"`
+	Amount      float32 `json:"amount" db:"amount"`
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"fmt"
+	"math"
+)
+
+type TabPriceOverride struct {
+	ItemId    int      `json:"item_id" db:"item_id" validate:"required,gte=1"`
+	VariantId *int     `json:"variant_id" db:"variant_id" validate:"omitempty,gte=1"`
+	Price     *float32 `json:"price" db:"price" validate:"required,gte=0"`
+}
+
+type TabCategoryDiscount struct {
+	CategoryId      int     `json:"category_id" db:"category_id" validate:"required,gte=1"`
+	DiscountPercent float32 `json:"discount_percent" db:"discount_percent" validate:"gt=0,lte=100"`
+}
+
+// TabPricing holds the rules used to price the orders on a tab. A price override takes precedence
+// over discounts for the line it applies to, and otherwise the greater of the tab's discount and the
+// discounts for the item's categories applies. An override at or above the line's current price is not
+// applied, so that lowering an item's price never turns the override into a surcharge. Tax exempt tabs
+// are not charged the shop's taxes.
+type TabPricing struct {
+	DiscountPercent   float32               `json:"discount_percent" db:"discount_percent" validate:"gte=0,lte=100"`
+	IsTaxExempt       bool                  `json:"is_tax_exempt" db:"is_tax_exempt"`
+	PriceOverrides    []TabPriceOverride    `json:"price_overrides" db:"price_overrides" validate:"required,dive"`
+	CategoryDiscounts []TabCategoryDiscount `json:"category_discounts" db:"category_discounts" validate:"required,dive"`
+}
+
+// IsEmpty reports whether the rules leave the prices of every item unchanged
+func (p *TabPricing) IsEmpty() bool {
+	return p.DiscountPercent == 0 && len(p.PriceOverrides) == 0 && len(p.CategoryDiscounts) == 0
+}
+
+func (p *TabPricing) priceOverride(itemId int, variantId *int) *float32 {
+	for _, override := range p.PriceOverrides {
+		if override.ItemId != itemId {
+			continue
+		}
+		if (override.VariantId == nil && variantId == nil) || (override.VariantId != nil && variantId != nil && *override.VariantId == *variantId) {
+			return override.Price
+		}
+	}
+	return nil
+}
+
+func (p *TabPricing) discountPercent(categoryIds []int) float32 {
+	percent := p.DiscountPercent
+	for _, discount := range p.CategoryDiscounts {
+		for _, categoryId := range categoryIds {
+			if discount.CategoryId == categoryId && discount.DiscountPercent > percent {
+				percent = discount.DiscountPercent
+			}
+		}
+	}
+	return percent
+}
+
+// Discounts returns a discount adjustment for each line of the order whose price is changed by the rules.
+// itemCategoryIds maps the id of each item ordered to the ids of its categories.
+func (p *TabPricing) Discounts(items []ItemOrder, itemCategoryIds map[int][]int) []OrderAdjustment {
+	discounts := make([]OrderAdjustment, 0)
+	addDiscount := func(itemId int, variantId *int, name string, quantity int, price float32) {
+		if quantity == 0 {
+			return
+		}
+
+		var description string
+		var unitDiscount float32
+		if override := p.priceOverride(itemId, variantId); override != nil {
+			description = fmt.Sprintf("Tab price: %v", name)
+			unitDiscount = price - *override
+		} else {
+			percent := p.discountPercent(itemCategoryIds[itemId])
+			description = fmt.Sprintf("%v%% discount: %v", percent, name)
+			unitDiscount = price * percent / 100
+		}
+		if unitDiscount <= 0 {
+			return
+		}
+
+		amount := float32(math.Round(float64(float32(quantity)*unitDiscount)*100) / 100)
+		if amount == 0 {
+			return
+		}
+
+		itemIdRef := itemId
+		discounts = append(discounts, OrderAdjustment{
+			Kind:        ORDER_ADJUSTMENT_KIND_DISCOUNT.String(),
+			Description: description,
+			ItemId:      &itemIdRef,
+			VariantId:   variantId,
+			Amount:      -amount,
+		})
+	}
+
+	for _, item := range items {
+		addDiscount(item.Id, nil, item.Name, item.Quantity, *item.BasePrice)
+		for _, variant := range item.Variants {
+			variantId := variant.Id
+			addDiscount(item.Id, &variantId, fmt.Sprintf("%v (%v)", item.Name, variant.Name), variant.Quantity, *variant.Price)
+		}
+	}
+	return discounts
+}
@@ -0,0 +1,144 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func price(p float32) *float32 {
+	return &p
+}
+
+func testVariant(id int, name string, p float32, quantity int) ItemVariantOrder {
+	variant := ItemVariantOrder{Quantity: quantity}
+	variant.Id = id
+	variant.Name = name
+	variant.Price = price(p)
+	return variant
+}
+
+func testItem(id int, name string, p float32, quantity int, variants ...ItemVariantOrder) ItemOrder {
+	item := ItemOrder{Quantity: quantity, Variants: variants}
+	item.Id = id
+	item.Name = name
+	item.BasePrice = price(p)
+	return item
+}
+
+// formatAdjustments describes each adjustment along with the line it applies to
+func formatAdjustments(adjustments []OrderAdjustment) []string {
+	formatted := make([]string, 0, len(adjustments))
+	for _, adjustment := range adjustments {
+		line := ""
+		if adjustment.ItemId != nil {
+			line = fmt.Sprintf(" [%v", *adjustment.ItemId)
+			if adjustment.VariantId != nil {
+				line += fmt.Sprintf("/%v", *adjustment.VariantId)
+			}
+			line += "]"
+		}
+		formatted = append(formatted, fmt.Sprintf("%v%v: %.2f", adjustment.Description, line, adjustment.Amount))
+	}
+	return formatted
+}
+
+func TestTabPricingDiscounts(t *testing.T) {
+	variantId := 10
+	// Coffee is a drink and tea is a drink on special
+	itemCategoryIds := map[int][]int{1: {100}, 2: {100, 200}, 3: {}}
+
+	tests := []struct {
+		name     string
+		pricing  TabPricing
+		items    []ItemOrder
+		expected []string
+	}{
+		{
+			name:     "no rules",
+			pricing:  TabPricing{},
+			items:    []ItemOrder{testItem(1, "Coffee", 3, 2)},
+			expected: []string{},
+		},
+		{
+			name:     "tab discount",
+			pricing:  TabPricing{DiscountPercent: 10},
+			items:    []ItemOrder{testItem(1, "Coffee", 3, 2), testItem(3, "Muffin", 2.5, 1)},
+			expected: []string{"10% discount: Coffee [1]: -0.60", "10% discount: Muffin [3]: -0.25"},
+		},
+		{
+			name: "greater category discount",
+			pricing: TabPricing{
+				DiscountPercent:   10,
+				CategoryDiscounts: []TabCategoryDiscount{{CategoryId: 100, DiscountPercent: 5}, {CategoryId: 200, DiscountPercent: 25}},
+			},
+			items:    []ItemOrder{testItem(1, "Coffee", 3, 1), testItem(2, "Tea", 2, 1)},
+			expected: []string{"10% discount: Coffee [1]: -0.30", "25% discount: Tea [2]: -0.50"},
+		},
+		{
+			name: "override takes precedence over discounts",
+			pricing: TabPricing{
+				DiscountPercent:   50,
+				CategoryDiscounts: []TabCategoryDiscount{{CategoryId: 100, DiscountPercent: 75}},
+				PriceOverrides:    []TabPriceOverride{{ItemId: 1, Price: price(2.75)}},
+			},
+			items:    []ItemOrder{testItem(1, "Coffee", 3, 4)},
+			expected: []string{"Tab price: Coffee [1]: -1.00"},
+		},
+		{
+			name:    "variant override",
+			pricing: TabPricing{PriceOverrides: []TabPriceOverride{{ItemId: 1, VariantId: &variantId, Price: price(0)}}},
+			items: []ItemOrder{
+				testItem(1, "Coffee", 3, 2, testVariant(10, "Oat milk", 0.5, 2), testVariant(11, "Extra shot", 0.75, 1)),
+			},
+			expected: []string{"Tab price: Coffee (Oat milk) [1/10]: -1.00"},
+		},
+		{
+			name:    "variants are discounted alongside their item",
+			pricing: TabPricing{DiscountPercent: 20},
+			items: []ItemOrder{
+				testItem(1, "Coffee", 3, 1, testVariant(10, "Oat milk", 0.5, 1)),
+			},
+			expected: []string{"20% discount: Coffee [1]: -0.60", "20% discount: Coffee (Oat milk) [1/10]: -0.10"},
+		},
+		{
+			name:     "override above the current price is not a surcharge",
+			pricing:  TabPricing{DiscountPercent: 10, PriceOverrides: []TabPriceOverride{{ItemId: 1, Price: price(3.5)}}},
+			items:    []ItemOrder{testItem(1, "Coffee", 3, 2)},
+			expected: []string{},
+		},
+		{
+			name:     "override at the current price",
+			pricing:  TabPricing{PriceOverrides: []TabPriceOverride{{ItemId: 1, Price: price(3)}}},
+			items:    []ItemOrder{testItem(1, "Coffee", 3, 2)},
+			expected: []string{},
+		},
+		{
+			name:     "lines without a quantity",
+			pricing:  TabPricing{DiscountPercent: 10},
+			items:    []ItemOrder{testItem(1, "Coffee", 3, 0, testVariant(10, "Oat milk", 0.5, 0))},
+			expected: []string{},
+		},
+		{
+			name:     "rounded to the cent per line",
+			pricing:  TabPricing{DiscountPercent: 15},
+			items:    []ItemOrder{testItem(1, "Coffee", 2.99, 3)},
+			expected: []string{"15% discount: Coffee [1]: -1.35"},
+		},
+		{
+			name:     "discounts which round to nothing are left out",
+			pricing:  TabPricing{DiscountPercent: 1},
+			items:    []ItemOrder{testItem(3, "Muffin", 0.25, 1)},
+			expected: []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := formatAdjustments(test.pricing.Discounts(test.items, itemCategoryIds))
+			if !slices.Equal(got, test.expected) {
+				t.Errorf("expected %q, got %q", test.expected, got)
+			}
+		})
+	}
+}
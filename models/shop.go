@@ -12,6 +12,7 @@ const (
 type ShopUpdate struct {
 	Name           string   `json:"name" db:"name" validate:"required,min=1,max=64"`
 	PaymentMethods []string `json:"payment_methods" db:"payment_methods" validate:"dive,oneof='in person' 'chartstring'"`
+	TimeZone       string   `json:"time_zone" db:"time_zone" validate:"omitempty,timezone"`
 }
 
 type ShopCreate struct {
@@ -24,6 +25,10 @@ type ShopOverview struct {
 	ShopCreate
 }
 
+func (s *ShopOverview) Location() (*time.Location, error) {
+	return time.LoadLocation(s.TimeZone)
+}
+
 type Shop struct {
 	ShopOverview
 	Locations []Location `json:"locations" db:"locations"`
@@ -1,20 +1,36 @@
 package models
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
 	"log"
+	"math"
 	"reflect"
 	"regexp"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/go-playground/validator/v10"
 )
 
+type VerificationMethod string
+
+const (
+	VerificationMethodSpecify VerificationMethod = "specify"
+	VerificationMethodVoucher VerificationMethod = "voucher"
+	VerificationMethodEmail   VerificationMethod = "email"
+)
+
 type TabStatus int
 
 const (
 	TAB_STATUS_PENDING TabStatus = iota
 	TAB_STATUS_CONFIRMED
 	TAB_STATUS_CLOSED
+	TAB_STATUS_EXPIRED
+	TAB_STATUS_REJECTED
 )
 
 func (s TabStatus) String() string {
@@ -25,6 +41,34 @@ func (s TabStatus) String() string {
 		return "confirmed"
 	case TAB_STATUS_CLOSED:
 		return "closed"
+	case TAB_STATUS_EXPIRED:
+		return "expired"
+	case TAB_STATUS_REJECTED:
+		return "rejected"
+	default:
+		return "unknown"
+	}
+}
+
+type BillStatus int
+
+const (
+	BILL_STATUS_OPEN BillStatus = iota
+	BILL_STATUS_ISSUED
+	BILL_STATUS_PAID
+	BILL_STATUS_VOID
+)
+
+func (s BillStatus) String() string {
+	switch s {
+	case BILL_STATUS_OPEN:
+		return "open"
+	case BILL_STATUS_ISSUED:
+		return "issued"
+	case BILL_STATUS_PAID:
+		return "paid"
+	case BILL_STATUS_VOID:
+		return "void"
 	default:
 		return "unknown"
 	}
@@ -37,23 +81,126 @@ type OrderCreate struct {
 
 type ItemOrderCreate struct {
 	OrderCreate
-	Variants []OrderCreate `json:"variants" db:"variants" validate:"required,dive"`
+	Variants []OrderCreate `json:"variants" db:"variants" validate:"required,unique=Id,dive"`
 }
 
 type BillOrderCreate struct {
-	Items []ItemOrderCreate `json:"items" db:"items" validate:"required,dive"`
+	LocationId          int               `json:"location_id" db:"location_id" validate:"required,gte=1"`
+	Items               []ItemOrderCreate `json:"items" db:"items" validate:"required,dive"`
+	LimitOverrideReason *string           `json:"limit_override_reason" db:"limit_override_reason" validate:"omitempty,min=1,max=255"`
+	VoucherCode         *string           `json:"voucher_code" db:"voucher_code" validate:"omitempty,min=1,max=32"`
+	CustomerEmail       *string           `json:"customer_email" db:"customer_email" validate:"omitempty,email"`
+	CustomerName        *string           `json:"customer_name" db:"customer_name" validate:"omitempty,min=1,max=255"`
+	TipAmount           *float32          `json:"tip_amount" db:"tip_amount" validate:"omitempty,gt=0"`
+	TipPercent          *float32          `json:"tip_percent" db:"tip_percent" validate:"omitempty,gt=0,lte=100,excluded_with=TipAmount"`
+}
+
+type OrderLimitExceeded struct {
+	DollarLimitPerOrder float32 `json:"dollar_limit_per_order"`
+	Total               float32 `json:"total"`
+}
+
+type TipExceeded struct {
+	MaxTipPercent float32 `json:"max_tip_percent"`
+	Max           float32 `json:"max"`
+	Tip           float32 `json:"tip"`
+}
+
+type BudgetExceeded struct {
+	TotalBudget float32 `json:"total_budget"`
+	Spent       float32 `json:"spent"`
+	Total       float32 `json:"total"`
+}
+
+// DiscountedTotal returns the total of an order's items less its discounts, leaving out any other
+// adjustments such as the shop's taxes and service fees
+func DiscountedTotal(items []ItemOrder, adjustments []OrderAdjustment) float32 {
+	var total float32 = 0
+	for _, item := range items {
+		total += item.Total()
+	}
+	for _, adjustment := range adjustments {
+		if adjustment.Kind == ORDER_ADJUSTMENT_KIND_DISCOUNT.String() {
+			total += adjustment.Amount
+		}
+	}
+	return float32(math.Round(float64(total)*100) / 100)
+}
+
+func OrderTotal(items []ItemOrder, adjustments []OrderAdjustment) float32 {
+	var total float32 = 0
+	for _, item := range items {
+		total += item.Total()
+	}
+	for _, adjustment := range adjustments {
+		total += adjustment.Amount
+	}
+	return float32(math.Round(float64(total)*100) / 100)
 }
 
 type BillOverview struct {
-	Id        int  `json:"id" db:"id" validate:"required,gte=1"`
-	StartDate Date `json:"start_date" db:"start_date" validate:"required"`
-	EndDate   Date `json:"end_date" db:"end_date" validate:"required"`
-	IsPaid    bool `json:"is_paid" db:"is_paid" validate:"required"`
+	Id              int        `json:"id" db:"id" validate:"required,gte=1"`
+	StartDate       Date       `json:"start_date" db:"start_date" validate:"required"`
+	EndDate         Date       `json:"end_date" db:"end_date" validate:"required"`
+	IsPaid          bool       `json:"is_paid" db:"is_paid" validate:"required"`
+	IsFinal         bool       `json:"is_final" db:"is_final"`
+	Status          string     `json:"status" db:"status"`
+	InvoiceNumber   *int       `json:"invoice_number" db:"invoice_number"`
+	IssuedAt        *time.Time `json:"issued_at" db:"issued_at"`
+	IssuedBy        *string    `json:"issued_by" db:"issued_by"`
+	PaidAt          *time.Time `json:"paid_at" db:"paid_at"`
+	PaidBy          *string    `json:"paid_by" db:"paid_by"`
+	VoidedAt        *time.Time `json:"voided_at" db:"voided_at"`
+	VoidedBy        *string    `json:"voided_by" db:"voided_by"`
+	VoidReason      *string    `json:"void_reason" db:"void_reason"`
+	JournalExportId *int       `json:"journal_export_id" db:"journal_export_id"`
+	PaymentMethod   *string    `json:"payment_method" db:"payment_method"`
+	PaymentDetails  *string    `json:"payment_details" db:"payment_details"`
+}
+
+type BillVoid struct {
+	Reason string `json:"reason" db:"reason" validate:"required,min=1,max=255"`
+}
+
+type BillSummary struct {
+	BillOverview
+	Total       float32 `json:"total" db:"total"`
+	Outstanding float32 `json:"outstanding" db:"outstanding"`
+}
+
+// BillAdjustment totals the adjustments with the same description across the orders of a bill
+type BillAdjustment struct {
+	Kind        string  `json:"kind" db:"kind"`
+	Description string  `json:"description" db:"description"`
+	Amount      float32 `json:"amount" db:"amount"`
 }
 
 type Bill struct {
 	BillOverview
-	Items []ItemOrder `json:"items" db:"items" validate:"required"`
+	Items       []ItemOrder      `json:"items" db:"items" validate:"required"`
+	Adjustments []BillAdjustment `json:"adjustments" db:"adjustments"`
+	Orders      []Order          `json:"orders" db:"orders"`
+	Total       float32          `json:"total" db:"total"`
+	AmountPaid  float32          `json:"amount_paid" db:"amount_paid"`
+	Outstanding float32          `json:"outstanding" db:"outstanding"`
+	Payments    []BillPayment    `json:"payments" db:"payments"`
+}
+
+type BillPaymentCreate struct {
+	Amount    float32 `json:"amount" db:"amount" validate:"required,gt=0"`
+	Method    string  `json:"method" db:"method" validate:"required,oneof='in person' 'chartstring'"`
+	Reference *string `json:"reference" db:"reference" validate:"omitempty,min=1,max=255"`
+	PaidOn    *Date   `json:"paid_on" db:"paid_on"`
+}
+
+type BillPayment struct {
+	Id         int       `json:"id" db:"id"`
+	Amount     float32   `json:"amount" db:"amount"`
+	Method     string    `json:"method" db:"method"`
+	Reference  *string   `json:"reference" db:"reference"`
+	PaidOn     Date      `json:"paid_on" db:"paid_on"`
+	RecordedBy string    `json:"recorded_by" db:"recorded_by"`
+	RecordedAt time.Time `json:"recorded_at" db:"recorded_at"`
 }
 
 /*
@@ -97,11 +244,18 @@ type TabBase struct {
 	EndDate             Date    `json:"end_date" db:"end_date" validate:"required"`
 	DailyStartTime      Time    `json:"daily_start_time" db:"daily_start_time" validate:"required"`
 	DailyEndTime        Time    `json:"daily_end_time" db:"daily_end_time" validate:"required"`
-	ActiveDaysOfWk      int8    `json:"active_days_of_wk" db:"active_days_of_wk"`
+	ActiveDaysOfWk      int8    `json:"active_days_of_wk" db:"active_days_of_wk" validate:"gte=1,lte=127"`
 	DollarLimitPerOrder float32 `json:"dollar_limit_per_order" db:"dollar_limit_per_order" validate:"gte=0"`
 	VerificationMethod  string  `json:"verification_method" db:"verification_method" validate:"required,oneof='specify' 'voucher' 'email'"`
 	PaymentDetails      string  `json:"payment_details" db:"payment_details"`
 	BillingIntervalDays int     `json:"billing_interval_days" db:"billing_interval_days" validate:"gte=1,lte=365"`
+	// TotalBudget limits the total spend on the tab, with zero meaning no limit
+	TotalBudget float32 `json:"total_budget" db:"total_budget" validate:"gte=0"`
+	// BudgetWarningThresholds are the percentages of the budget at which the tab owner is warned
+	BudgetWarningThresholds []int `json:"budget_warning_thresholds" db:"budget_warning_thresholds" validate:"max=10,dive,gte=1,lte=100"`
+	AllowTips               bool  `json:"allow_tips" db:"allow_tips"`
+	// MaxTipPercent caps tips as a percentage of the discounted order, and must be set when tips are allowed
+	MaxTipPercent float32 `json:"max_tip_percent" db:"max_tip_percent" validate:"required_if=AllowTips true,gte=0,lte=100"`
 }
 
 type TabUpdates struct {
@@ -134,16 +288,312 @@ type TabOverview struct {
 	Status           string      `json:"status" db:"status"`
 	IsPendingBalance bool        `json:"is_pending_balance" db:"is_pending_balance"`
 	Locations        []Location  `json:"locations" db:"locations"`
+	RemainingBudget  *float32    `json:"remaining_budget" db:"remaining_budget"`
+	RenewedFromTabId *int        `json:"renewed_from_tab_id" db:"renewed_from_tab_id"`
+	Managers         []string    `json:"managers" db:"managers"`
 }
 
 type Tab struct {
 	TabOverview
-	Bills []Bill `json:"bills" db:"bills" validate:"required,dive"`
+	Bills                    []Bill                `json:"bills" db:"bills" validate:"required,dive"`
+	LastUpdateRejection      *TabUpdateRejection   `json:"last_update_rejection" db:"last_update_rejection"`
+	PendingOwnershipTransfer *TabOwnershipTransfer `json:"pending_ownership_transfer" db:"pending_ownership_transfer"`
+}
+
+type UserTab struct {
+	TabOverview
+	ShopName          string       `json:"shop_name" db:"shop_name"`
+	IsOwner           bool         `json:"is_owner" db:"is_owner"`
+	IsManager         bool         `json:"is_manager" db:"is_manager"`
+	Balance           float32      `json:"balance" db:"balance"`
+	CurrentBill       *BillSummary `json:"current_bill" db:"current_bill"`
+	HasPendingUpdates bool         `json:"has_pending_updates" db:"has_pending_updates"`
+}
+
+const (
+	TabSortDisplayName  = "display_name"
+	TabSortOrganization = "organization"
+	TabSortStartDate    = "start_date"
+	TabSortEndDate      = "end_date"
+)
+
+// GetTabsQueryParams filters, sorts and pages a list of tabs. A limit of zero returns every tab on one page.
+type GetTabsQueryParams struct {
+	Statuses          []string    `json:"status" validate:"dive,oneof=pending confirmed closed expired rejected"`
+	Organization      *string     `json:"organization" validate:"omitempty,min=1,max=64"`
+	PaymentMethod     *string     `json:"payment_method" validate:"omitempty,oneof='in person' 'chartstring'"`
+	ActiveFrom        *Date       `json:"active_from"`
+	ActiveTo          *Date       `json:"active_to"`
+	IsPendingBalance  *bool       `json:"pending_balance"`
+	HasPendingUpdates *bool       `json:"pending_updates"`
+	OwnerId           *string     `json:"owner" validate:"omitempty,max=255"`
+	Search            *string     `json:"q" validate:"omitempty,min=1,max=64"`
+	Sort              string      `json:"sort" validate:"oneof=display_name organization start_date end_date"`
+	Descending        bool        `json:"-"`
+	Limit             int         `json:"limit" validate:"gte=0,lte=200"`
+	Cursor            *TabsCursor `json:"cursor"`
+}
+
+// TabsCursor marks the position after the last tab of a page in a sorted list of tabs
+type TabsCursor struct {
+	Sort       string `json:"s"`
+	Descending bool   `json:"d"`
+	Value      string `json:"v"`
+	Id         int    `json:"i"`
+}
+
+// TabsCursorAfter returns the cursor for the page following tab when sorted by sort in the given direction
+func TabsCursorAfter(tab *TabOverview, sort string, descending bool) *TabsCursor {
+	cursor := TabsCursor{Sort: sort, Descending: descending, Id: tab.Id}
+	switch sort {
+	case TabSortOrganization:
+		cursor.Value = tab.Organization
+	case TabSortStartDate:
+		cursor.Value = tab.StartDate.String()
+	case TabSortEndDate:
+		cursor.Value = tab.EndDate.String()
+	default:
+		cursor.Value = tab.DisplayName
+	}
+	return &cursor
+}
+
+func (c *TabsCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeTabsCursor(s string) (*TabsCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor := TabsCursor{}
+	err = json.Unmarshal(data, &cursor)
+	if err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
+
+type TabManagerCreate struct {
+	Email string `json:"email" db:"email" validate:"required,email,max=255"`
+}
+
+type TabOwnershipTransferCreate struct {
+	Email string `json:"email" db:"email" validate:"required,email,max=255"`
+}
+
+type TabOwnershipTransfer struct {
+	Email       string    `json:"email" db:"email"`
+	RequestedBy string    `json:"requested_by" db:"requested_by"`
+	RequestedAt time.Time `json:"requested_at" db:"requested_at"`
+}
+
+// IsManager reports whether the user with the email may manage the tab on behalf of its owner
+func (t *TabOverview) IsManager(email string) bool {
+	for _, manager := range t.Managers {
+		if strings.EqualFold(manager, email) {
+			return true
+		}
+	}
+	return false
+}
+
+// TabRenew describes the new term of a tab renewed from an existing one
+type TabRenew struct {
+	StartDate   Date    `json:"start_date" db:"start_date" validate:"required"`
+	EndDate     Date    `json:"end_date" db:"end_date" validate:"required"`
+	DisplayName *string `json:"display_name" db:"display_name" validate:"omitempty,min=3,max=64"`
+}
+
+// Renewal creates a tab for a new term with the same settings, locations and verification list as t
+func (t *Tab) Renewal(data *TabRenew) TabCreate {
+	base := t.TabBase
+	base.StartDate = data.StartDate
+	base.EndDate = data.EndDate
+	if data.DisplayName != nil {
+		base.DisplayName = *data.DisplayName
+	}
+
+	locationIds := make([]int, 0, len(t.Locations))
+	for _, location := range t.Locations {
+		locationIds = append(locationIds, int(location.Id))
+	}
+
+	verificationList := make([]string, len(t.VerificationList))
+	copy(verificationList, t.VerificationList)
+
+	return TabCreate{
+		TabUpdate: TabUpdate{
+			TabBase:          base,
+			VerificationList: verificationList,
+			LocationIds:      locationIds,
+		},
+		ShopId:  t.ShopId,
+		OwnerId: t.OwnerId,
+	}
+}
+
+type TabUpdateReject struct {
+	Message *string `json:"message" db:"message" validate:"omitempty,min=1,max=1024"`
+}
+
+type TabUpdateRejection struct {
+	Message    *string   `json:"message" db:"message"`
+	RejectedBy string    `json:"rejected_by" db:"rejected_by"`
+	RejectedAt time.Time `json:"rejected_at" db:"rejected_at"`
+}
+
+type TabFieldDiff struct {
+	Field   string      `json:"field"`
+	Current interface{} `json:"current"`
+	Pending interface{} `json:"pending"`
+}
+
+// PendingUpdateDiff lists the fields of the tab which approving its pending updates would change
+func (t *TabOverview) PendingUpdateDiff() []TabFieldDiff {
+	diffs := make([]TabFieldDiff, 0)
+	if t.PendingUpdates == nil {
+		return diffs
+	}
+
+	currentBase := t.TabBase
+	currentBase.NormalizeBudgetWarnings()
+	pendingBase := t.PendingUpdates.TabBase
+	pendingBase.NormalizeBudgetWarnings()
+	current := reflect.ValueOf(currentBase)
+	pending := reflect.ValueOf(pendingBase)
+	for i := 0; i < current.NumField(); i++ {
+		currentValue := current.Field(i).Interface()
+		pendingValue := pending.Field(i).Interface()
+		if reflect.DeepEqual(currentValue, pendingValue) {
+			continue
+		}
+
+		field := current.Type().Field(i)
+		tag, ok := field.Tag.Lookup("json")
+		if !ok {
+			tag = field.Name
+		}
+		diffs = append(diffs, TabFieldDiff{Field: tag, Current: currentValue, Pending: pendingValue})
+	}
+
+	currentLocationIds := make([]uint, 0, len(t.Locations))
+	for _, location := range t.Locations {
+		currentLocationIds = append(currentLocationIds, location.Id)
+	}
+	pendingLocationIds := make([]uint, 0, len(t.PendingUpdates.Locations))
+	for _, location := range t.PendingUpdates.Locations {
+		pendingLocationIds = append(pendingLocationIds, location.Id)
+	}
+	slices.Sort(currentLocationIds)
+	slices.Sort(pendingLocationIds)
+	if !slices.Equal(currentLocationIds, pendingLocationIds) {
+		diffs = append(diffs, TabFieldDiff{Field: "locations", Current: t.Locations, Pending: t.PendingUpdates.Locations})
+	}
+
+	return diffs
 }
 
-func (t *TabOverview) IsActive() bool {
-	today := DateOf(time.Now())
-	return t.Status == TAB_STATUS_CONFIRMED.String() && !t.StartDate.After(today.Date) && !t.EndDate.Before(today.Date)
+type TabInactiveError struct {
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+func (e *TabInactiveError) Error() string {
+	return e.Message
+}
+
+// NormalizeBudgetWarnings sorts the budget warning thresholds and removes duplicates,
+// so that settings which warn at the same points compare equal.
+func (t *TabBase) NormalizeBudgetWarnings() {
+	thresholds := slices.Clone(t.BudgetWarningThresholds)
+	if thresholds == nil {
+		thresholds = make([]int, 0)
+	}
+	slices.Sort(thresholds)
+	t.BudgetWarningThresholds = slices.Compact(thresholds)
+}
+
+// CrossedBudgetWarnings returns the warning thresholds reached by spending from before to after
+func (t *TabBase) CrossedBudgetWarnings(before float32, after float32) []int {
+	crossed := make([]int, 0)
+	if t.TotalBudget == 0 {
+		return crossed
+	}
+
+	for _, threshold := range t.BudgetWarningThresholds {
+		limit := t.TotalBudget * float32(threshold) / 100
+		if before < limit && after >= limit {
+			crossed = append(crossed, threshold)
+		}
+	}
+	return crossed
+}
+
+// HighestBudgetWarning returns the highest warning threshold reached by spending from before to after,
+// as it supersedes any lower thresholds reached at the same time
+func (t *TabBase) HighestBudgetWarning(before float32, after float32) (int, bool) {
+	crossed := t.CrossedBudgetWarnings(before, after)
+	if len(crossed) == 0 {
+		return 0, false
+	}
+	return slices.Max(crossed), true
+}
+
+// ExceedsBudget reports whether an order would take the tab's total spend over its budget.
+// Spend is compared to the cent, so that rounding errors do not reject orders which exactly use up the budget.
+func (t *TabBase) ExceedsBudget(spent float32, total float32) bool {
+	if t.TotalBudget == 0 {
+		return false
+	}
+	return float32(math.Round(float64(spent+total)*100)/100) > t.TotalBudget
+}
+
+// IsActiveOn reports whether the bit for the given weekday is set, with Sunday as the lowest bit
+func (t *TabBase) IsActiveOn(day time.Weekday) bool {
+	return t.ActiveDaysOfWk&(1<<day) != 0
+}
+
+func (t *TabOverview) HasLocation(locationId int) bool {
+	for _, location := range t.Locations {
+		if int(location.Id) == locationId {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckActive returns a TabInactiveError describing why the tab cannot be charged at the
+// given time, which should be expressed in the shop's time zone.
+func (t *TabOverview) CheckActive(now time.Time) error {
+	if t.Status != TAB_STATUS_CONFIRMED.String() {
+		return &TabInactiveError{Reason: "status", Message: fmt.Sprintf("Tab is %v", t.Status)}
+	}
+
+	today := DateOf(now)
+	if t.StartDate.After(today.Date) {
+		return &TabInactiveError{Reason: "start_date", Message: fmt.Sprintf("Tab does not start until %v", t.StartDate)}
+	}
+	if t.EndDate.Before(today.Date) {
+		return &TabInactiveError{Reason: "end_date", Message: fmt.Sprintf("Tab ended on %v", t.EndDate)}
+	}
+
+	if !t.IsActiveOn(now.Weekday()) {
+		return &TabInactiveError{Reason: "active_days_of_wk", Message: fmt.Sprintf("Tab is not active on %v", now.Weekday())}
+	}
+
+	timeOfDay := Time{Duration: time.Duration(now.Hour())*time.Hour + time.Duration(now.Minute())*time.Minute}
+	if timeOfDay.Duration < t.DailyStartTime.Duration || timeOfDay.Duration > t.DailyEndTime.Duration {
+		return &TabInactiveError{
+			Reason:  "daily_hours",
+			Message: fmt.Sprintf("Tab is only active between %v and %v", t.DailyStartTime.String(), t.DailyEndTime.String()),
+		}
+	}
+
+	return nil
 }
 
 func TabUpdateStructLevelValidation(sl validator.StructLevel) {
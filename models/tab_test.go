@@ -0,0 +1,204 @@
+package models
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+)
+
+func TestIsActiveOn(t *testing.T) {
+	tests := []struct {
+		days     int8
+		active   []time.Weekday
+		inactive []time.Weekday
+	}{
+		{1, []time.Weekday{time.Sunday}, []time.Weekday{time.Monday, time.Saturday}},
+		{1 << 6, []time.Weekday{time.Saturday}, []time.Weekday{time.Sunday, time.Friday}},
+		// Monday to Friday
+		{0b0111110, []time.Weekday{time.Monday, time.Wednesday, time.Friday}, []time.Weekday{time.Sunday, time.Saturday}},
+		{127, []time.Weekday{time.Sunday, time.Monday, time.Saturday}, nil},
+		{0, nil, []time.Weekday{time.Sunday, time.Monday, time.Saturday}},
+	}
+
+	for _, test := range tests {
+		tab := TabBase{ActiveDaysOfWk: test.days}
+		for _, day := range test.active {
+			if !tab.IsActiveOn(day) {
+				t.Errorf("expected days %07b to include %v", test.days, day)
+			}
+		}
+		for _, day := range test.inactive {
+			if tab.IsActiveOn(day) {
+				t.Errorf("expected days %07b not to include %v", test.days, day)
+			}
+		}
+	}
+}
+
+func TestCheckActive(t *testing.T) {
+	// Active on weekdays from 9:00 to 17:00 through March 2026
+	tab := TabOverview{Status: TAB_STATUS_CONFIRMED.String()}
+	tab.StartDate = Date{Date: civil.Date{Year: 2026, Month: time.March, Day: 2}}
+	tab.EndDate = Date{Date: civil.Date{Year: 2026, Month: time.March, Day: 31}}
+	tab.ActiveDaysOfWk = 0b0111110
+	tab.DailyStartTime = Time{Duration: 9 * time.Hour}
+	tab.DailyEndTime = Time{Duration: 17 * time.Hour}
+
+	at := func(day int, hour int, minute int, second int) time.Time {
+		return time.Date(2026, time.March, day, hour, minute, second, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name   string
+		status TabStatus
+		now    time.Time
+		reason string
+	}{
+		{"during the day", TAB_STATUS_CONFIRMED, at(4, 12, 0, 0), ""},
+		{"start of the daily window", TAB_STATUS_CONFIRMED, at(4, 9, 0, 0), ""},
+		{"before the daily window", TAB_STATUS_CONFIRMED, at(4, 8, 59, 59), "daily_hours"},
+		{"last minute of the daily window", TAB_STATUS_CONFIRMED, at(4, 17, 0, 59), ""},
+		{"after the daily window", TAB_STATUS_CONFIRMED, at(4, 17, 1, 0), "daily_hours"},
+		{"sunday", TAB_STATUS_CONFIRMED, at(8, 12, 0, 0), "active_days_of_wk"},
+		{"saturday", TAB_STATUS_CONFIRMED, at(7, 12, 0, 0), "active_days_of_wk"},
+		{"first day", TAB_STATUS_CONFIRMED, at(2, 12, 0, 0), ""},
+		{"before the first day", TAB_STATUS_CONFIRMED, time.Date(2026, time.February, 27, 12, 0, 0, 0, time.UTC), "start_date"},
+		{"last day", TAB_STATUS_CONFIRMED, at(31, 12, 0, 0), ""},
+		{"after the last day", TAB_STATUS_CONFIRMED, time.Date(2026, time.April, 1, 12, 0, 0, 0, time.UTC), "end_date"},
+		{"pending", TAB_STATUS_PENDING, at(4, 12, 0, 0), "status"},
+		{"closed", TAB_STATUS_CLOSED, at(4, 12, 0, 0), "status"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tab := tab
+			tab.Status = test.status.String()
+
+			err := tab.CheckActive(test.now)
+			if test.reason == "" {
+				if err != nil {
+					t.Fatalf("expected the tab to be active, got %v", err)
+				}
+				return
+			}
+
+			var inactive *TabInactiveError
+			if !errors.As(err, &inactive) {
+				t.Fatalf("expected a TabInactiveError, got %v", err)
+			}
+			if inactive.Reason != test.reason {
+				t.Errorf("expected reason %v, got %v", test.reason, inactive.Reason)
+			}
+		})
+	}
+}
+
+func TestCheckActiveUsesTimeZoneOfNow(t *testing.T) {
+	tab := TabOverview{Status: TAB_STATUS_CONFIRMED.String()}
+	tab.StartDate = Date{Date: civil.Date{Year: 2026, Month: time.March, Day: 1}}
+	tab.EndDate = Date{Date: civil.Date{Year: 2026, Month: time.March, Day: 31}}
+	tab.ActiveDaysOfWk = 0b0111110
+	tab.DailyStartTime = Time{Duration: 9 * time.Hour}
+	tab.DailyEndTime = Time{Duration: 17 * time.Hour}
+
+	loc, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skip("time zone database is not available")
+	}
+
+	// Saturday at 1:00 in UTC is still Friday afternoon in Los Angeles
+	now := time.Date(2026, time.March, 7, 1, 0, 0, 0, time.UTC)
+	if err := tab.CheckActive(now); err == nil {
+		t.Errorf("expected the tab to be inactive on Saturday in UTC")
+	}
+	if err := tab.CheckActive(now.In(loc)); err != nil {
+		t.Errorf("expected the tab to be active on Friday afternoon in Los Angeles, got %v", err)
+	}
+}
+
+func TestCrossedBudgetWarnings(t *testing.T) {
+	tab := TabBase{TotalBudget: 200, BudgetWarningThresholds: []int{50, 75, 90}}
+
+	tests := []struct {
+		name     string
+		before   float32
+		after    float32
+		expected []int
+	}{
+		{"below every threshold", 0, 99.99, []int{}},
+		{"reaching a threshold exactly", 99.99, 100, []int{50}},
+		{"one order crossing several thresholds", 20, 185, []int{50, 75, 90}},
+		{"thresholds already crossed", 100, 160, []int{75}},
+		{"over the budget", 190, 250, []int{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := tab.CrossedBudgetWarnings(test.before, test.after)
+			if !slices.Equal(got, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, got)
+			}
+		})
+	}
+
+	unlimited := TabBase{BudgetWarningThresholds: []int{50}}
+	if got := unlimited.CrossedBudgetWarnings(0, 1000); len(got) != 0 {
+		t.Errorf("expected no warnings without a budget, got %v", got)
+	}
+}
+
+func TestHighestBudgetWarning(t *testing.T) {
+	tests := []struct {
+		name       string
+		thresholds []int
+		before     float32
+		after      float32
+		expected   int
+		warned     bool
+	}{
+		{"no threshold reached", []int{50, 75, 90}, 0, 40, 0, false},
+		{"one threshold reached", []int{50, 75, 90}, 90, 110, 50, true},
+		{"only the highest of several is emailed", []int{50, 75, 90}, 20, 185, 90, true},
+		{"thresholds in any order", []int{90, 50, 75}, 20, 160, 75, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tab := TabBase{TotalBudget: 200, BudgetWarningThresholds: test.thresholds}
+			threshold, warned := tab.HighestBudgetWarning(test.before, test.after)
+			if threshold != test.expected || warned != test.warned {
+				t.Errorf("expected %v %v, got %v %v", test.expected, test.warned, threshold, warned)
+			}
+		})
+	}
+}
+
+func TestExceedsBudget(t *testing.T) {
+	tests := []struct {
+		name     string
+		budget   float32
+		spent    float32
+		total    float32
+		expected bool
+	}{
+		{"under the budget", 100, 50, 49.99, false},
+		{"exactly at the budget", 100, 50, 50, false},
+		// The float sum of these is slightly above 30.3, but rounds to the budget
+		{"exactly at the budget after rounding", 30.3, 10.1, 20.2, false},
+		{"one cent over the budget", 100, 50, 50.01, true},
+		{"spend already over the budget", 100, 100.01, 0, true},
+		{"no budget", 0, 5000, 100, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tab := TabBase{TotalBudget: test.budget}
+			if got := tab.ExceedsBudget(test.spent, test.total); got != test.expected {
+				t.Errorf("expected %v, got %v", test.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package models
+
+import "math"
+
+type TipsReportQueryParams struct {
+	// From and To limit the report to orders placed on or between the dates in the shop's time zone
+	From *Date
+	To   *Date
+}
+
+// TipsReportEntry totals the tips left on the orders taken by a member of staff at a location.
+// Tips which were voided are deducted from the staff member who took the original order.
+type TipsReportEntry struct {
+	UserId       *string `json:"user_id" db:"user_id"`
+	UserName     *string `json:"user_name" db:"user_name"`
+	LocationId   *int    `json:"location_id" db:"location_id"`
+	LocationName *string `json:"location_name" db:"location_name"`
+	OrderCount   int     `json:"order_count" db:"order_count"`
+	Tips         float32 `json:"tips" db:"tips"`
+}
+
+// Tip returns a tip adjustment of the amount, rounded to the cent
+func Tip(amount float32) OrderAdjustment {
+	return OrderAdjustment{
+		Kind:        ORDER_ADJUSTMENT_KIND_TIP.String(),
+		Description: "Tip",
+		Amount:      float32(math.Round(float64(amount)*100) / 100),
+	}
+}
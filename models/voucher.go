@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+type VoucherBatchCreate struct {
+	Count     int   `json:"count" validate:"required,gte=1,lte=500"`
+	MaxUses   int   `json:"max_uses" validate:"required,gte=1"`
+	ExpiresOn *Date `json:"expires_on"`
+}
+
+type Voucher struct {
+	Id        int       `json:"id" db:"id"`
+	Code      string    `json:"code" db:"code"`
+	MaxUses   int       `json:"max_uses" db:"max_uses"`
+	Uses      int       `json:"uses" db:"uses"`
+	ExpiresOn *Date     `json:"expires_on" db:"expires_on"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+func (v *Voucher) IsExpired(today Date) bool {
+	return v.ExpiresOn != nil && v.ExpiresOn.Before(today.Date)
+}
+
+func (v *Voucher) IsRedeemed() bool {
+	return v.Uses >= v.MaxUses
+}
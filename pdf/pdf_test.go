@@ -0,0 +1,214 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type parsedDocument struct {
+	objects map[int]string
+	pages   []string
+}
+
+var (
+	startXrefPattern = regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`)
+	objectPattern    = regexp.MustCompile(`^(\d+) 0 obj\n`)
+	countPattern     = regexp.MustCompile(`/Count (\d+)`)
+	kidsPattern      = regexp.MustCompile(`/Kids \[([^\]]*)\]`)
+	contentsPattern  = regexp.MustCompile(`/Contents (\d+) 0 R`)
+	streamPattern    = regexp.MustCompile(`^<< /Length (\d+) >>\nstream\n`)
+	showTextPattern  = regexp.MustCompile(`\(((?:\\.|[^\\)])*)\) Tj`)
+)
+
+// parse reads back a document written by WriteTo, following the cross reference table to each object
+// and failing the test if any offset or length does not match
+func parse(t *testing.T, b []byte) parsedDocument {
+	t.Helper()
+	if !bytes.HasPrefix(b, []byte("%PDF-1.4\n")) {
+		t.Fatalf("missing PDF header")
+	}
+
+	match := startXrefPattern.FindSubmatch(b)
+	if match == nil {
+		t.Fatalf("missing startxref")
+	}
+	xref, _ := strconv.Atoi(string(match[1]))
+	if !bytes.HasPrefix(b[xref:], []byte("xref\n")) {
+		t.Fatalf("startxref %d does not point to the cross reference table", xref)
+	}
+
+	lines := strings.Split(string(b[xref:]), "\n")
+	var first, size int
+	fmt.Sscanf(lines[1], "%d %d", &first, &size)
+	if first != 0 || lines[2] != "0000000000 65535 f " {
+		t.Fatalf("unexpected start of cross reference table %q", lines[1:3])
+	}
+	if !strings.Contains(string(b[xref:]), fmt.Sprintf("/Size %d ", size)) {
+		t.Errorf("expected trailer size %d", size)
+	}
+
+	doc := parsedDocument{objects: map[int]string{}}
+	for id := 1; id < size; id++ {
+		entry := lines[2+id]
+		if len(entry) != 19 || !strings.HasSuffix(entry, " 00000 n ") {
+			t.Fatalf("malformed cross reference entry %q", entry)
+		}
+		offset, _ := strconv.Atoi(entry[:10])
+		header := objectPattern.FindSubmatch(b[offset:])
+		if header == nil || string(header[1]) != strconv.Itoa(id) {
+			t.Fatalf("offset %d of object %d does not point to its start", offset, id)
+		}
+		body := b[offset+len(header[0]):]
+		end := bytes.Index(body, []byte("endobj\n"))
+		if end < 0 {
+			t.Fatalf("object %d is not ended", id)
+		}
+		doc.objects[id] = string(body[:end])
+	}
+
+	count := countPattern.FindStringSubmatch(doc.objects[2])
+	kids := kidsPattern.FindStringSubmatch(doc.objects[2])
+	if count == nil || kids == nil {
+		t.Fatalf("malformed page tree %q", doc.objects[2])
+	}
+	refs := strings.Fields(kids[1])
+	if n, _ := strconv.Atoi(count[1]); n != len(refs)/3 {
+		t.Fatalf("page count %d does not match the %d kids", n, len(refs)/3)
+	}
+
+	for i := 0; i < len(refs); i += 3 {
+		pageId, _ := strconv.Atoi(refs[i])
+		page := doc.objects[pageId]
+		if !strings.Contains(page, "/Type /Page ") {
+			t.Fatalf("kid %d is not a page", pageId)
+		}
+		contents := contentsPattern.FindStringSubmatch(page)
+		if contents == nil {
+			t.Fatalf("page %d has no contents", pageId)
+		}
+		contentId, _ := strconv.Atoi(contents[1])
+		stream := doc.objects[contentId]
+		length := streamPattern.FindStringSubmatch(stream)
+		if length == nil {
+			t.Fatalf("contents of page %d are not a stream", pageId)
+		}
+		n, _ := strconv.Atoi(length[1])
+		content := stream[len(length[0]):]
+		if content[n:] != "endstream\n" {
+			t.Fatalf("stream length %d of page %d does not match its contents", n, pageId)
+		}
+		doc.pages = append(doc.pages, content[:n])
+	}
+	return doc
+}
+
+// unescape reverses the escaping of a PDF string literal
+func unescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// pageText returns the strings shown on a page in order
+func pageText(content string) []string {
+	var text []string
+	for _, match := range showTextPattern.FindAllStringSubmatch(content, -1) {
+		text = append(text, unescape(match[1]))
+	}
+	return text
+}
+
+func TestWriteToMultiplePages(t *testing.T) {
+	doc := New()
+	for i := 0; i < 3; i++ {
+		page := doc.AddPage()
+		page.Text(72, 720, 12, FontBold, fmt.Sprintf("Page %d", i+1))
+		page.Text(72, 700, 12, FontRegular, `Total (incl. tax) \ 5%`)
+		page.Line(72, 690, 540, 690, 0.5)
+	}
+
+	var buf bytes.Buffer
+	n, err := doc.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("expected WriteTo to report %d bytes, got %d", buf.Len(), n)
+	}
+
+	parsed := parse(t, buf.Bytes())
+	if len(parsed.pages) != 3 {
+		t.Fatalf("expected 3 pages, got %d", len(parsed.pages))
+	}
+	for i, content := range parsed.pages {
+		expected := []string{fmt.Sprintf("Page %d", i+1), `Total (incl. tax) \ 5%`}
+		text := pageText(content)
+		if strings.Join(text, "|") != strings.Join(expected, "|") {
+			t.Errorf("expected page %d to show %q, got %q", i+1, expected, text)
+		}
+	}
+}
+
+func TestWriteToEmptyDocument(t *testing.T) {
+	var buf bytes.Buffer
+	_, err := New().WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed := parse(t, buf.Bytes())
+	if len(parsed.pages) != 0 {
+		t.Fatalf("expected no pages, got %d", len(parsed.pages))
+	}
+}
+
+func TestEncodeText(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected string
+	}{
+		{"Coffee", "Coffee"},
+		{`(a) \ b`, `\(a\) \\ b`},
+		{"Café", "Caf\xe9"},
+		{"Tea ☕", "Tea ?"},
+		{"a\nb", "a?b"},
+	}
+	for _, test := range tests {
+		if got := encodeText(test.in); got != test.expected {
+			t.Errorf("encodeText(%q): expected %q, got %q", test.in, test.expected, got)
+		}
+	}
+}
+
+func TestTruncateAndWrap(t *testing.T) {
+	if got := Truncate("Coffee", 10, FontRegular, 100); got != "Coffee" {
+		t.Errorf("expected text which fits not to be truncated, got %q", got)
+	}
+
+	truncated := Truncate("A very long description of an item", 10, FontRegular, 60)
+	if !strings.HasSuffix(truncated, "...") || TextWidth(truncated, 10, FontRegular) > 60 {
+		t.Errorf("expected text to be truncated to fit, got %q", truncated)
+	}
+
+	lines := Wrap("one two three four five\nsix", 10, FontRegular, 50)
+	for _, line := range lines {
+		if TextWidth(line, 10, FontRegular) > 50 {
+			t.Errorf("expected %q to fit within the width", line)
+		}
+	}
+	if strings.Join(lines, " ") != "one two three four five six" {
+		t.Errorf("expected wrapped lines to keep every word, got %q", lines)
+	}
+	if lines[len(lines)-1] != "six" {
+		t.Errorf("expected the line break to be preserved, got %q", lines)
+	}
+}
@@ -0,0 +1,148 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/WilliamTrojniak/TabAppBackend/db"
+	"github.com/WilliamTrojniak/TabAppBackend/models"
+	"github.com/WilliamTrojniak/TabAppBackend/util"
+)
+
+const (
+	sendBatchSize = 20
+	maxAttempts   = 8
+	retryBackoff  = time.Minute
+	maxBackoff    = time.Hour * 6
+	// claimLease is how long a claimed email is left for its sender before it may be claimed again
+	claimLease = time.Minute * 10
+)
+
+type Handler struct {
+	logger    *slog.Logger
+	store     *db.PgxStore
+	transport Transport
+	clock     util.Clock
+	from      string
+	templates map[Template]*emailTemplate
+}
+
+func NewHandler(store *db.PgxStore, transport Transport, clock util.Clock, config *Config, logger *slog.Logger) (*Handler, error) {
+	templates, err := parseTemplates(config.UIURI)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Handler{
+		logger:    logger,
+		store:     store,
+		transport: transport,
+		clock:     clock,
+		from:      config.From,
+		templates: templates,
+	}, nil
+}
+
+// Enqueue renders an email and adds it to the outbox as part of the caller's transaction,
+// so that it is only sent if the transaction commits.
+func (h *Handler) Enqueue(ctx context.Context, pq *db.PgxQueries, template Template, recipient string, data any) error {
+	t, ok := h.templates[template]
+	if !ok {
+		return fmt.Errorf("Unknown email template %q", template)
+	}
+
+	subject, textBody, htmlBody, err := t.render(data)
+	if err != nil {
+		return err
+	}
+
+	h.logger.Debug("Enqueueing email", "template", template, "recipient", recipient)
+	return pq.EnqueueEmail(ctx, &models.EmailCreate{
+		Recipient: recipient,
+		Template:  string(template),
+		Subject:   subject,
+		TextBody:  textBody,
+		HtmlBody:  htmlBody,
+	})
+}
+
+// Run sends due emails from the outbox immediately and then once every interval until the context is cancelled.
+func (h *Handler) Run(ctx context.Context, interval time.Duration) {
+	h.logger.Info("Starting email sender", "interval", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := h.SendPending(ctx)
+		if err != nil {
+			h.logger.Error("Failed to send emails", "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			h.logger.Info("Stopping email sender")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SendPending sends every email in the outbox which is due, in batches. Each batch is claimed in its
+// own transaction, and each email is marked sent or failed in another, so that no locks are held
+// while talking to the transport and a failure only affects the email it occurred on.
+func (h *Handler) SendPending(ctx context.Context) error {
+	for {
+		now := h.clock.Now()
+		emails, err := db.WithTxRet(ctx, h.store, func(pq *db.PgxQueries) ([]models.Email, error) {
+			return pq.ClaimEmails(ctx, now, now.Add(claimLease), sendBatchSize)
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, email := range emails {
+			// The email is claimed again once its lease expires if its result could not be recorded
+			err = h.send(ctx, &email)
+			if err != nil {
+				h.logger.Error("Failed to record email result", "emailId", email.Id, "err", err)
+			}
+		}
+
+		if len(emails) < sendBatchSize {
+			return nil
+		}
+	}
+}
+
+// send delivers a claimed email, scheduling a retry with exponential backoff if the transport fails
+func (h *Handler) send(ctx context.Context, email *models.Email) error {
+	sendErr := h.transport.Send(ctx, &Message{
+		From:     h.from,
+		To:       email.Recipient,
+		Subject:  email.Subject,
+		TextBody: email.TextBody,
+		HtmlBody: email.HtmlBody,
+	})
+	now := h.clock.Now()
+	if sendErr == nil {
+		h.logger.Debug("Sent email", "emailId", email.Id, "template", email.Template)
+		return db.WithTx(ctx, h.store, func(pq *db.PgxQueries) error {
+			return pq.MarkEmailSent(ctx, email.Id, now)
+		})
+	}
+
+	var nextAttemptAt *time.Time
+	if email.Attempts+1 < maxAttempts {
+		backoff := min(retryBackoff<<email.Attempts, maxBackoff)
+		next := now.Add(backoff)
+		nextAttemptAt = &next
+		h.logger.Warn("Failed to send email", "emailId", email.Id, "attempt", email.Attempts+1, "retryAt", next, "err", sendErr)
+	} else {
+		h.logger.Error("Giving up sending email", "emailId", email.Id, "attempts", email.Attempts+1, "err", sendErr)
+	}
+	return db.WithTx(ctx, h.store, func(pq *db.PgxQueries) error {
+		return pq.MarkEmailAttemptFailed(ctx, email.Id, now, nextAttemptAt, sendErr.Error())
+	})
+}
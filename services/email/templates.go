@@ -0,0 +1,128 @@
+package email
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/WilliamTrojniak/TabAppBackend/models"
+)
+
+type Template string
+
+const (
+	TEMPLATE_SHOP_INVITE          Template = "shop_invite"
+	TEMPLATE_TAB_REQUESTED        Template = "tab_requested"
+	TEMPLATE_TAB_UPDATE_REQUESTED Template = "tab_update_requested"
+	TEMPLATE_TAB_UPDATE_REJECTED  Template = "tab_update_rejected"
+	TEMPLATE_TAB_APPROVED         Template = "tab_approved"
+	TEMPLATE_TAB_REJECTED         Template = "tab_rejected"
+	TEMPLATE_BILL_ISSUED          Template = "bill_issued"
+	TEMPLATE_BUDGET_WARNING       Template = "budget_warning"
+	TEMPLATE_TAB_TRANSFER         Template = "tab_transfer_requested"
+)
+
+var allTemplates = []Template{
+	TEMPLATE_SHOP_INVITE,
+	TEMPLATE_TAB_REQUESTED,
+	TEMPLATE_TAB_UPDATE_REQUESTED,
+	TEMPLATE_TAB_UPDATE_REJECTED,
+	TEMPLATE_TAB_APPROVED,
+	TEMPLATE_TAB_REJECTED,
+	TEMPLATE_BILL_ISSUED,
+	TEMPLATE_BUDGET_WARNING,
+	TEMPLATE_TAB_TRANSFER,
+}
+
+type ShopInviteData struct {
+	ShopId   int
+	ShopName string
+}
+
+// TabData is used by the templates for tab events
+type TabData struct {
+	ShopId   int
+	ShopName string
+	TabId    int
+	TabName  string
+}
+
+type TabUpdateRejectedData struct {
+	TabData
+	Message *string
+}
+
+type BillIssuedData struct {
+	TabData
+	InvoiceNumber int
+	StartDate     models.Date
+	EndDate       models.Date
+	Total         float32
+}
+
+type BudgetWarningData struct {
+	TabData
+	Threshold   int
+	TotalBudget float32
+	Spent       float32
+}
+
+//go:embed templates/*.tmpl
+var templateFiles embed.FS
+
+// emailTemplate renders the subject and plain text body with text/template, and the HTML body with
+// html/template so that values are escaped.
+type emailTemplate struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+func parseTemplates(uiUri string) (map[Template]*emailTemplate, error) {
+	url := func(format string, args ...any) string {
+		return uiUri + fmt.Sprintf(format, args...)
+	}
+
+	templates := make(map[Template]*emailTemplate, len(allTemplates))
+	for _, name := range allTemplates {
+		file := fmt.Sprintf("templates/%v.tmpl", name)
+		text, err := texttemplate.New(string(name)).Funcs(texttemplate.FuncMap{"url": url}).ParseFS(templateFiles, file)
+		if err != nil {
+			return nil, err
+		}
+		html, err := htmltemplate.New(string(name)).Funcs(htmltemplate.FuncMap{"url": url}).ParseFS(templateFiles, file)
+		if err != nil {
+			return nil, err
+		}
+		templates[name] = &emailTemplate{text: text, html: html}
+	}
+	return templates, nil
+}
+
+func (t *emailTemplate) render(data any) (subject string, textBody string, htmlBody string, err error) {
+	var buf bytes.Buffer
+	err = t.text.ExecuteTemplate(&buf, "subject", data)
+	if err != nil {
+		return "", "", "", err
+	}
+	// Subjects are a single line
+	subject = strings.Join(strings.Fields(buf.String()), " ")
+
+	buf.Reset()
+	err = t.text.ExecuteTemplate(&buf, "text", data)
+	if err != nil {
+		return "", "", "", err
+	}
+	textBody = buf.String()
+
+	buf.Reset()
+	err = t.html.ExecuteTemplate(&buf, "html", data)
+	if err != nil {
+		return "", "", "", err
+	}
+	htmlBody = buf.String()
+
+	return subject, textBody, htmlBody, nil
+}
@@ -0,0 +1,226 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	TRANSPORT_SMTP = "smtp"
+	TRANSPORT_FILE = "file"
+	TRANSPORT_LOG  = "log"
+
+	// smtpTimeout bounds the whole exchange with the SMTP server for each message
+	smtpTimeout = time.Second * 30
+)
+
+type Message struct {
+	From     string
+	To       string
+	Subject  string
+	TextBody string
+	HtmlBody string
+}
+
+type Transport interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// Config holds the settings emails are sent with. Only the settings of the selected transport are used.
+type Config struct {
+	UIURI        string
+	From         string
+	Transport    string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	OutboxDir    string
+}
+
+// NewTransport creates the transport selected by the config
+func NewTransport(config *Config, logger *slog.Logger) (Transport, error) {
+	switch config.Transport {
+	case TRANSPORT_SMTP:
+		return &SMTPTransport{
+			Host:     config.SMTPHost,
+			Port:     config.SMTPPort,
+			Username: config.SMTPUsername,
+			Password: config.SMTPPassword,
+		}, nil
+	case TRANSPORT_FILE:
+		return &FileTransport{Dir: config.OutboxDir}, nil
+	case TRANSPORT_LOG:
+		return &LogTransport{logger: logger}, nil
+	default:
+		return nil, fmt.Errorf("Unknown email transport %q", config.Transport)
+	}
+}
+
+type SMTPTransport struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+}
+
+func (t *SMTPTransport) Send(ctx context.Context, msg *Message) error {
+	data, err := encodeMessage(msg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, smtpTimeout)
+	defer cancel()
+
+	dialer := net.Dialer{Timeout: smtpTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(t.Host, t.Port))
+	if err != nil {
+		return err
+	}
+	// Abandon the exchange if the server stops responding or the sender is stopped
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	deadline, _ := ctx.Deadline()
+	err = conn.SetDeadline(deadline)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, t.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	// As with smtp.SendMail, upgrade to TLS when offered and authenticate when configured
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		err = client.StartTLS(&tls.Config{ServerName: t.Host})
+		if err != nil {
+			return err
+		}
+	}
+	if t.Username != "" {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return errors.New("SMTP server does not support authentication")
+		}
+		err = client.Auth(smtp.PlainAuth("", t.Username, t.Password, t.Host))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = client.Mail(msg.From)
+	if err != nil {
+		return err
+	}
+	err = client.Rcpt(msg.To)
+	if err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	if err != nil {
+		return err
+	}
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// FileTransport writes each message to an .eml file in Dir instead of sending it
+type FileTransport struct {
+	Dir string
+}
+
+func (t *FileTransport) Send(ctx context.Context, msg *Message) error {
+	now := time.Now()
+	data, err := encodeMessage(msg, now)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(t.Dir, 0o755)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%v-%v.eml", now.UTC().Format("20060102T150405.000000000"), strings.NewReplacer("@", "_at_", "/", "_").Replace(msg.To))
+	return os.WriteFile(filepath.Join(t.Dir, name), data, 0o644)
+}
+
+// LogTransport logs each message instead of sending it
+type LogTransport struct {
+	logger *slog.Logger
+}
+
+func (t *LogTransport) Send(ctx context.Context, msg *Message) error {
+	t.logger.Info("Email", "from", msg.From, "to", msg.To, "subject", msg.Subject, "body", msg.TextBody)
+	return nil
+}
+
+// encodeMessage builds a multipart/alternative MIME message with plain text and HTML bodies
+func encodeMessage(msg *Message, date time.Time) ([]byte, error) {
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=utf-8", msg.TextBody},
+		{"text/html; charset=utf-8", msg.HtmlBody},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		qp := quotedprintable.NewWriter(w)
+		_, err = qp.Write([]byte(part.content))
+		if err != nil {
+			return nil, err
+		}
+		err = qp.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	err := parts.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var data bytes.Buffer
+	fmt.Fprintf(&data, "From: %v\r\n", msg.From)
+	fmt.Fprintf(&data, "To: %v\r\n", msg.To)
+	fmt.Fprintf(&data, "Subject: %v\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&data, "Date: %v\r\n", date.Format(time.RFC1123Z))
+	data.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&data, "Content-Type: multipart/alternative; boundary=%v\r\n\r\n", parts.Boundary())
+	data.Write(body.Bytes())
+	return data.Bytes(), nil
+}
@@ -0,0 +1,151 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/WilliamTrojniak/TabAppBackend/db"
+	"github.com/WilliamTrojniak/TabAppBackend/util"
+)
+
+// Store runs each of the scheduler's queries in its own transaction
+type Store interface {
+	GetTabsDueExpiry(ctx context.Context, now time.Time) ([]db.TabKey, error)
+	ExpireTab(ctx context.Context, shopId int, tabId int, now time.Time) (bool, error)
+	GetTabsDueRollOver(ctx context.Context, now time.Time) ([]db.TabKey, error)
+	RollOverTabBills(ctx context.Context, shopId int, tabId int, now time.Time) (bool, error)
+}
+
+type Scheduler struct {
+	logger   *slog.Logger
+	store    Store
+	clock    util.Clock
+	interval time.Duration
+}
+
+func New(store *db.PgxStore, clock util.Clock, interval time.Duration, logger *slog.Logger) *Scheduler {
+	return NewWithStore(&PgxStore{store: store}, clock, interval, logger)
+}
+
+func NewWithStore(store Store, clock util.Clock, interval time.Duration, logger *slog.Logger) *Scheduler {
+	return &Scheduler{
+		logger:   logger,
+		store:    store,
+		clock:    clock,
+		interval: interval,
+	}
+}
+
+// Run performs the scheduled jobs immediately and then once every interval until the context is cancelled.
+func (s *Scheduler) Run(ctx context.Context) {
+	s.logger.Info("Starting scheduler", "interval", s.interval)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.RunOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			s.logger.Info("Stopping scheduler")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce expires tabs before rolling over bills, so that tabs which have ended are finalized rather
+// than rolled over. A job which fails is logged and does not stop the other from running.
+func (s *Scheduler) RunOnce(ctx context.Context) {
+	err := s.ExpireTabs(ctx)
+	if err != nil {
+		s.logger.Error("Failed to expire tabs", "err", err)
+	}
+
+	err = s.RollOverBills(ctx)
+	if err != nil {
+		s.logger.Error("Failed to roll over tab bills", "err", err)
+	}
+}
+
+// ExpireTabs ends tabs which have run past their end date, issuing their final bills, and pending tabs
+// which were never approved before they were due to start.
+func (s *Scheduler) ExpireTabs(ctx context.Context) error {
+	now := s.clock.Now()
+	tabs, err := s.store.GetTabsDueExpiry(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	count := s.forEachTab(ctx, tabs, "Failed to expire tab", func(tab *db.TabKey) (bool, error) {
+		return s.store.ExpireTab(ctx, tab.ShopId, tab.TabId, now)
+	})
+	s.logger.Debug("Expired tabs", "time", now, "tabs", count)
+	return nil
+}
+
+// RollOverBills closes out billing periods which have ended and opens the next ones for all confirmed tabs.
+func (s *Scheduler) RollOverBills(ctx context.Context) error {
+	now := s.clock.Now()
+	tabs, err := s.store.GetTabsDueRollOver(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	count := s.forEachTab(ctx, tabs, "Failed to roll over tab bills", func(tab *db.TabKey) (bool, error) {
+		return s.store.RollOverTabBills(ctx, tab.ShopId, tab.TabId, now)
+	})
+	s.logger.Debug("Rolled over tab bills", "time", now, "tabs", count)
+	return nil
+}
+
+// forEachTab runs the job for each tab, so that a tab which fails is logged and skipped without
+// holding back the others. It returns the number of tabs the job reported as done.
+func (s *Scheduler) forEachTab(ctx context.Context, tabs []db.TabKey, failure string, job func(*db.TabKey) (bool, error)) int {
+	count := 0
+	for _, tab := range tabs {
+		if ctx.Err() != nil {
+			break
+		}
+
+		done, err := job(&tab)
+		if err != nil {
+			s.logger.Error(failure, "shopId", tab.ShopId, "tabId", tab.TabId, "err", err)
+			continue
+		}
+		if done {
+			count++
+		}
+	}
+	return count
+}
+
+// PgxStore runs the scheduler's queries against the database, each tab in its own transaction
+type PgxStore struct {
+	store *db.PgxStore
+}
+
+func (s *PgxStore) GetTabsDueExpiry(ctx context.Context, now time.Time) ([]db.TabKey, error) {
+	return db.WithTxRet(ctx, s.store, func(pq *db.PgxQueries) ([]db.TabKey, error) {
+		return pq.GetTabsDueExpiry(ctx, now)
+	})
+}
+
+func (s *PgxStore) ExpireTab(ctx context.Context, shopId int, tabId int, now time.Time) (bool, error) {
+	return db.WithTxRet(ctx, s.store, func(pq *db.PgxQueries) (bool, error) {
+		return pq.ExpireTab(ctx, shopId, tabId, now)
+	})
+}
+
+func (s *PgxStore) GetTabsDueRollOver(ctx context.Context, now time.Time) ([]db.TabKey, error) {
+	return db.WithTxRet(ctx, s.store, func(pq *db.PgxQueries) ([]db.TabKey, error) {
+		return pq.GetTabsDueRollOver(ctx, now)
+	})
+}
+
+func (s *PgxStore) RollOverTabBills(ctx context.Context, shopId int, tabId int, now time.Time) (bool, error) {
+	return db.WithTxRet(ctx, s.store, func(pq *db.PgxQueries) (bool, error) {
+		return pq.RollOverTabBills(ctx, shopId, tabId, now)
+	})
+}
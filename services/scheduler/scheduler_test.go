@@ -0,0 +1,181 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/WilliamTrojniak/TabAppBackend/db"
+	"github.com/WilliamTrojniak/TabAppBackend/util"
+)
+
+// fakeStore records the calls made by the scheduler, returning the tabs due at each time and failing
+// the calls listed in errs
+type fakeStore struct {
+	due   func(job string, now time.Time) []db.TabKey
+	errs  map[string]error
+	calls []string
+	// onCall is called after each call is recorded
+	onCall func()
+}
+
+func (s *fakeStore) record(call string) error {
+	s.calls = append(s.calls, call)
+	if s.onCall != nil {
+		s.onCall()
+	}
+	return s.errs[call]
+}
+
+func (s *fakeStore) list(job string, now time.Time) ([]db.TabKey, error) {
+	err := s.record(fmt.Sprintf("%v due %v", job, now.Format(time.DateOnly)))
+	if err != nil {
+		return nil, err
+	}
+	return s.due(job, now), nil
+}
+
+func (s *fakeStore) run(job string, shopId int, tabId int, now time.Time) (bool, error) {
+	err := s.record(fmt.Sprintf("%v %v/%v %v", job, shopId, tabId, now.Format(time.DateOnly)))
+	return err == nil, err
+}
+
+func (s *fakeStore) GetTabsDueExpiry(ctx context.Context, now time.Time) ([]db.TabKey, error) {
+	return s.list("expire", now)
+}
+
+func (s *fakeStore) ExpireTab(ctx context.Context, shopId int, tabId int, now time.Time) (bool, error) {
+	return s.run("expire", shopId, tabId, now)
+}
+
+func (s *fakeStore) GetTabsDueRollOver(ctx context.Context, now time.Time) ([]db.TabKey, error) {
+	return s.list("roll over", now)
+}
+
+func (s *fakeStore) RollOverTabBills(ctx context.Context, shopId int, tabId int, now time.Time) (bool, error) {
+	return s.run("roll over", shopId, tabId, now)
+}
+
+func newTestScheduler(store Store, clock util.Clock) *Scheduler {
+	return NewWithStore(store, clock, time.Hour, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+// twoTabsDue reports tabs 1 and 2 of shop 1 as due for every job
+func twoTabsDue(job string, now time.Time) []db.TabKey {
+	return []db.TabKey{{ShopId: 1, TabId: 1}, {ShopId: 1, TabId: 2}}
+}
+
+func TestRunOnceExpiresBeforeRollingOver(t *testing.T) {
+	clock := util.NewFakeClock(time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC))
+	store := &fakeStore{
+		// Tab 2 ends on the 1st of March, so only tab 1 is rolled over from the 2nd
+		due: func(job string, now time.Time) []db.TabKey {
+			if job == "expire" && now.Day() == 2 {
+				return []db.TabKey{{ShopId: 1, TabId: 2}}
+			}
+			if job == "roll over" {
+				return []db.TabKey{{ShopId: 1, TabId: 1}}
+			}
+			return nil
+		},
+	}
+	scheduler := newTestScheduler(store, clock)
+
+	scheduler.RunOnce(context.Background())
+	clock.Advance(time.Hour * 24)
+	scheduler.RunOnce(context.Background())
+
+	expected := []string{
+		"expire due 2026-03-01",
+		"roll over due 2026-03-01",
+		"roll over 1/1 2026-03-01",
+		"expire due 2026-03-02",
+		"expire 1/2 2026-03-02",
+		"roll over due 2026-03-02",
+		"roll over 1/1 2026-03-02",
+	}
+	if !slices.Equal(store.calls, expected) {
+		t.Fatalf("expected calls %q, got %q", expected, store.calls)
+	}
+}
+
+func TestRunOnceSkipsFailedTabs(t *testing.T) {
+	clock := util.NewFakeClock(time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC))
+	store := &fakeStore{
+		due: twoTabsDue,
+		errs: map[string]error{
+			"expire 1/1 2026-03-01":    errors.New("expire failed"),
+			"roll over 1/1 2026-03-01": errors.New("roll over failed"),
+		},
+	}
+
+	newTestScheduler(store, clock).RunOnce(context.Background())
+
+	expected := []string{
+		"expire due 2026-03-01",
+		"expire 1/1 2026-03-01",
+		"expire 1/2 2026-03-01",
+		"roll over due 2026-03-01",
+		"roll over 1/1 2026-03-01",
+		"roll over 1/2 2026-03-01",
+	}
+	if !slices.Equal(store.calls, expected) {
+		t.Fatalf("expected calls %q, got %q", expected, store.calls)
+	}
+}
+
+func TestRunOnceRollsOverWhenExpiryFails(t *testing.T) {
+	clock := util.NewFakeClock(time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC))
+	store := &fakeStore{
+		due:  twoTabsDue,
+		errs: map[string]error{"expire due 2026-03-01": errors.New("database unavailable")},
+	}
+
+	scheduler := newTestScheduler(store, clock)
+	err := scheduler.ExpireTabs(context.Background())
+	if err == nil {
+		t.Fatalf("expected the failure to list tabs to be returned")
+	}
+
+	store.calls = nil
+	scheduler.RunOnce(context.Background())
+
+	expected := []string{
+		"expire due 2026-03-01",
+		"roll over due 2026-03-01",
+		"roll over 1/1 2026-03-01",
+		"roll over 1/2 2026-03-01",
+	}
+	if !slices.Equal(store.calls, expected) {
+		t.Fatalf("expected calls %q, got %q", expected, store.calls)
+	}
+}
+
+func TestRunOnceStopsWhenCancelled(t *testing.T) {
+	clock := util.NewFakeClock(time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := &fakeStore{due: twoTabsDue}
+	store.onCall = func() {
+		if len(store.calls) == 2 {
+			cancel()
+		}
+	}
+
+	newTestScheduler(store, clock).RunOnce(ctx)
+
+	// The remaining tabs are left for the next run, though each job still lists the tabs due
+	expected := []string{
+		"expire due 2026-03-01",
+		"expire 1/1 2026-03-01",
+		"roll over due 2026-03-01",
+	}
+	if !slices.Equal(store.calls, expected) {
+		t.Fatalf("expected calls %q, got %q", expected, store.calls)
+	}
+}
@@ -0,0 +1,150 @@
+package shop
+
+import (
+	"context"
+	"math"
+
+	"github.com/WilliamTrojniak/TabAppBackend/db"
+	"github.com/WilliamTrojniak/TabAppBackend/models"
+	"github.com/WilliamTrojniak/TabAppBackend/services/email"
+	"github.com/WilliamTrojniak/TabAppBackend/services/sessions"
+)
+
+func (h *Handler) IssueBill(ctx context.Context, session *sessions.Session, shopId int, tabId int, billId int) error {
+	userId, err := session.GetUserId()
+	if err != nil {
+		return err
+	}
+
+	return h.WithAuthorize(ctx, session, shopId, ROLE_USER_MANAGE_ORDERS, func(pq *db.PgxQueries) error {
+		err := pq.IssueBill(ctx, shopId, tabId, billId, userId, h.clock.Now())
+		if err != nil {
+			return err
+		}
+
+		return h.emailBillIssued(ctx, pq, shopId, tabId, billId)
+	})
+}
+
+func (h *Handler) MarkTabBillPaid(ctx context.Context, session *sessions.Session, shopId int, tabId int, billId int) error {
+	userId, err := session.GetUserId()
+	if err != nil {
+		return err
+	}
+
+	return h.WithAuthorize(ctx, session, shopId, ROLE_USER_MANAGE_ORDERS, func(pq *db.PgxQueries) error {
+		issued, err := pq.MarkTabBillPaid(ctx, shopId, tabId, billId, userId, h.clock.Now())
+		if err != nil || !issued {
+			return err
+		}
+
+		// Bills which are still open are issued when they are paid
+		return h.emailBillIssued(ctx, pq, shopId, tabId, billId)
+	})
+}
+
+func (h *Handler) VoidBill(ctx context.Context, session *sessions.Session, shopId int, tabId int, billId int, data *models.BillVoid) error {
+	userId, err := session.GetUserId()
+	if err != nil {
+		return err
+	}
+
+	err = models.ValidateData(data, h.logger)
+	if err != nil {
+		return err
+	}
+
+	return h.WithAuthorize(ctx, session, shopId, ROLE_USER_MANAGE_TABS, func(pq *db.PgxQueries) error {
+		h.logger.Info("Voiding bill", "shopId", shopId, "tabId", tabId, "billId", billId, "userId", userId)
+		return pq.VoidBill(ctx, shopId, tabId, billId, userId, data.Reason)
+	})
+}
+
+func (h *Handler) RecordBillPayment(ctx context.Context, session *sessions.Session, shopId int, tabId int, billId int, data *models.BillPaymentCreate) (models.BillPayment, error) {
+	userId, err := session.GetUserId()
+	if err != nil {
+		return models.BillPayment{}, err
+	}
+
+	// Amounts are validated once rounded to the cent, so that no payment rounds down to nothing
+	data.Amount = float32(math.Round(float64(data.Amount)*100) / 100)
+	err = models.ValidateData(data, h.logger)
+	if err != nil {
+		return models.BillPayment{}, err
+	}
+
+	return db.WithTxRet(ctx, h.store, func(pq *db.PgxQueries) (models.BillPayment, error) {
+		err := h.Authorize(ctx, session, shopId, ROLE_USER_MANAGE_ORDERS, pq)
+		if err != nil {
+			return models.BillPayment{}, err
+		}
+
+		// Payments are dated today in the shop's time zone unless stated otherwise
+		var paidOn models.Date
+		if data.PaidOn != nil {
+			paidOn = *data.PaidOn
+		} else {
+			paidOn, err = pq.GetShopDate(ctx, shopId, h.clock.Now())
+			if err != nil {
+				return models.BillPayment{}, err
+			}
+		}
+
+		payment, issued, err := pq.RecordBillPayment(ctx, shopId, tabId, billId, userId, data, paidOn, h.clock.Now())
+		if err != nil {
+			return models.BillPayment{}, err
+		}
+
+		if issued {
+			err = h.emailBillIssued(ctx, pq, shopId, tabId, billId)
+			if err != nil {
+				return models.BillPayment{}, err
+			}
+		}
+		return payment, nil
+	})
+}
+
+func (h *Handler) GetBillPayments(ctx context.Context, session *sessions.Session, shopId int, tabId int, billId int) ([]models.BillPayment, error) {
+	return db.WithTxRet(ctx, h.store, func(pq *db.PgxQueries) ([]models.BillPayment, error) {
+		tab, err := pq.GetTabById(ctx, shopId, tabId)
+		if err != nil {
+			return nil, err
+		}
+
+		err = h.authorizeTabManager(ctx, session, &tab.TabOverview, ROLE_USER_READ_TABS, pq)
+		if err != nil {
+			return nil, err
+		}
+
+		return pq.GetBillPayments(ctx, shopId, tabId, billId)
+	})
+}
+
+// emailBillIssued sends a newly issued bill to the tab owner
+func (h *Handler) emailBillIssued(ctx context.Context, pq *db.PgxQueries, shopId int, tabId int, billId int) error {
+	tab, err := pq.GetTabById(ctx, shopId, tabId)
+	if err != nil {
+		return err
+	}
+
+	_, tabData, err := h.tabEmailData(ctx, pq, &tab.TabOverview)
+	if err != nil {
+		return err
+	}
+
+	for _, bill := range tab.Bills {
+		if bill.Id != billId || bill.InvoiceNumber == nil {
+			continue
+		}
+
+		return h.emailUser(ctx, pq, tab.OwnerId, email.TEMPLATE_BILL_ISSUED, email.BillIssuedData{
+			TabData:       tabData,
+			InvoiceNumber: *bill.InvoiceNumber,
+			StartDate:     bill.StartDate,
+			EndDate:       bill.EndDate,
+			Total:         bill.Total,
+		})
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package shop
+
+import (
+	"context"
+
+	"github.com/WilliamTrojniak/TabAppBackend/db"
+	"github.com/WilliamTrojniak/TabAppBackend/models"
+	"github.com/WilliamTrojniak/TabAppBackend/services/sessions"
+)
+
+// CreateShopCharge adds a tax or service fee to the shop, which is charged on orders made after it is added
+func (h *Handler) CreateShopCharge(ctx context.Context, session *sessions.Session, data *models.ShopChargeCreate) error {
+	return h.WithAuthorize(ctx, session, data.ShopId, ROLE_USER_OWNER, func(pq *db.PgxQueries) error {
+		err := models.ValidateData(data, h.logger)
+		if err != nil {
+			return err
+		}
+
+		return pq.CreateShopCharge(ctx, data)
+	})
+}
+
+func (h *Handler) GetShopCharges(ctx context.Context, shopId int) ([]models.ShopCharge, error) {
+	return db.WithTxRet(ctx, h.store, func(pq *db.PgxQueries) ([]models.ShopCharge, error) {
+		return pq.GetShopCharges(ctx, shopId)
+	})
+}
+
+// UpdateShopCharge changes a tax or service fee. Orders which have already been charged are unaffected.
+func (h *Handler) UpdateShopCharge(ctx context.Context, session *sessions.Session, shopId int, chargeId int, data *models.ShopChargeUpdate) error {
+	return h.WithAuthorize(ctx, session, shopId, ROLE_USER_OWNER, func(pq *db.PgxQueries) error {
+		err := models.ValidateData(data, h.logger)
+		if err != nil {
+			return err
+		}
+		h.logger.Debug("Updating shop charge", "shopId", shopId, "chargeId", chargeId)
+
+		return pq.UpdateShopCharge(ctx, shopId, chargeId, data)
+	})
+}
+
+func (h *Handler) DeleteShopCharge(ctx context.Context, session *sessions.Session, shopId int, chargeId int) error {
+	return h.WithAuthorize(ctx, session, shopId, ROLE_USER_OWNER, func(pq *db.PgxQueries) error {
+		h.logger.Debug("Deleting shop charge", "shopId", shopId, "chargeId", chargeId)
+
+		return pq.DeleteShopCharge(ctx, shopId, chargeId)
+	})
+}
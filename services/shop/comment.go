@@ -0,0 +1,54 @@
+package shop
+
+import (
+	"context"
+
+	"github.com/WilliamTrojniak/TabAppBackend/db"
+	"github.com/WilliamTrojniak/TabAppBackend/models"
+	"github.com/WilliamTrojniak/TabAppBackend/services/sessions"
+)
+
+// AddTabComment posts a comment to the tab's thread, which the tab's owner and managers may discuss
+// with shop staff
+func (h *Handler) AddTabComment(ctx context.Context, session *sessions.Session, shopId int, tabId int, data *models.TabCommentCreate) (models.TabComment, error) {
+	userId, err := session.GetUserId()
+	if err != nil {
+		return models.TabComment{}, err
+	}
+
+	err = models.ValidateData(data, h.logger)
+	if err != nil {
+		return models.TabComment{}, err
+	}
+
+	return db.WithTxRet(ctx, h.store, func(pq *db.PgxQueries) (models.TabComment, error) {
+		tab, err := pq.GetTabById(ctx, shopId, tabId)
+		if err != nil {
+			return models.TabComment{}, err
+		}
+
+		err = h.authorizeTabManager(ctx, session, &tab.TabOverview, ROLE_USER_READ_TABS, pq)
+		if err != nil {
+			return models.TabComment{}, err
+		}
+
+		return pq.AddTabComment(ctx, shopId, tabId, userId, data)
+	})
+}
+
+// GetTabComments returns the tab's thread, including the events recorded in its lifecycle, oldest first
+func (h *Handler) GetTabComments(ctx context.Context, session *sessions.Session, shopId int, tabId int) ([]models.TabComment, error) {
+	return db.WithTxRet(ctx, h.store, func(pq *db.PgxQueries) ([]models.TabComment, error) {
+		tab, err := pq.GetTabById(ctx, shopId, tabId)
+		if err != nil {
+			return nil, err
+		}
+
+		err = h.authorizeTabManager(ctx, session, &tab.TabOverview, ROLE_USER_READ_TABS, pq)
+		if err != nil {
+			return nil, err
+		}
+
+		return pq.GetTabComments(ctx, shopId, tabId)
+	})
+}
@@ -0,0 +1,54 @@
+package shop
+
+import (
+	"context"
+
+	"github.com/WilliamTrojniak/TabAppBackend/db"
+	"github.com/WilliamTrojniak/TabAppBackend/models"
+	"github.com/WilliamTrojniak/TabAppBackend/services/email"
+)
+
+// tabEmailData collects the details of a tab used by the emails about it
+func (h *Handler) tabEmailData(ctx context.Context, pq *db.PgxQueries, tab *models.TabOverview) (*models.Shop, email.TabData, error) {
+	shop, err := pq.GetShopById(ctx, tab.ShopId)
+	if err != nil {
+		return nil, email.TabData{}, err
+	}
+
+	return &shop, email.TabData{
+		ShopId:   tab.ShopId,
+		ShopName: shop.Name,
+		TabId:    tab.Id,
+		TabName:  tab.DisplayName,
+	}, nil
+}
+
+// emailUser enqueues an email to a user as part of the current transaction
+func (h *Handler) emailUser(ctx context.Context, pq *db.PgxQueries, userId string, template email.Template, data any) error {
+	user, err := pq.GetUser(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	return h.emails.Enqueue(ctx, pq, template, user.Email, data)
+}
+
+// emailTabOwner notifies the owner of a tab about an event on it
+func (h *Handler) emailTabOwner(ctx context.Context, pq *db.PgxQueries, tab *models.TabOverview, template email.Template) error {
+	_, data, err := h.tabEmailData(ctx, pq, tab)
+	if err != nil {
+		return err
+	}
+
+	return h.emailUser(ctx, pq, tab.OwnerId, template, data)
+}
+
+// emailShopOwner notifies the owner of the shop about an event on one of its tabs
+func (h *Handler) emailShopOwner(ctx context.Context, pq *db.PgxQueries, tab *models.TabOverview, template email.Template) error {
+	shop, data, err := h.tabEmailData(ctx, pq, tab)
+	if err != nil {
+		return err
+	}
+
+	return h.emailUser(ctx, pq, shop.OwnerId, template, data)
+}
@@ -0,0 +1,241 @@
+package shop
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/WilliamTrojniak/TabAppBackend/db"
+	"github.com/WilliamTrojniak/TabAppBackend/models"
+	"github.com/WilliamTrojniak/TabAppBackend/pdf"
+	"github.com/WilliamTrojniak/TabAppBackend/services"
+	"github.com/WilliamTrojniak/TabAppBackend/services/sessions"
+)
+
+const (
+	invoiceMargin     = 54
+	invoiceFontSize   = 10
+	invoiceLineHeight = 14
+
+	invoiceQuantityX = 380
+	invoicePriceX    = 470
+	invoiceAmountX   = pdf.PageWidth - invoiceMargin
+)
+
+// GetBillInvoice renders a printable PDF invoice for a bill of a tab
+func (h *Handler) GetBillInvoice(ctx context.Context, session *sessions.Session, shopId int, tabId int, billId int) (models.BillOverview, []byte, error) {
+	var bill *models.Bill
+	var invoice bytes.Buffer
+	err := db.WithTx(ctx, h.store, func(pq *db.PgxQueries) error {
+		tab, err := pq.GetTabById(ctx, shopId, tabId)
+		if err != nil {
+			return err
+		}
+
+		err = h.authorizeTabManager(ctx, session, &tab.TabOverview, ROLE_USER_READ_TABS, pq)
+		if err != nil {
+			return err
+		}
+
+		for i := range tab.Bills {
+			if tab.Bills[i].Id == billId {
+				bill = &tab.Bills[i]
+				break
+			}
+		}
+		if bill == nil {
+			return services.NewNotFoundServiceError(errors.New("Bill not found"))
+		}
+
+		shop, err := pq.GetShopById(ctx, shopId)
+		if err != nil {
+			return err
+		}
+
+		_, err = renderInvoice(&shop, &tab, bill).WriteTo(&invoice)
+		return err
+	})
+	if err != nil {
+		return models.BillOverview{}, nil, err
+	}
+
+	return bill.BillOverview, invoice.Bytes(), nil
+}
+
+type invoiceWriter struct {
+	doc  *pdf.Document
+	page *pdf.Page
+	y    float64
+	// onPageBreak is called at the top of each continued page
+	onPageBreak func()
+}
+
+func (w *invoiceWriter) newPage() {
+	w.page = w.doc.AddPage()
+	w.y = pdf.PageHeight - invoiceMargin
+}
+
+// line advances to the next line, starting a new page when the current one is full
+func (w *invoiceWriter) line(height float64) {
+	w.y -= height
+	if w.y < invoiceMargin {
+		w.newPage()
+		if w.onPageBreak != nil {
+			w.onPageBreak()
+		}
+		w.y -= height
+	}
+}
+
+func (w *invoiceWriter) itemHeader() {
+	w.line(invoiceLineHeight)
+	w.page.Text(invoiceMargin, w.y, invoiceFontSize, pdf.FontBold, "Description")
+	w.page.TextRight(invoiceQuantityX, w.y, invoiceFontSize, pdf.FontBold, "Qty")
+	w.page.TextRight(invoicePriceX, w.y, invoiceFontSize, pdf.FontBold, "Unit price")
+	w.page.TextRight(invoiceAmountX, w.y, invoiceFontSize, pdf.FontBold, "Amount")
+	w.page.Line(invoiceMargin, w.y-4, invoiceAmountX, w.y-4, 0.5)
+	w.y -= 4
+}
+
+func (w *invoiceWriter) itemLine(indent float64, description string, quantity int, price float32) {
+	w.line(invoiceLineHeight)
+	maxWidth := invoiceQuantityX - 40 - invoiceMargin - indent
+	w.page.Text(invoiceMargin+indent, w.y, invoiceFontSize, pdf.FontRegular, pdf.Truncate(description, invoiceFontSize, pdf.FontRegular, maxWidth))
+	w.page.TextRight(invoiceQuantityX, w.y, invoiceFontSize, pdf.FontRegular, fmt.Sprint(quantity))
+	w.page.TextRight(invoicePriceX, w.y, invoiceFontSize, pdf.FontRegular, formatMoney(price))
+	w.page.TextRight(invoiceAmountX, w.y, invoiceFontSize, pdf.FontRegular, formatMoney(float32(quantity)*price))
+}
+
+func (w *invoiceWriter) adjustmentLine(description string, amount float32) {
+	w.line(invoiceLineHeight)
+	maxWidth := float64(invoicePriceX - 40 - invoiceMargin)
+	w.page.Text(invoiceMargin, w.y, invoiceFontSize, pdf.FontRegular, pdf.Truncate(description, invoiceFontSize, pdf.FontRegular, maxWidth))
+	w.page.TextRight(invoiceAmountX, w.y, invoiceFontSize, pdf.FontRegular, formatMoney(amount))
+}
+
+func (w *invoiceWriter) field(label string, value string) {
+	w.line(invoiceLineHeight)
+	w.page.Text(invoiceMargin, w.y, invoiceFontSize, pdf.FontBold, label)
+	for i, line := range pdf.Wrap(value, invoiceFontSize, pdf.FontRegular, invoiceAmountX-invoiceMargin-110) {
+		if i > 0 {
+			w.line(invoiceLineHeight)
+		}
+		w.page.Text(invoiceMargin+110, w.y, invoiceFontSize, pdf.FontRegular, line)
+	}
+}
+
+func (w *invoiceWriter) total(label string, amount float32, font pdf.Font) {
+	w.line(invoiceLineHeight)
+	w.page.TextRight(invoicePriceX, w.y, invoiceFontSize, font, label)
+	w.page.TextRight(invoiceAmountX, w.y, invoiceFontSize, font, formatMoney(amount))
+}
+
+func renderInvoice(shop *models.Shop, tab *models.Tab, bill *models.Bill) *pdf.Document {
+	w := &invoiceWriter{doc: pdf.New()}
+	w.newPage()
+
+	w.line(20)
+	w.page.Text(invoiceMargin, w.y, 20, pdf.FontBold, "INVOICE")
+	w.page.TextRight(invoiceAmountX, w.y, 14, pdf.FontBold, pdf.Truncate(shop.Name, 14, pdf.FontBold, 300))
+	w.line(invoiceLineHeight)
+	if bill.InvoiceNumber != nil {
+		w.page.Text(invoiceMargin, w.y, invoiceFontSize, pdf.FontRegular, fmt.Sprintf("Invoice number %v", *bill.InvoiceNumber))
+	} else {
+		w.page.Text(invoiceMargin, w.y, invoiceFontSize, pdf.FontRegular, fmt.Sprintf("Draft for bill %v", bill.Id))
+	}
+	if bill.IssuedAt != nil {
+		issuedAt := *bill.IssuedAt
+		loc, err := shop.Location()
+		if err == nil {
+			issuedAt = issuedAt.In(loc)
+		}
+		w.page.TextRight(invoiceAmountX, w.y, invoiceFontSize, pdf.FontRegular, fmt.Sprintf("Issued %v", models.DateOf(issuedAt)))
+	}
+	if bill.Status == models.BILL_STATUS_VOID.String() {
+		w.line(invoiceLineHeight)
+		void := "VOID"
+		if bill.VoidReason != nil {
+			void = fmt.Sprintf("VOID: %v", *bill.VoidReason)
+		}
+		w.page.Text(invoiceMargin, w.y, invoiceFontSize, pdf.FontBold, pdf.Truncate(void, invoiceFontSize, pdf.FontBold, invoiceAmountX-invoiceMargin))
+	}
+
+	w.line(invoiceLineHeight)
+	w.field("Bill to", tab.DisplayName)
+	w.field("Organization", tab.Organization)
+	paymentMethod, paymentDetails := invoicePayment(tab, bill)
+	if paymentMethod != "" {
+		w.field("Payment method", paymentMethod)
+	}
+	if paymentDetails != "" {
+		w.field("Payment details", paymentDetails)
+	}
+	w.field("Billing period", fmt.Sprintf("%v to %v", bill.StartDate, bill.EndDate))
+
+	w.line(invoiceLineHeight)
+	w.itemHeader()
+	w.onPageBreak = w.itemHeader
+	for _, item := range bill.Items {
+		description := item.Name
+		if item.Customer != "" {
+			description = fmt.Sprintf("%v (%v)", item.Name, item.Customer)
+		}
+		if item.Quantity != 0 {
+			w.itemLine(0, description, item.Quantity, *item.BasePrice)
+		}
+		for _, variant := range item.Variants {
+			if variant.Quantity == 0 {
+				continue
+			}
+			w.itemLine(12, fmt.Sprintf("%v: %v", item.Name, variant.Name), variant.Quantity, *variant.Price)
+		}
+	}
+	w.onPageBreak = nil
+	w.page.Line(invoiceMargin, w.y-6, invoiceAmountX, w.y-6, 0.5)
+	w.y -= 6
+
+	// Discounts and other adjustments are listed after the subtotal of the items they apply to
+	if len(bill.Adjustments) > 0 {
+		var subtotal float32
+		for _, item := range bill.Items {
+			subtotal += item.Total()
+		}
+		w.total("Subtotal", subtotal, pdf.FontRegular)
+		for _, adjustment := range bill.Adjustments {
+			w.adjustmentLine(adjustment.Description, adjustment.Amount)
+		}
+	}
+
+	w.total("Total", bill.Total, pdf.FontBold)
+	if bill.AmountPaid != 0 {
+		w.total("Amount paid", bill.AmountPaid, pdf.FontRegular)
+	}
+	w.total("Balance due", bill.Outstanding, pdf.FontBold)
+
+	return w.doc
+}
+
+// invoicePayment returns the payment method and details saved on the bill when it was issued, so that
+// the invoice is charged as issued even if the tab's details later change. Open bills use the tab's.
+func invoicePayment(tab *models.Tab, bill *models.Bill) (string, string) {
+	if bill.Status == models.BILL_STATUS_OPEN.String() {
+		return tab.PaymentMethod, tab.PaymentDetails
+	}
+
+	var method, details string
+	if bill.PaymentMethod != nil {
+		method = *bill.PaymentMethod
+	}
+	if bill.PaymentDetails != nil {
+		details = *bill.PaymentDetails
+	}
+	return method, details
+}
+
+func formatMoney(amount float32) string {
+	if amount < 0 {
+		return fmt.Sprintf("-$%.2f", -amount)
+	}
+	return fmt.Sprintf("$%.2f", amount)
+}
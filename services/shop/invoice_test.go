@@ -0,0 +1,121 @@
+package shop
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/WilliamTrojniak/TabAppBackend/models"
+)
+
+var (
+	invoiceStreamPattern = regexp.MustCompile(`(?s)>>\nstream\n(.*?)endstream\n`)
+	invoiceTextPattern   = regexp.MustCompile(`\(((?:\\.|[^\\)])*)\) Tj`)
+)
+
+// invoicePages renders the invoice and returns the strings shown on each of its pages
+func invoicePages(t *testing.T, shop *models.Shop, tab *models.Tab, bill *models.Bill) [][]string {
+	t.Helper()
+	var buf bytes.Buffer
+	_, err := renderInvoice(shop, tab, bill).WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var pages [][]string
+	for _, stream := range invoiceStreamPattern.FindAllStringSubmatch(buf.String(), -1) {
+		var text []string
+		for _, match := range invoiceTextPattern.FindAllStringSubmatch(stream[1], -1) {
+			text = append(text, strings.NewReplacer(`\(`, "(", `\)`, ")", `\\`, `\`).Replace(match[1]))
+		}
+		pages = append(pages, text)
+	}
+	if count := fmt.Sprintf("/Count %d", len(pages)); !strings.Contains(buf.String(), count) {
+		t.Fatalf("expected the page tree to hold %d pages", len(pages))
+	}
+	return pages
+}
+
+func testInvoice(status models.BillStatus, items int) (*models.Shop, *models.Tab, *models.Bill) {
+	shop := &models.Shop{}
+	shop.Name = "Test Shop"
+	shop.TimeZone = "UTC"
+
+	tab := &models.Tab{}
+	tab.DisplayName = "Test Tab"
+	tab.Organization = "Test Organization"
+	tab.PaymentMethod = "chartstring"
+	tab.PaymentDetails = "NEW-CHARTSTRING"
+
+	bill := &models.Bill{}
+	bill.Id = 1
+	bill.Status = status.String()
+	if status != models.BILL_STATUS_OPEN {
+		invoiceNumber := 7
+		paymentMethod := "chartstring"
+		paymentDetails := "ISSUED-CHARTSTRING"
+		bill.InvoiceNumber = &invoiceNumber
+		bill.PaymentMethod = &paymentMethod
+		bill.PaymentDetails = &paymentDetails
+	}
+
+	price := float32(2.5)
+	for i := 0; i < items; i++ {
+		item := models.ItemOrder{Quantity: 2}
+		item.Id = i + 1
+		item.Name = fmt.Sprintf("Coffee (large) #%d", i+1)
+		item.BasePrice = &price
+		bill.Items = append(bill.Items, item)
+		bill.Total += item.Total()
+	}
+	bill.Outstanding = bill.Total
+	return shop, tab, bill
+}
+
+func TestRenderInvoiceMultiplePages(t *testing.T) {
+	shop, tab, bill := testInvoice(models.BILL_STATUS_ISSUED, 120)
+	pages := invoicePages(t, shop, tab, bill)
+	if len(pages) < 3 {
+		t.Fatalf("expected 120 items to span at least 3 pages, got %d", len(pages))
+	}
+
+	var lines []string
+	for i, page := range pages {
+		if !slices.Contains(page, "Description") {
+			t.Errorf("expected page %d to repeat the item header, got %q", i+1, page)
+		}
+		lines = append(lines, page...)
+	}
+
+	for i := 1; i <= 120; i++ {
+		if !slices.Contains(lines, fmt.Sprintf("Coffee (large) #%d", i)) {
+			t.Fatalf("expected item %d to be listed with its parentheses intact", i)
+		}
+	}
+	last := pages[len(pages)-1]
+	if !slices.Contains(last, "Balance due") || !slices.Contains(last, "$600.00") {
+		t.Errorf("expected the last page to end with the balance due, got %q", last)
+	}
+}
+
+func TestRenderInvoicePaymentDetails(t *testing.T) {
+	tests := []struct {
+		status   models.BillStatus
+		expected string
+	}{
+		{models.BILL_STATUS_OPEN, "NEW-CHARTSTRING"},
+		{models.BILL_STATUS_ISSUED, "ISSUED-CHARTSTRING"},
+		{models.BILL_STATUS_PAID, "ISSUED-CHARTSTRING"},
+		{models.BILL_STATUS_VOID, "ISSUED-CHARTSTRING"},
+	}
+	for _, test := range tests {
+		shop, tab, bill := testInvoice(test.status, 1)
+		page := invoicePages(t, shop, tab, bill)[0]
+		if !slices.Contains(page, test.expected) {
+			t.Errorf("expected a %v bill to show payment details %v, got %q", test.status, test.expected, page)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package shop
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/WilliamTrojniak/TabAppBackend/db"
+	"github.com/WilliamTrojniak/TabAppBackend/models"
+	"github.com/WilliamTrojniak/TabAppBackend/services/sessions"
+)
+
+var journalCsvHeader = []string{"invoice_number", "chartstring", "organization", "tab_id", "tab_name", "bill_id", "period_start", "period_end", "subtotal", "discounts", "taxes", "service_fees", "tips", "amount"}
+
+// CreateJournalExport batches the issued and paid chartstring bills in the date range which have not already been exported
+func (h *Handler) CreateJournalExport(ctx context.Context, session *sessions.Session, shopId int, data *models.JournalExportCreate) (models.JournalExport, []models.JournalEntry, error) {
+	userId, err := session.GetUserId()
+	if err != nil {
+		return models.JournalExport{}, nil, err
+	}
+
+	err = models.ValidateData(data, h.logger)
+	if err != nil {
+		return models.JournalExport{}, nil, err
+	}
+
+	var export models.JournalExport
+	var entries []models.JournalEntry
+	err = h.WithAuthorize(ctx, session, shopId, ROLE_USER_MANAGE_ORDERS, func(pq *db.PgxQueries) error {
+		export, err = pq.CreateJournalExport(ctx, shopId, userId, data)
+		if err != nil {
+			return err
+		}
+
+		entries, err = pq.GetJournalEntries(ctx, shopId, export.Id)
+		return err
+	})
+	if err != nil {
+		return models.JournalExport{}, nil, err
+	}
+
+	h.logger.Info("Exported journal", "shopId", shopId, "exportId", export.Id, "bills", export.BillCount)
+	return export, entries, nil
+}
+
+func (h *Handler) GetJournalExports(ctx context.Context, session *sessions.Session, shopId int) ([]models.JournalExport, error) {
+	var exports []models.JournalExport = nil
+	err := h.WithAuthorize(ctx, session, shopId, ROLE_USER_MANAGE_ORDERS, func(pq *db.PgxQueries) error {
+		var err error
+		exports, err = pq.GetJournalExports(ctx, shopId)
+		return err
+	})
+	return exports, err
+}
+
+// GetJournalExport returns a previous export batch so that it may be exported again
+func (h *Handler) GetJournalExport(ctx context.Context, session *sessions.Session, shopId int, exportId int) (models.JournalExport, []models.JournalEntry, error) {
+	var export models.JournalExport
+	var entries []models.JournalEntry
+	err := h.WithAuthorize(ctx, session, shopId, ROLE_USER_MANAGE_ORDERS, func(pq *db.PgxQueries) error {
+		var err error
+		export, err = pq.GetJournalExport(ctx, shopId, exportId)
+		if err != nil {
+			return err
+		}
+
+		entries, err = pq.GetJournalEntries(ctx, shopId, exportId)
+		return err
+	})
+	return export, entries, err
+}
+
+func writeJournalCsv(w io.Writer, entries []models.JournalEntry) error {
+	writer := csv.NewWriter(w)
+	err := writer.Write(journalCsvHeader)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		err = writer.Write([]string{
+			strconv.Itoa(entry.InvoiceNumber),
+			csvText(entry.Chartstring),
+			csvText(entry.Organization),
+			strconv.Itoa(entry.TabId),
+			csvText(entry.TabName),
+			strconv.Itoa(entry.BillId),
+			entry.StartDate.String(),
+			entry.EndDate.String(),
+			fmt.Sprintf("%.2f", entry.Subtotal),
+			fmt.Sprintf("%.2f", entry.Discounts),
+			fmt.Sprintf("%.2f", entry.Taxes),
+			fmt.Sprintf("%.2f", entry.ServiceFees),
+			fmt.Sprintf("%.2f", entry.Tips),
+			fmt.Sprintf("%.2f", entry.Amount),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// csvText escapes text entered by users so that spreadsheets do not evaluate it as a formula
+func csvText(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
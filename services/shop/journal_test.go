@@ -0,0 +1,76 @@
+package shop
+
+import (
+	"bytes"
+	"encoding/csv"
+	"slices"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"github.com/WilliamTrojniak/TabAppBackend/models"
+)
+
+func TestCsvText(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected string
+	}{
+		{"", ""},
+		{"Chemistry Department", "Chemistry Department"},
+		{"1234-56789-ABC", "1234-56789-ABC"},
+		{"=HYPERLINK(\"http://example.com\")", "'=HYPERLINK(\"http://example.com\")"},
+		{"+1+1", "'+1+1"},
+		{"-1+1", "'-1+1"},
+		{"@SUM(A1:A2)", "'@SUM(A1:A2)"},
+		{"\t=1+1", "'\t=1+1"},
+		{"\r=1+1", "'\r=1+1"},
+		{"Tab = 1", "Tab = 1"},
+	}
+
+	for _, test := range tests {
+		if got := csvText(test.in); got != test.expected {
+			t.Errorf("csvText(%q): expected %q, got %q", test.in, test.expected, got)
+		}
+	}
+}
+
+func TestWriteJournalCsv(t *testing.T) {
+	entry := models.JournalEntry{
+		InvoiceNumber: 12,
+		Chartstring:   "1234-56789",
+		Organization:  "=cmd|' /C calc'!A0",
+		TabId:         3,
+		TabName:       "Lab, \"weekly\" coffee",
+		BillId:        7,
+		StartDate:     models.Date{Date: civil.Date{Year: 2026, Month: time.March, Day: 1}},
+		EndDate:       models.Date{Date: civil.Date{Year: 2026, Month: time.March, Day: 14}},
+		Subtotal:      20,
+		Discounts:     -2.5,
+		Taxes:         1.58,
+		ServiceFees:   0.9,
+		Tips:          3,
+		Amount:        22.98,
+	}
+
+	var buf bytes.Buffer
+	err := writeJournalCsv(&buf, []models.JournalEntry{entry})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || !slices.Equal(records[0], journalCsvHeader) {
+		t.Fatalf("expected the header and one row, got %q", records)
+	}
+
+	// Only text entered by users is escaped, so negative amounts remain numbers
+	expected := []string{"12", "1234-56789", "'=cmd|' /C calc'!A0", "3", "Lab, \"weekly\" coffee", "7", "2026-03-01", "2026-03-14",
+		"20.00", "-2.50", "1.58", "0.90", "3.00", "22.98"}
+	if !slices.Equal(records[1], expected) {
+		t.Errorf("expected %q, got %q", expected, records[1])
+	}
+}
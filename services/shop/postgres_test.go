@@ -0,0 +1,184 @@
+package shop
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"github.com/WilliamTrojniak/TabAppBackend/cache"
+	"github.com/WilliamTrojniak/TabAppBackend/db"
+	"github.com/WilliamTrojniak/TabAppBackend/models"
+	"github.com/WilliamTrojniak/TabAppBackend/services"
+	"github.com/WilliamTrojniak/TabAppBackend/services/email"
+	"github.com/WilliamTrojniak/TabAppBackend/services/sessions"
+	"github.com/WilliamTrojniak/TabAppBackend/services/user"
+	"github.com/WilliamTrojniak/TabAppBackend/util"
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// memoryCache keeps sessions in memory for handlers under test
+type memoryCache struct {
+	mu     sync.Mutex
+	values map[string][]byte
+}
+
+func (c *memoryCache) Set(ctx context.Context, key string, value []byte, expiration time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[key] = value
+	return nil
+}
+
+func (c *memoryCache) Get(ctx context.Context, key string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	value, ok := c.values[key]
+	if !ok {
+		return nil, cache.ErrNotFound
+	}
+	return value, nil
+}
+
+func (c *memoryCache) Delete(ctx context.Context, keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		delete(c.values, key)
+	}
+	return nil
+}
+
+// testHandler creates a handler backed by the database named by TEST_DATABASE_URL, migrating it to the latest schema.
+// Tests which need a database are skipped when it is not set.
+func testHandler(t *testing.T) *Handler {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	m, err := migrate.New("file://../../cmd/migrate/migrations", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Up()
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		t.Fatal(err)
+	}
+	m.Close()
+
+	config, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := db.NewPostgresStorage(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	emailConfig := &email.Config{UIURI: "http://localhost", Transport: email.TRANSPORT_LOG}
+	transport, err := email.NewTransport(emailConfig, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := util.SystemClock{}
+	emailHandler, err := email.NewHandler(store, transport, clock, emailConfig, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessionManager := sessions.New(&memoryCache{values: map[string][]byte{}}, time.Hour, time.Hour, services.HandleHttpError, logger)
+	userHandler := user.NewHandler(store, sessionManager, services.HandleHttpError, logger)
+	return NewHandler(store, sessionManager, userHandler, emailHandler, clock, services.HandleHttpError, logger)
+}
+
+// testSession signs in as the user
+func testSession(t *testing.T, h *Handler, userId string) *sessions.Session {
+	t.Helper()
+	signedIn := &models.User{}
+	signedIn.Id = userId
+	session, err := h.sessions.SetNewSession(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil), signedIn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return session
+}
+
+// createTestUser creates a user with a unique id and email
+func createTestUser(t *testing.T, h *Handler) string {
+	t.Helper()
+	userId := uuid.NewString()
+	err := db.WithTx(context.Background(), h.store, func(pq *db.PgxQueries) error {
+		_, err := pq.CreateUser(context.Background(), &models.UserCreate{Id: userId, Email: userId + "@example.com", Name: "Test User"})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return userId
+}
+
+// createTestTab creates a tab with a new owner at a new shop, returning the shop, the tab and the tab's owner
+func createTestTab(t *testing.T, h *Handler, status models.TabStatus) (int, int, string) {
+	t.Helper()
+	ctx := context.Background()
+	shopOwnerId := createTestUser(t, h)
+	tabOwnerId := createTestUser(t, h)
+
+	type ids struct{ shopId, tabId int }
+	created, err := db.WithTxRet(ctx, h.store, func(pq *db.PgxQueries) (ids, error) {
+		err := pq.CreateShop(ctx, &models.ShopCreate{
+			OwnerId:    shopOwnerId,
+			ShopUpdate: models.ShopUpdate{Name: "Test Shop", PaymentMethods: []string{"in person"}},
+		})
+		if err != nil {
+			return ids{}, err
+		}
+
+		isMember := true
+		shops, err := pq.GetShops(ctx, &models.GetShopsQueryParams{Limit: 1, IsMember: &isMember, UserId: &shopOwnerId})
+		if err != nil || len(shops) != 1 {
+			return ids{}, errors.Join(errors.New("Failed to find the test shop"), err)
+		}
+		shopId := int(shops[0].Id)
+
+		tabId, err := pq.CreateTab(ctx, &models.TabCreate{
+			TabUpdate: models.TabUpdate{
+				TabBase: models.TabBase{
+					PaymentMethod:           "in person",
+					Organization:            "Test Organization",
+					DisplayName:             "Test Tab",
+					StartDate:               models.Date{Date: civil.Date{Year: 2026, Month: time.March, Day: 1}},
+					EndDate:                 models.Date{Date: civil.Date{Year: 2026, Month: time.March, Day: 31}},
+					DailyStartTime:          models.Time{Duration: 8 * time.Hour},
+					DailyEndTime:            models.Time{Duration: 17 * time.Hour},
+					ActiveDaysOfWk:          127,
+					VerificationMethod:      "specify",
+					BillingIntervalDays:     7,
+					BudgetWarningThresholds: []int{},
+				},
+				VerificationList: []string{},
+				LocationIds:      []int{},
+			},
+			ShopId:  shopId,
+			OwnerId: tabOwnerId,
+		}, status)
+		return ids{shopId, tabId}, err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return created.shopId, created.tabId, tabOwnerId
+}
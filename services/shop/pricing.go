@@ -0,0 +1,67 @@
+package shop
+
+import (
+	"context"
+
+	"github.com/WilliamTrojniak/TabAppBackend/db"
+	"github.com/WilliamTrojniak/TabAppBackend/models"
+	"github.com/WilliamTrojniak/TabAppBackend/services/sessions"
+)
+
+func (h *Handler) GetTabPricing(ctx context.Context, session *sessions.Session, shopId int, tabId int) (models.TabPricing, error) {
+	return db.WithTxRet(ctx, h.store, func(pq *db.PgxQueries) (models.TabPricing, error) {
+		tab, err := pq.GetTabById(ctx, shopId, tabId)
+		if err != nil {
+			return models.TabPricing{}, err
+		}
+
+		err = h.authorizeTabManager(ctx, session, &tab.TabOverview, ROLE_USER_READ_TABS, pq)
+		if err != nil {
+			return models.TabPricing{}, err
+		}
+
+		return pq.GetTabPricing(ctx, shopId, tabId)
+	})
+}
+
+// SetTabPricing replaces the discounts and price overrides negotiated for a tab
+func (h *Handler) SetTabPricing(ctx context.Context, session *sessions.Session, shopId int, tabId int, data *models.TabPricing) error {
+	err := models.ValidateData(data, h.logger)
+	if err != nil {
+		return err
+	}
+
+	return h.WithAuthorize(ctx, session, shopId, ROLE_USER_MANAGE_TABS, func(pq *db.PgxQueries) error {
+		return pq.SetTabPricing(ctx, shopId, tabId, data)
+	})
+}
+
+// priceTabOrder returns the adjustments to an order made by the pricing rules of the tab, followed by
+// the shop's taxes and service fees on the discounted order
+func (h *Handler) priceTabOrder(ctx context.Context, pq *db.PgxQueries, tab *models.TabOverview, items []models.ItemOrder) ([]models.OrderAdjustment, error) {
+	pricing, err := pq.GetTabPricing(ctx, tab.ShopId, tab.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	charges, err := pq.GetShopCharges(ctx, tab.ShopId)
+	if err != nil {
+		return nil, err
+	}
+
+	if pricing.IsEmpty() && len(charges) == 0 {
+		return nil, nil
+	}
+
+	itemIds := make([]int, 0, len(items))
+	for _, item := range items {
+		itemIds = append(itemIds, item.Id)
+	}
+	itemCategoryIds, err := pq.GetItemCategoryIds(ctx, tab.ShopId, itemIds)
+	if err != nil {
+		return nil, err
+	}
+
+	discounts := pricing.Discounts(items, itemCategoryIds)
+	return append(discounts, models.Charges(charges, items, discounts, itemCategoryIds, pricing.IsTaxExempt)...), nil
+}
@@ -2,10 +2,14 @@ package shop
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 
+	"cloud.google.com/go/civil"
 	"github.com/WilliamTrojniak/TabAppBackend/models"
 	"github.com/WilliamTrojniak/TabAppBackend/services"
 )
@@ -19,6 +23,9 @@ const (
 	substitutionGroupIdParam = "substitutionGroupId"
 	tabIdParam               = "tabId"
 	billIdParam              = "billId"
+	orderIdParam             = "orderId"
+	exportIdParam            = "exportId"
+	chargeIdParam            = "chargeId"
 )
 
 func (h *Handler) RegisterRoutes(router *http.ServeMux) {
@@ -52,6 +59,12 @@ func (h *Handler) RegisterRoutes(router *http.ServeMux) {
 	router.HandleFunc(fmt.Sprintf("PATCH /shops/{%v}/categories/{%v}", shopIdParam, categoryIdParam), h.handleUpdateCategory)
 	router.HandleFunc(fmt.Sprintf("DELETE /shops/{%v}/categories/{%v}", shopIdParam, categoryIdParam), h.handleDeleteCategory)
 
+	// Taxes and Service Fees
+	router.HandleFunc(fmt.Sprintf("POST /shops/{%v}/charges", shopIdParam), h.handleCreateShopCharge)
+	router.HandleFunc(fmt.Sprintf("GET /shops/{%v}/charges", shopIdParam), h.handleGetShopCharges)
+	router.HandleFunc(fmt.Sprintf("PATCH /shops/{%v}/charges/{%v}", shopIdParam, chargeIdParam), h.handleUpdateShopCharge)
+	router.HandleFunc(fmt.Sprintf("DELETE /shops/{%v}/charges/{%v}", shopIdParam, chargeIdParam), h.handleDeleteShopCharge)
+
 	// Items
 	router.HandleFunc(fmt.Sprintf("POST /shops/{%v}/items", shopIdParam), h.handleCreateItem)
 	router.HandleFunc(fmt.Sprintf("GET /shops/{%v}/items", shopIdParam), h.handleGetItems)
@@ -77,11 +90,41 @@ func (h *Handler) RegisterRoutes(router *http.ServeMux) {
 	router.HandleFunc(fmt.Sprintf("PATCH /shops/{%v}/tabs/{%v}", shopIdParam, tabIdParam), h.handleUpdateTab)
 	router.HandleFunc(fmt.Sprintf("POST /shops/{%v}/tabs/{%v}/approve", shopIdParam, tabIdParam), h.handleApproveTab)
 	router.HandleFunc(fmt.Sprintf("POST /shops/{%v}/tabs/{%v}/close", shopIdParam, tabIdParam), h.handleCloseTab)
+	router.HandleFunc(fmt.Sprintf("POST /shops/{%v}/tabs/{%v}/reject", shopIdParam, tabIdParam), h.handleRejectTab)
+	router.HandleFunc(fmt.Sprintf("POST /shops/{%v}/tabs/{%v}/renew", shopIdParam, tabIdParam), h.handleRenewTab)
+	router.HandleFunc(fmt.Sprintf("POST /shops/{%v}/tabs/{%v}/transfer", shopIdParam, tabIdParam), h.handleRequestTabOwnershipTransfer)
+	router.HandleFunc(fmt.Sprintf("POST /shops/{%v}/tabs/{%v}/transfer/accept", shopIdParam, tabIdParam), h.handleAcceptTabOwnershipTransfer)
+	router.HandleFunc(fmt.Sprintf("POST /shops/{%v}/tabs/{%v}/transfer/cancel", shopIdParam, tabIdParam), h.handleCancelTabOwnershipTransfer)
+	router.HandleFunc(fmt.Sprintf("POST /shops/{%v}/tabs/{%v}/managers", shopIdParam, tabIdParam), h.handleAddTabManager)
+	router.HandleFunc(fmt.Sprintf("POST /shops/{%v}/tabs/{%v}/managers/remove", shopIdParam, tabIdParam), h.handleRemoveTabManager)
+	router.HandleFunc(fmt.Sprintf("POST /shops/{%v}/tabs/{%v}/updates/reject", shopIdParam, tabIdParam), h.handleRejectTabUpdates)
+	router.HandleFunc(fmt.Sprintf("GET /shops/{%v}/tabs/{%v}/updates/diff", shopIdParam, tabIdParam), h.handleGetTabUpdateDiff)
+	router.HandleFunc(fmt.Sprintf("POST /shops/{%v}/tabs/{%v}/comments", shopIdParam, tabIdParam), h.handleAddTabComment)
+	router.HandleFunc(fmt.Sprintf("GET /shops/{%v}/tabs/{%v}/comments", shopIdParam, tabIdParam), h.handleGetTabComments)
+	router.HandleFunc(fmt.Sprintf("GET /shops/{%v}/tabs/{%v}/pricing", shopIdParam, tabIdParam), h.handleGetTabPricing)
+	router.HandleFunc(fmt.Sprintf("PATCH /shops/{%v}/tabs/{%v}/pricing", shopIdParam, tabIdParam), h.handleSetTabPricing)
+	router.HandleFunc(fmt.Sprintf("POST /shops/{%v}/tabs/{%v}/bills/{%v}/issue", shopIdParam, tabIdParam, billIdParam), h.handleIssueBill)
 	router.HandleFunc(fmt.Sprintf("POST /shops/{%v}/tabs/{%v}/bills/{%v}/close", shopIdParam, tabIdParam, billIdParam), h.handleCloseTabBill)
+	router.HandleFunc(fmt.Sprintf("POST /shops/{%v}/tabs/{%v}/bills/{%v}/void", shopIdParam, tabIdParam, billIdParam), h.handleVoidBill)
+	router.HandleFunc(fmt.Sprintf("POST /shops/{%v}/tabs/{%v}/bills/{%v}/payments", shopIdParam, tabIdParam, billIdParam), h.handleRecordBillPayment)
+	router.HandleFunc(fmt.Sprintf("GET /shops/{%v}/tabs/{%v}/bills/{%v}/payments", shopIdParam, tabIdParam, billIdParam), h.handleGetBillPayments)
+	router.HandleFunc(fmt.Sprintf("GET /shops/{%v}/tabs/{%v}/bills/{%v}/invoice", shopIdParam, tabIdParam, billIdParam), h.handleGetBillInvoice)
+
+	// Vouchers
+	router.HandleFunc(fmt.Sprintf("POST /shops/{%v}/tabs/{%v}/vouchers", shopIdParam, tabIdParam), h.handleCreateVouchers)
+	router.HandleFunc(fmt.Sprintf("GET /shops/{%v}/tabs/{%v}/vouchers", shopIdParam, tabIdParam), h.handleGetVouchers)
+
+	// Journal Exports
+	router.HandleFunc(fmt.Sprintf("POST /shops/{%v}/journal-exports", shopIdParam), h.handleCreateJournalExport)
+	router.HandleFunc(fmt.Sprintf("GET /shops/{%v}/journal-exports", shopIdParam), h.handleGetJournalExports)
+	router.HandleFunc(fmt.Sprintf("GET /shops/{%v}/journal-exports/{%v}", shopIdParam, exportIdParam), h.handleGetJournalExport)
+
+	// Reports
+	router.HandleFunc(fmt.Sprintf("GET /shops/{%v}/reports/tips", shopIdParam), h.handleGetTipsReport)
 
 	// Orders
 	router.HandleFunc(fmt.Sprintf("POST /shops/{%v}/tabs/{%v}/add-order", shopIdParam, tabIdParam), h.handleAddOrderToTab)
-	router.HandleFunc(fmt.Sprintf("POST /shops/{%v}/tabs/{%v}/remove-order", shopIdParam, tabIdParam), h.handleRemoveOrderFromTab)
+	router.HandleFunc(fmt.Sprintf("POST /shops/{%v}/tabs/{%v}/orders/{%v}/void", shopIdParam, tabIdParam, orderIdParam), h.handleVoidOrder)
 
 }
 
@@ -553,6 +596,110 @@ func (h *Handler) handleDeleteCategory(w http.ResponseWriter, r *http.Request) {
 
 }
 
+func (h *Handler) handleCreateShopCharge(w http.ResponseWriter, r *http.Request) {
+	session, err := h.sessions.GetSession(r)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	shopId, err := strconv.Atoi(r.PathValue(shopIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid shop id"))
+		return
+	}
+
+	data := models.ShopChargeCreate{}
+	err = models.ReadRequestJson(r, &data)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+	data.ShopId = shopId
+
+	err = h.CreateShopCharge(r.Context(), session, &data)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+}
+
+func (h *Handler) handleGetShopCharges(w http.ResponseWriter, r *http.Request) {
+	shopId, err := strconv.Atoi(r.PathValue(shopIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid shop id"))
+		return
+	}
+
+	charges, err := h.GetShopCharges(r.Context(), shopId)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(charges)
+}
+
+func (h *Handler) handleUpdateShopCharge(w http.ResponseWriter, r *http.Request) {
+	session, err := h.sessions.GetSession(r)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	shopId, err := strconv.Atoi(r.PathValue(shopIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid shop id"))
+		return
+	}
+
+	chargeId, err := strconv.Atoi(r.PathValue(chargeIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid charge id"))
+		return
+	}
+
+	data := models.ShopChargeUpdate{}
+	err = models.ReadRequestJson(r, &data)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	err = h.UpdateShopCharge(r.Context(), session, shopId, chargeId, &data)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+}
+
+func (h *Handler) handleDeleteShopCharge(w http.ResponseWriter, r *http.Request) {
+	session, err := h.sessions.GetSession(r)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	shopId, err := strconv.Atoi(r.PathValue(shopIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid shop id"))
+		return
+	}
+
+	chargeId, err := strconv.Atoi(r.PathValue(chargeIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid charge id"))
+		return
+	}
+
+	err = h.DeleteShopCharge(r.Context(), session, shopId, chargeId)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+}
+
 func (h *Handler) handleCreateItem(w http.ResponseWriter, r *http.Request) {
 	session, err := h.sessions.GetSession(r)
 	if err != nil {
@@ -939,17 +1086,148 @@ func (h *Handler) handleGetTabs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tabs, err := h.GetTabs(r.Context(), session, shopId)
+	params, err := parseGetTabsQueryParams(r.URL.Query())
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	tabs, next, err := h.GetTabs(r.Context(), session, shopId, &params)
 	if err != nil {
 		h.handleError(w, err)
 		return
 	}
 
+	if next != nil {
+		w.Header().Set("X-Next-Cursor", next.Encode())
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(tabs)
 
 }
 
+// parseGetTabsQueryParams reads the filters, sort and page of a tab list from the query string.
+// Statuses may be given as a comma separated list, and a sort prefixed with '-' is descending.
+// Tabs are only paginated when a limit or cursor is given.
+func parseGetTabsQueryParams(query url.Values) (models.GetTabsQueryParams, error) {
+	const (
+		statusKey          = "status"
+		organizationKey    = "organization"
+		paymentMethodKey   = "payment_method"
+		activeFromKey      = "active_from"
+		activeToKey        = "active_to"
+		pendingBalanceKey  = "pending_balance"
+		pendingUpdatesKey  = "pending_updates"
+		ownerKey           = "owner"
+		searchKey          = "q"
+		sortKey            = "sort"
+		limitKey           = "limit"
+		cursorKey          = "cursor"
+		defaultTabsPerPage = 50
+	)
+
+	params := models.GetTabsQueryParams{
+		Sort: models.TabSortDisplayName,
+	}
+	invalid := func(key string, err error) error {
+		return services.NewValidationServiceError(err, services.ValidationErrors{
+			key: services.ValidationError{Value: query.Get(key), Error: "invalid"},
+		})
+	}
+	optionalString := func(key string) *string {
+		value := query.Get(key)
+		if value == "" {
+			return nil
+		}
+		return &value
+	}
+	optionalBool := func(key string) (*bool, error) {
+		if !query.Has(key) {
+			return nil, nil
+		}
+		value, err := strconv.ParseBool(query.Get(key))
+		if err != nil {
+			return nil, invalid(key, err)
+		}
+		return &value, nil
+	}
+	optionalDate := func(key string) (*models.Date, error) {
+		if !query.Has(key) {
+			return nil, nil
+		}
+		value, err := civil.ParseDate(query.Get(key))
+		if err != nil {
+			return nil, invalid(key, err)
+		}
+		return &models.Date{Date: value}, nil
+	}
+
+	for _, value := range query[statusKey] {
+		for _, status := range strings.Split(value, ",") {
+			if status != "" {
+				params.Statuses = append(params.Statuses, status)
+			}
+		}
+	}
+	params.Organization = optionalString(organizationKey)
+	params.PaymentMethod = optionalString(paymentMethodKey)
+	params.OwnerId = optionalString(ownerKey)
+	params.Search = optionalString(searchKey)
+
+	var err error
+	params.ActiveFrom, err = optionalDate(activeFromKey)
+	if err != nil {
+		return params, err
+	}
+	params.ActiveTo, err = optionalDate(activeToKey)
+	if err != nil {
+		return params, err
+	}
+	params.IsPendingBalance, err = optionalBool(pendingBalanceKey)
+	if err != nil {
+		return params, err
+	}
+	params.HasPendingUpdates, err = optionalBool(pendingUpdatesKey)
+	if err != nil {
+		return params, err
+	}
+
+	if query.Has(sortKey) {
+		sort := query.Get(sortKey)
+		params.Descending = strings.HasPrefix(sort, "-")
+		params.Sort = strings.TrimPrefix(sort, "-")
+	}
+
+	if query.Has(limitKey) {
+		params.Limit, err = strconv.Atoi(query.Get(limitKey))
+		if err != nil {
+			return params, invalid(limitKey, err)
+		}
+		if params.Limit < 1 {
+			return params, invalid(limitKey, errors.New("Limit must be at least 1"))
+		}
+	}
+
+	if query.Has(cursorKey) {
+		if params.Limit == 0 {
+			params.Limit = defaultTabsPerPage
+		}
+		params.Cursor, err = models.DecodeTabsCursor(query.Get(cursorKey))
+		if err != nil {
+			return params, invalid(cursorKey, err)
+		}
+		// Date cursors are compared as dates, so they must hold one
+		if params.Sort == models.TabSortStartDate || params.Sort == models.TabSortEndDate {
+			_, err = civil.ParseDate(params.Cursor.Value)
+			if err != nil {
+				return params, invalid(cursorKey, err)
+			}
+		}
+	}
+
+	return params, nil
+}
+
 func (h *Handler) handleGetTabById(w http.ResponseWriter, r *http.Request) {
 	session, err := h.sessions.GetSession(r)
 	if err != nil {
@@ -1099,7 +1377,7 @@ func (h *Handler) handleAddOrderToTab(w http.ResponseWriter, r *http.Request) {
 
 }
 
-func (h *Handler) handleRemoveOrderFromTab(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) handleRenewTab(w http.ResponseWriter, r *http.Request) {
 	session, err := h.sessions.GetSession(r)
 	if err != nil {
 		h.handleError(w, err)
@@ -1118,22 +1396,24 @@ func (h *Handler) handleRemoveOrderFromTab(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	data := models.BillOrderCreate{}
+	data := models.TabRenew{}
 	err = models.ReadRequestJson(r, &data)
 	if err != nil {
 		h.handleError(w, err)
 		return
 	}
 
-	err = h.RemoveOrderFromTab(r.Context(), session, shopId, tabId, &data)
+	tab, err := h.RenewTab(r.Context(), session, shopId, tabId, &data)
 	if err != nil {
 		h.handleError(w, err)
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tab)
 }
 
-func (h *Handler) handleCloseTabBill(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) handleRequestTabOwnershipTransfer(w http.ResponseWriter, r *http.Request) {
 	session, err := h.sessions.GetSession(r)
 	if err != nil {
 		h.handleError(w, err)
@@ -1152,16 +1432,832 @@ func (h *Handler) handleCloseTabBill(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	billId, err := strconv.Atoi(r.PathValue(billIdParam))
+	data := models.TabOwnershipTransferCreate{}
+	err = models.ReadRequestJson(r, &data)
 	if err != nil {
-		h.handleError(w, services.NewValidationServiceError(err, "Invalid bill id"))
+		h.handleError(w, err)
 		return
 	}
 
-	err = h.MarkTabBillPaid(r.Context(), session, shopId, tabId, billId)
+	err = h.RequestTabOwnershipTransfer(r.Context(), session, shopId, tabId, &data)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+}
+
+func (h *Handler) handleAcceptTabOwnershipTransfer(w http.ResponseWriter, r *http.Request) {
+	session, err := h.sessions.GetSession(r)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	shopId, err := strconv.Atoi(r.PathValue(shopIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid shop id"))
+		return
+	}
+
+	tabId, err := strconv.Atoi(r.PathValue(tabIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid tab id"))
+		return
+	}
+
+	err = h.AcceptTabOwnershipTransfer(r.Context(), session, shopId, tabId)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+}
+
+func (h *Handler) handleCancelTabOwnershipTransfer(w http.ResponseWriter, r *http.Request) {
+	session, err := h.sessions.GetSession(r)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	shopId, err := strconv.Atoi(r.PathValue(shopIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid shop id"))
+		return
+	}
+
+	tabId, err := strconv.Atoi(r.PathValue(tabIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid tab id"))
+		return
+	}
+
+	err = h.CancelTabOwnershipTransfer(r.Context(), session, shopId, tabId)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+}
+
+func (h *Handler) handleAddTabManager(w http.ResponseWriter, r *http.Request) {
+	session, err := h.sessions.GetSession(r)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	shopId, err := strconv.Atoi(r.PathValue(shopIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid shop id"))
+		return
+	}
+
+	tabId, err := strconv.Atoi(r.PathValue(tabIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid tab id"))
+		return
+	}
+
+	data := models.TabManagerCreate{}
+	err = models.ReadRequestJson(r, &data)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	err = h.AddTabManager(r.Context(), session, shopId, tabId, &data)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+}
+
+func (h *Handler) handleRemoveTabManager(w http.ResponseWriter, r *http.Request) {
+	session, err := h.sessions.GetSession(r)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	shopId, err := strconv.Atoi(r.PathValue(shopIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid shop id"))
+		return
+	}
+
+	tabId, err := strconv.Atoi(r.PathValue(tabIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid tab id"))
+		return
+	}
+
+	data := models.TabManagerCreate{}
+	err = models.ReadRequestJson(r, &data)
 	if err != nil {
 		h.handleError(w, err)
 		return
 	}
 
+	err = h.RemoveTabManager(r.Context(), session, shopId, tabId, &data)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+}
+
+func (h *Handler) handleAddTabComment(w http.ResponseWriter, r *http.Request) {
+	session, err := h.sessions.GetSession(r)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	shopId, err := strconv.Atoi(r.PathValue(shopIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid shop id"))
+		return
+	}
+
+	tabId, err := strconv.Atoi(r.PathValue(tabIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid tab id"))
+		return
+	}
+
+	data := models.TabCommentCreate{}
+	err = models.ReadRequestJson(r, &data)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	comment, err := h.AddTabComment(r.Context(), session, shopId, tabId, &data)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(comment)
+}
+
+func (h *Handler) handleGetTabComments(w http.ResponseWriter, r *http.Request) {
+	session, err := h.sessions.GetSession(r)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	shopId, err := strconv.Atoi(r.PathValue(shopIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid shop id"))
+		return
+	}
+
+	tabId, err := strconv.Atoi(r.PathValue(tabIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid tab id"))
+		return
+	}
+
+	comments, err := h.GetTabComments(r.Context(), session, shopId, tabId)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(comments)
+}
+
+func (h *Handler) handleGetTabPricing(w http.ResponseWriter, r *http.Request) {
+	session, err := h.sessions.GetSession(r)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	shopId, err := strconv.Atoi(r.PathValue(shopIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid shop id"))
+		return
+	}
+
+	tabId, err := strconv.Atoi(r.PathValue(tabIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid tab id"))
+		return
+	}
+
+	pricing, err := h.GetTabPricing(r.Context(), session, shopId, tabId)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(pricing)
+}
+
+func (h *Handler) handleSetTabPricing(w http.ResponseWriter, r *http.Request) {
+	session, err := h.sessions.GetSession(r)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	shopId, err := strconv.Atoi(r.PathValue(shopIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid shop id"))
+		return
+	}
+
+	tabId, err := strconv.Atoi(r.PathValue(tabIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid tab id"))
+		return
+	}
+
+	data := models.TabPricing{}
+	err = models.ReadRequestJson(r, &data)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	err = h.SetTabPricing(r.Context(), session, shopId, tabId, &data)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+}
+
+func (h *Handler) handleRejectTab(w http.ResponseWriter, r *http.Request) {
+	session, err := h.sessions.GetSession(r)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	shopId, err := strconv.Atoi(r.PathValue(shopIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid shop id"))
+		return
+	}
+
+	tabId, err := strconv.Atoi(r.PathValue(tabIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid tab id"))
+		return
+	}
+
+	err = h.RejectTab(r.Context(), session, shopId, tabId)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+}
+
+func (h *Handler) handleRejectTabUpdates(w http.ResponseWriter, r *http.Request) {
+	session, err := h.sessions.GetSession(r)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	shopId, err := strconv.Atoi(r.PathValue(shopIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid shop id"))
+		return
+	}
+
+	tabId, err := strconv.Atoi(r.PathValue(tabIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid tab id"))
+		return
+	}
+
+	data := models.TabUpdateReject{}
+	err = models.ReadRequestJson(r, &data)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	err = h.RejectTabUpdates(r.Context(), session, shopId, tabId, &data)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+}
+
+func (h *Handler) handleGetTabUpdateDiff(w http.ResponseWriter, r *http.Request) {
+	session, err := h.sessions.GetSession(r)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	shopId, err := strconv.Atoi(r.PathValue(shopIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid shop id"))
+		return
+	}
+
+	tabId, err := strconv.Atoi(r.PathValue(tabIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid tab id"))
+		return
+	}
+
+	diff, err := h.GetTabUpdateDiff(r.Context(), session, shopId, tabId)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(diff)
+}
+
+func (h *Handler) handleVoidOrder(w http.ResponseWriter, r *http.Request) {
+	session, err := h.sessions.GetSession(r)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	shopId, err := strconv.Atoi(r.PathValue(shopIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid shop id"))
+		return
+	}
+
+	tabId, err := strconv.Atoi(r.PathValue(tabIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid tab id"))
+		return
+	}
+
+	orderId, err := strconv.Atoi(r.PathValue(orderIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid order id"))
+		return
+	}
+
+	data := models.OrderVoid{}
+	err = models.ReadRequestJson(r, &data)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	order, err := h.VoidOrder(r.Context(), session, shopId, tabId, orderId, &data)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(order)
+}
+
+func (h *Handler) handleIssueBill(w http.ResponseWriter, r *http.Request) {
+	session, err := h.sessions.GetSession(r)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	shopId, err := strconv.Atoi(r.PathValue(shopIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid shop id"))
+		return
+	}
+
+	tabId, err := strconv.Atoi(r.PathValue(tabIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid tab id"))
+		return
+	}
+
+	billId, err := strconv.Atoi(r.PathValue(billIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid bill id"))
+		return
+	}
+
+	err = h.IssueBill(r.Context(), session, shopId, tabId, billId)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+}
+
+func (h *Handler) handleVoidBill(w http.ResponseWriter, r *http.Request) {
+	session, err := h.sessions.GetSession(r)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	shopId, err := strconv.Atoi(r.PathValue(shopIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid shop id"))
+		return
+	}
+
+	tabId, err := strconv.Atoi(r.PathValue(tabIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid tab id"))
+		return
+	}
+
+	billId, err := strconv.Atoi(r.PathValue(billIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid bill id"))
+		return
+	}
+
+	data := models.BillVoid{}
+	err = models.ReadRequestJson(r, &data)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	err = h.VoidBill(r.Context(), session, shopId, tabId, billId, &data)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+}
+
+func (h *Handler) handleRecordBillPayment(w http.ResponseWriter, r *http.Request) {
+	session, err := h.sessions.GetSession(r)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	shopId, err := strconv.Atoi(r.PathValue(shopIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid shop id"))
+		return
+	}
+
+	tabId, err := strconv.Atoi(r.PathValue(tabIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid tab id"))
+		return
+	}
+
+	billId, err := strconv.Atoi(r.PathValue(billIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid bill id"))
+		return
+	}
+
+	data := models.BillPaymentCreate{}
+	err = models.ReadRequestJson(r, &data)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	payment, err := h.RecordBillPayment(r.Context(), session, shopId, tabId, billId, &data)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(payment)
+}
+
+func (h *Handler) handleGetBillPayments(w http.ResponseWriter, r *http.Request) {
+	session, err := h.sessions.GetSession(r)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	shopId, err := strconv.Atoi(r.PathValue(shopIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid shop id"))
+		return
+	}
+
+	tabId, err := strconv.Atoi(r.PathValue(tabIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid tab id"))
+		return
+	}
+
+	billId, err := strconv.Atoi(r.PathValue(billIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid bill id"))
+		return
+	}
+
+	payments, err := h.GetBillPayments(r.Context(), session, shopId, tabId, billId)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(payments)
+}
+
+func (h *Handler) handleGetBillInvoice(w http.ResponseWriter, r *http.Request) {
+	session, err := h.sessions.GetSession(r)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	shopId, err := strconv.Atoi(r.PathValue(shopIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid shop id"))
+		return
+	}
+
+	tabId, err := strconv.Atoi(r.PathValue(tabIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid tab id"))
+		return
+	}
+
+	billId, err := strconv.Atoi(r.PathValue(billIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid bill id"))
+		return
+	}
+
+	bill, invoice, err := h.GetBillInvoice(r.Context(), session, shopId, tabId, billId)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	filename := fmt.Sprintf("bill-%v-%v.pdf", tabId, bill.Id)
+	if bill.InvoiceNumber != nil {
+		filename = fmt.Sprintf("invoice-%v.pdf", *bill.InvoiceNumber)
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="%v"`, filename))
+	w.Write(invoice)
+}
+
+func (h *Handler) handleCloseTabBill(w http.ResponseWriter, r *http.Request) {
+	session, err := h.sessions.GetSession(r)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	shopId, err := strconv.Atoi(r.PathValue(shopIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid shop id"))
+		return
+	}
+
+	tabId, err := strconv.Atoi(r.PathValue(tabIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid tab id"))
+		return
+	}
+
+	billId, err := strconv.Atoi(r.PathValue(billIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid bill id"))
+		return
+	}
+
+	err = h.MarkTabBillPaid(r.Context(), session, shopId, tabId, billId)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+}
+
+func (h *Handler) handleCreateVouchers(w http.ResponseWriter, r *http.Request) {
+	session, err := h.sessions.GetSession(r)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	shopId, err := strconv.Atoi(r.PathValue(shopIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid shop id"))
+		return
+	}
+
+	tabId, err := strconv.Atoi(r.PathValue(tabIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid tab id"))
+		return
+	}
+
+	data := models.VoucherBatchCreate{}
+	err = models.ReadRequestJson(r, &data)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	vouchers, err := h.CreateVouchers(r.Context(), session, shopId, tabId, &data)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(vouchers)
+}
+
+func (h *Handler) handleGetVouchers(w http.ResponseWriter, r *http.Request) {
+	session, err := h.sessions.GetSession(r)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	shopId, err := strconv.Atoi(r.PathValue(shopIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid shop id"))
+		return
+	}
+
+	tabId, err := strconv.Atoi(r.PathValue(tabIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid tab id"))
+		return
+	}
+
+	vouchers, err := h.GetVouchers(r.Context(), session, shopId, tabId)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(vouchers)
+}
+
+func (h *Handler) handleCreateJournalExport(w http.ResponseWriter, r *http.Request) {
+	session, err := h.sessions.GetSession(r)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	shopId, err := strconv.Atoi(r.PathValue(shopIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid shop id"))
+		return
+	}
+
+	data := models.JournalExportCreate{}
+	err = models.ReadRequestJson(r, &data)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	export, entries, err := h.CreateJournalExport(r.Context(), session, shopId, &data)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	h.writeJournalExport(w, shopId, &export, entries)
+}
+
+func (h *Handler) handleGetJournalExports(w http.ResponseWriter, r *http.Request) {
+	session, err := h.sessions.GetSession(r)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	shopId, err := strconv.Atoi(r.PathValue(shopIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid shop id"))
+		return
+	}
+
+	exports, err := h.GetJournalExports(r.Context(), session, shopId)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(exports)
+}
+
+func (h *Handler) handleGetJournalExport(w http.ResponseWriter, r *http.Request) {
+	session, err := h.sessions.GetSession(r)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	shopId, err := strconv.Atoi(r.PathValue(shopIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid shop id"))
+		return
+	}
+
+	exportId, err := strconv.Atoi(r.PathValue(exportIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid export id"))
+		return
+	}
+
+	export, entries, err := h.GetJournalExport(r.Context(), session, shopId, exportId)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	h.writeJournalExport(w, shopId, &export, entries)
+}
+
+func (h *Handler) writeJournalExport(w http.ResponseWriter, shopId int, export *models.JournalExport, entries []models.JournalEntry) {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="journal-%v-%v.csv"`, shopId, export.Id))
+	err := writeJournalCsv(w, entries)
+	if err != nil {
+		h.logger.Error("Failed to write journal export", "shopId", shopId, "exportId", export.Id, "err", err)
+	}
+}
+
+func (h *Handler) handleGetTipsReport(w http.ResponseWriter, r *http.Request) {
+	session, err := h.sessions.GetSession(r)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	shopId, err := strconv.Atoi(r.PathValue(shopIdParam))
+	if err != nil {
+		h.handleError(w, services.NewValidationServiceError(err, "Invalid shop id"))
+		return
+	}
+
+	params, err := parseTipsReportQueryParams(r.URL.Query())
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	entries, err := h.GetTipsReport(r.Context(), session, shopId, &params)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// parseTipsReportQueryParams reads the optional date range of a tips report from the query string
+func parseTipsReportQueryParams(query url.Values) (models.TipsReportQueryParams, error) {
+	const (
+		fromKey = "from"
+		toKey   = "to"
+	)
+
+	params := models.TipsReportQueryParams{}
+	optionalDate := func(key string) (*models.Date, error) {
+		if !query.Has(key) {
+			return nil, nil
+		}
+		value, err := civil.ParseDate(query.Get(key))
+		if err != nil {
+			return nil, services.NewValidationServiceError(err, services.ValidationErrors{
+				key: services.ValidationError{Value: query.Get(key), Error: "invalid"},
+			})
+		}
+		return &models.Date{Date: value}, nil
+	}
+
+	var err error
+	params.From, err = optionalDate(fromKey)
+	if err != nil {
+		return params, err
+	}
+	params.To, err = optionalDate(toKey)
+	if err != nil {
+		return params, err
+	}
+	return params, nil
 }
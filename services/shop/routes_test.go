@@ -0,0 +1,125 @@
+package shop
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/WilliamTrojniak/TabAppBackend/models"
+	"github.com/WilliamTrojniak/TabAppBackend/services"
+)
+
+// invalidFields returns the fields reported by a validation error, failing the test for any other error
+func invalidFields(t *testing.T, err error) []string {
+	t.Helper()
+	serviceErr, ok := err.(*services.ServiceError)
+	if !ok || serviceErr.StatusCode() != http.StatusBadRequest {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+	errs, _ := serviceErr.Data().(services.ValidationErrors)
+	fields := make([]string, 0, len(errs))
+	for field := range errs {
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+func TestParseGetTabsQueryParams(t *testing.T) {
+	cursor := (&models.TabsCursor{Sort: models.TabSortStartDate, Value: "2026-03-01", Id: 4}).Encode()
+
+	params, err := parseGetTabsQueryParams(url.Values{
+		"status":          {"confirmed,pending", "closed"},
+		"sort":            {"-start_date"},
+		"cursor":          {cursor},
+		"pending_balance": {"true"},
+		"active_from":     {"2026-03-01"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(params.Statuses) != 3 || params.Statuses[2] != "closed" {
+		t.Errorf("expected statuses from every parameter, got %v", params.Statuses)
+	}
+	if params.Sort != models.TabSortStartDate || !params.Descending {
+		t.Errorf("expected a descending start date sort, got %v descending %v", params.Sort, params.Descending)
+	}
+	if params.Limit != 50 {
+		t.Errorf("expected a cursor without a limit to use the default page size, got %v", params.Limit)
+	}
+	if params.Cursor == nil || params.Cursor.Id != 4 {
+		t.Errorf("expected the cursor to be decoded, got %v", params.Cursor)
+	}
+	if params.IsPendingBalance == nil || !*params.IsPendingBalance || params.HasPendingUpdates != nil {
+		t.Errorf("expected only the given flags to be set, got %v and %v", params.IsPendingBalance, params.HasPendingUpdates)
+	}
+
+	params, err = parseGetTabsQueryParams(url.Values{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.Sort != models.TabSortDisplayName || params.Limit != 0 || params.Cursor != nil {
+		t.Errorf("expected every tab sorted by display name by default, got %+v", params)
+	}
+}
+
+func TestParseGetTabsQueryParamsInvalid(t *testing.T) {
+	dateCursor := (&models.TabsCursor{Sort: models.TabSortStartDate, Value: "Test Tab", Id: 4}).Encode()
+
+	tests := []struct {
+		name  string
+		query url.Values
+		field string
+	}{
+		{"limit which is not a number", url.Values{"limit": {"ten"}}, "limit"},
+		{"zero limit", url.Values{"limit": {"0"}}, "limit"},
+		{"negative limit", url.Values{"limit": {"-5"}}, "limit"},
+		{"cursor which is not base64", url.Values{"cursor": {"not a cursor!"}}, "cursor"},
+		{"cursor which is not json", url.Values{"cursor": {"bm90IGpzb24"}}, "cursor"},
+		{"date cursor without a date", url.Values{"sort": {"start_date"}, "cursor": {dateCursor}}, "cursor"},
+		{"flag which is not a bool", url.Values{"pending_updates": {"maybe"}}, "pending_updates"},
+		{"date which is not a date", url.Values{"active_to": {"03/01/2026"}}, "active_to"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := parseGetTabsQueryParams(test.query)
+			fields := invalidFields(t, err)
+			if len(fields) != 1 || fields[0] != test.field {
+				t.Errorf("expected %v to be invalid, got %v", test.field, fields)
+			}
+		})
+	}
+}
+
+// Values which parse but are out of range are rejected when the params are validated
+func TestGetTabsRejectsInvalidParams(t *testing.T) {
+	h := &Handler{logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	nameCursor := (&models.TabsCursor{Sort: models.TabSortDisplayName, Value: "Test Tab", Id: 4}).Encode()
+
+	tests := []struct {
+		name  string
+		query url.Values
+	}{
+		{"unknown sort", url.Values{"sort": {"owner"}}},
+		{"unknown descending sort", url.Values{"sort": {"-id"}}},
+		{"limit above the maximum", url.Values{"limit": {"201"}}},
+		{"unknown status", url.Values{"status": {"confirmed,archived"}}},
+		{"cursor for another sort", url.Values{"sort": {"organization"}, "cursor": {nameCursor}}},
+		{"cursor for another direction", url.Values{"sort": {"-display_name"}, "cursor": {nameCursor}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			params, err := parseGetTabsQueryParams(test.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, _, err = h.GetTabs(context.Background(), nil, 1, &params)
+			invalidFields(t, err)
+		})
+	}
+}
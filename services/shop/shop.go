@@ -8,8 +8,10 @@ import (
 	"github.com/WilliamTrojniak/TabAppBackend/db"
 	"github.com/WilliamTrojniak/TabAppBackend/models"
 	"github.com/WilliamTrojniak/TabAppBackend/services"
+	"github.com/WilliamTrojniak/TabAppBackend/services/email"
 	"github.com/WilliamTrojniak/TabAppBackend/services/sessions"
 	"github.com/WilliamTrojniak/TabAppBackend/services/user"
+	"github.com/WilliamTrojniak/TabAppBackend/util"
 )
 
 type Handler struct {
@@ -17,15 +19,19 @@ type Handler struct {
 	store       *db.PgxStore
 	sessions    *sessions.Handler
 	users       *user.Handler
+	emails      *email.Handler
+	clock       util.Clock
 	handleError services.HTTPErrorHandler
 }
 
-func NewHandler(store *db.PgxStore, sessions *sessions.Handler, userHandler *user.Handler, handleError services.HTTPErrorHandler, logger *slog.Logger) *Handler {
+func NewHandler(store *db.PgxStore, sessions *sessions.Handler, userHandler *user.Handler, emailHandler *email.Handler, clock util.Clock, handleError services.HTTPErrorHandler, logger *slog.Logger) *Handler {
 	return &Handler{
 		logger:      logger,
 		sessions:    sessions,
 		store:       store,
 		users:       userHandler,
+		emails:      emailHandler,
+		clock:       clock,
 		handleError: handleError,
 	}
 }
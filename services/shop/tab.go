@@ -3,11 +3,16 @@ package shop
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net/http"
 	"reflect"
+	"slices"
+	"strings"
 
 	"github.com/WilliamTrojniak/TabAppBackend/db"
 	"github.com/WilliamTrojniak/TabAppBackend/models"
 	"github.com/WilliamTrojniak/TabAppBackend/services"
+	"github.com/WilliamTrojniak/TabAppBackend/services/email"
 	"github.com/WilliamTrojniak/TabAppBackend/services/sessions"
 )
 
@@ -17,6 +22,12 @@ func (h *Handler) CreateTab(ctx context.Context, session *sessions.Session, data
 	if err != nil {
 		return err
 	}
+	data.NormalizeBudgetWarnings()
+
+	userId, err := session.GetUserId()
+	if err != nil {
+		return err
+	}
 
 	return db.WithTx(ctx, h.store, func(pq *db.PgxQueries) error {
 		userRoles, err := h.GetShopUserPermissions(ctx, session, data.ShopId)
@@ -30,15 +41,92 @@ func (h *Handler) CreateTab(ctx context.Context, session *sessions.Session, data
 			status = models.TAB_STATUS_CONFIRMED
 		}
 
-		err = pq.CreateTab(ctx, data, status)
+		tabId, err := pq.CreateTab(ctx, data, status)
 		if err != nil {
 			return err
 		}
 
+		err = pq.AddTabEvent(ctx, data.ShopId, tabId, models.TAB_COMMENT_KIND_CREATED, &userId, nil)
+		if err != nil {
+			return err
+		}
+
+		if status == models.TAB_STATUS_PENDING {
+			tab := models.TabOverview{TabBase: data.TabBase, Id: tabId, ShopId: data.ShopId}
+			return h.emailShopOwner(ctx, pq, &tab, email.TEMPLATE_TAB_REQUESTED)
+		}
+
 		return nil
 	})
 }
 
+// RenewTab creates a tab for a new term copying the settings, locations and verification list of an
+// existing tab. As with CreateTab, the new tab must be approved unless the user may manage tabs.
+func (h *Handler) RenewTab(ctx context.Context, session *sessions.Session, shopId int, tabId int, data *models.TabRenew) (models.Tab, error) {
+	userId, err := session.GetUserId()
+	if err != nil {
+		return models.Tab{}, err
+	}
+
+	err = models.ValidateData(data, h.logger)
+	if err != nil {
+		return models.Tab{}, err
+	}
+
+	return db.WithTxRet(ctx, h.store, func(pq *db.PgxQueries) (models.Tab, error) {
+		tab, err := pq.GetTabById(ctx, shopId, tabId)
+		if err != nil {
+			return models.Tab{}, err
+		}
+
+		err = h.authorizeTabOwner(ctx, session, &tab.TabOverview, ROLE_USER_MANAGE_TABS, pq)
+		if err != nil {
+			return models.Tab{}, err
+		}
+
+		renewal := tab.Renewal(data)
+		err = models.ValidateData(&renewal, h.logger)
+		if err != nil {
+			return models.Tab{}, err
+		}
+		renewal.NormalizeBudgetWarnings()
+
+		userRoles, err := h.GetShopUserPermissions(ctx, session, shopId)
+		if err != nil {
+			return models.Tab{}, err
+		}
+
+		status := models.TAB_STATUS_PENDING
+		if (userRoles&ROLE_USER_OWNER) == ROLE_USER_OWNER || (userRoles&ROLE_USER_MANAGE_TABS) == ROLE_USER_MANAGE_TABS {
+			status = models.TAB_STATUS_CONFIRMED
+		}
+
+		renewedTabId, err := pq.RenewTab(ctx, tabId, &renewal, status)
+		if err != nil {
+			return models.Tab{}, err
+		}
+
+		err = pq.AddTabEvent(ctx, shopId, renewedTabId, models.TAB_COMMENT_KIND_CREATED, &userId, nil)
+		if err != nil {
+			return models.Tab{}, err
+		}
+
+		renewed, err := pq.GetTabById(ctx, shopId, renewedTabId)
+		if err != nil {
+			return models.Tab{}, err
+		}
+
+		if status == models.TAB_STATUS_PENDING {
+			err = h.emailShopOwner(ctx, pq, &renewed.TabOverview, email.TEMPLATE_TAB_REQUESTED)
+			if err != nil {
+				return models.Tab{}, err
+			}
+		}
+
+		return renewed, nil
+	})
+}
+
 func (h *Handler) UpdateTab(ctx context.Context, session *sessions.Session, shopId int, tabId int, data *models.TabUpdate) error {
 	userId, err := session.GetUserId()
 	if err != nil {
@@ -57,36 +145,57 @@ func (h *Handler) UpdateTab(ctx context.Context, session *sessions.Session, shop
 		}
 
 		authErr := h.Authorize(ctx, session, shopId, ROLE_USER_MANAGE_TABS, pq)
-		if authErr != nil && userId != tab.OwnerId {
-			return authErr
+		isOwner := userId == tab.OwnerId
+		if authErr != nil && !isOwner {
+			// Managers may only change the verification list of the tab
+			managerErr := h.authorizeTabManager(ctx, session, &tab.TabOverview, ROLE_USER_MANAGE_TABS, pq)
+			if managerErr != nil {
+				return managerErr
+			}
 		}
 
-		if reflect.DeepEqual(tab.TabBase, data.TabBase) && reflect.DeepEqual(tab.VerificationList, data.VerificationList) {
+		tab.NormalizeBudgetWarnings()
+		data.NormalizeBudgetWarnings()
+
+		sameLocations := sameTabLocations(&tab.TabOverview, data.LocationIds)
+		if reflect.DeepEqual(tab.TabBase, data.TabBase) && sameLocations && reflect.DeepEqual(tab.VerificationList, data.VerificationList) {
 			return nil
 		}
 
-		if authErr == nil || (userId == tab.OwnerId && tab.Status == models.TAB_STATUS_PENDING.String()) {
+		if authErr != nil && !isOwner {
+			if !reflect.DeepEqual(tab.TabBase, data.TabBase) || !sameLocations {
+				return services.NewUnauthorizedServiceError(errors.New("Tab managers may only change the verification list"))
+			}
+			if tab.Status != models.TAB_STATUS_PENDING.String() && tab.Status != models.TAB_STATUS_CONFIRMED.String() {
+				return services.NewDataConflictServiceError(fmt.Errorf("Cannot update %v tab", tab.Status))
+			}
+			return pq.SetTabUsers(ctx, shopId, tabId, data.VerificationList)
+		}
+
+		if authErr == nil || (isOwner && tab.Status == models.TAB_STATUS_PENDING.String()) {
 			// If the request is made by the shop owner, or if the tab has not yet been confirmed, update the tab directly
 			err = pq.UpdateTab(ctx, shopId, tabId, data)
 			if err != nil {
 				return err
 			}
 		} else if tab.Status == models.TAB_STATUS_CONFIRMED.String() {
-			tabLocationIds := make([]uint, 0)
-			for _, location := range tab.Locations {
-				tabLocationIds = append(tabLocationIds, location.Id)
-			}
 			// Here it must be the case that the user is the tab owner, so only request an update so long as the tab is in the confirmed state
-			if !reflect.DeepEqual(tab.TabBase, data.TabBase) || !reflect.DeepEqual(tabLocationIds, data.LocationIds) {
+			if !reflect.DeepEqual(tab.TabBase, data.TabBase) || !sameLocations {
 				err = pq.SetTabUpdates(ctx, shopId, tabId, data)
+				if err == nil {
+					err = pq.AddTabEvent(ctx, shopId, tabId, models.TAB_COMMENT_KIND_UPDATE_REQUESTED, &userId, nil)
+				}
+				if err == nil {
+					err = h.emailShopOwner(ctx, pq, &tab.TabOverview, email.TEMPLATE_TAB_UPDATE_REQUESTED)
+				}
 			} else {
 				err = pq.SetTabUsers(ctx, shopId, tabId, data.VerificationList)
 			}
 			if err != nil {
 				return err
 			}
-		} else if tab.Status == models.TAB_STATUS_CLOSED.String() {
-			return services.NewDataConflictServiceError(errors.New("Cannot update closed tab"))
+		} else if tab.Status == models.TAB_STATUS_CLOSED.String() || tab.Status == models.TAB_STATUS_EXPIRED.String() || tab.Status == models.TAB_STATUS_REJECTED.String() {
+			return services.NewDataConflictServiceError(fmt.Errorf("Cannot update %v tab", tab.Status))
 		} else {
 			h.logger.Error("Unknown tab state in update tab", "userId", userId, "tab", tab)
 			return services.NewInternalServiceError(errors.New("Unknown tab state"))
@@ -96,6 +205,11 @@ func (h *Handler) UpdateTab(ctx context.Context, session *sessions.Session, shop
 }
 
 func (h *Handler) ApproveTab(ctx context.Context, session *sessions.Session, shopId int, tabId int) error {
+	userId, err := session.GetUserId()
+	if err != nil {
+		return err
+	}
+
 	return h.WithAuthorize(ctx, session, shopId, ROLE_USER_MANAGE_TABS, func(pq *db.PgxQueries) error {
 		tab, err := pq.GetTabById(ctx, shopId, tabId)
 		if err != nil {
@@ -110,11 +224,109 @@ func (h *Handler) ApproveTab(ctx context.Context, session *sessions.Session, sho
 			return err
 		}
 
+		err = pq.AddTabEvent(ctx, shopId, tabId, models.TAB_COMMENT_KIND_APPROVED, &userId, nil)
+		if err != nil {
+			return err
+		}
+
+		if tab.Status == models.TAB_STATUS_PENDING.String() {
+			return h.emailTabOwner(ctx, pq, &tab.TabOverview, email.TEMPLATE_TAB_APPROVED)
+		}
+
 		return nil
 	})
 }
 
+// RejectTab declines a tab which has been requested but not yet approved
+func (h *Handler) RejectTab(ctx context.Context, session *sessions.Session, shopId int, tabId int) error {
+	userId, err := session.GetUserId()
+	if err != nil {
+		return err
+	}
+
+	return h.WithAuthorize(ctx, session, shopId, ROLE_USER_MANAGE_TABS, func(pq *db.PgxQueries) error {
+		tab, err := pq.GetTabById(ctx, shopId, tabId)
+		if err != nil {
+			return err
+		}
+		if tab.Status != models.TAB_STATUS_PENDING.String() {
+			return services.NewDataConflictServiceError(errors.New("Only pending tabs can be rejected"))
+		}
+
+		err = pq.RejectTab(ctx, shopId, tabId, h.clock.Now())
+		if err != nil {
+			return err
+		}
+
+		err = pq.AddTabEvent(ctx, shopId, tabId, models.TAB_COMMENT_KIND_REJECTED, &userId, nil)
+		if err != nil {
+			return err
+		}
+
+		return h.emailTabOwner(ctx, pq, &tab.TabOverview, email.TEMPLATE_TAB_REJECTED)
+	})
+}
+
+// RejectTabUpdates declines the changes requested by the tab owner, leaving the tab as it is
+func (h *Handler) RejectTabUpdates(ctx context.Context, session *sessions.Session, shopId int, tabId int, data *models.TabUpdateReject) error {
+	userId, err := session.GetUserId()
+	if err != nil {
+		return err
+	}
+
+	err = models.ValidateData(data, h.logger)
+	if err != nil {
+		return err
+	}
+
+	return h.WithAuthorize(ctx, session, shopId, ROLE_USER_MANAGE_TABS, func(pq *db.PgxQueries) error {
+		tab, err := pq.GetTabById(ctx, shopId, tabId)
+		if err != nil {
+			return err
+		}
+		if tab.PendingUpdates == nil {
+			return services.NewDataConflictServiceError(errors.New("Tab has no pending updates"))
+		}
+
+		err = pq.RejectTabUpdates(ctx, shopId, tabId, userId, data.Message)
+		if err != nil {
+			return err
+		}
+
+		_, emailData, err := h.tabEmailData(ctx, pq, &tab.TabOverview)
+		if err != nil {
+			return err
+		}
+		return h.emailUser(ctx, pq, tab.OwnerId, email.TEMPLATE_TAB_UPDATE_REJECTED, email.TabUpdateRejectedData{TabData: emailData, Message: data.Message})
+	})
+}
+
+func (h *Handler) GetTabUpdateDiff(ctx context.Context, session *sessions.Session, shopId int, tabId int) ([]models.TabFieldDiff, error) {
+	return db.WithTxRet(ctx, h.store, func(pq *db.PgxQueries) ([]models.TabFieldDiff, error) {
+		tab, err := pq.GetTabById(ctx, shopId, tabId)
+		if err != nil {
+			return nil, err
+		}
+
+		err = h.authorizeTabManager(ctx, session, &tab.TabOverview, ROLE_USER_READ_TABS, pq)
+		if err != nil {
+			return nil, err
+		}
+
+		if tab.PendingUpdates == nil {
+			return nil, services.NewNotFoundServiceError(errors.New("Tab has no pending updates"))
+		}
+
+		return tab.PendingUpdateDiff(), nil
+	})
+}
+
 func (h *Handler) CloseTab(ctx context.Context, session *sessions.Session, shopId int, tabId int) error {
+	userId, err := session.GetUserId()
+	if err != nil {
+		return err
+	}
+
 	return h.WithAuthorize(ctx, session, shopId, ROLE_USER_MANAGE_TABS, func(pq *db.PgxQueries) error {
 		tab, err := pq.GetTabById(ctx, shopId, tabId)
 		if err != nil {
@@ -124,39 +336,53 @@ func (h *Handler) CloseTab(ctx context.Context, session *sessions.Session, shopI
 			return services.NewDataConflictServiceError(nil)
 		}
 
-		err = pq.CloseTab(ctx, shopId, tabId)
+		err = pq.CloseTab(ctx, shopId, tabId, h.clock.Now())
 		if err != nil {
 			return err
 		}
 
-		return nil
+		return pq.AddTabEvent(ctx, shopId, tabId, models.TAB_COMMENT_KIND_CLOSED, &userId, nil)
 	})
 }
 
-func (h *Handler) MarkTabBillPaid(ctx context.Context, session *sessions.Session, shopId int, tabId int, billId int) error {
-	return h.WithAuthorize(ctx, session, shopId, ROLE_USER_MANAGE_ORDERS, func(pq *db.PgxQueries) error {
-		return pq.MarkTabBillPaid(ctx, shopId, tabId, billId)
-	})
-}
+func (h *Handler) GetTabs(ctx context.Context, session *sessions.Session, shopId int, params *models.GetTabsQueryParams) ([]models.TabOverview, *models.TabsCursor, error) {
+	err := models.ValidateData(params, h.logger)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// A cursor only marks a position in the order it was created for
+	if params.Cursor != nil && (params.Cursor.Sort != params.Sort || params.Cursor.Descending != params.Descending) {
+		return nil, nil, services.NewValidationServiceError(errors.New("Cursor does not match sort"), services.ValidationErrors{
+			"cursor": services.ValidationError{Value: params.Cursor.Sort, Error: "sort"},
+		})
+	}
 
-func (h *Handler) GetTabs(ctx context.Context, session *sessions.Session, shopId int) ([]models.TabOverview, error) {
 	var tabs []models.TabOverview = nil
-	err := h.WithAuthorize(ctx, session, shopId, ROLE_USER_READ_TABS, func(pq *db.PgxQueries) error {
+	var next *models.TabsCursor = nil
+	err = h.WithAuthorize(ctx, session, shopId, ROLE_USER_READ_TABS, func(pq *db.PgxQueries) error {
 		var err error
-		tabs, err = pq.GetTabs(ctx, shopId)
+		tabs, next, err = pq.GetTabs(ctx, shopId, params)
 		return err
 	})
-	return tabs, err
+	return tabs, next, err
 }
 
 func (h *Handler) GetTabById(ctx context.Context, session *sessions.Session, shopId int, tabId int) (models.Tab, error) {
-	var tab models.Tab
-	err := h.WithAuthorize(ctx, session, shopId, ROLE_USER_READ_TABS, func(pq *db.PgxQueries) error {
-		var err error
-		tab, err = pq.GetTabById(ctx, shopId, tabId)
-		return err
+	return db.WithTxRet(ctx, h.store, func(pq *db.PgxQueries) (models.Tab, error) {
+		tab, err := pq.GetTabById(ctx, shopId, tabId)
+		if err != nil {
+			return models.Tab{}, err
+		}
+
+		// The owner and managers of a tab may always view it and its bills
+		err = h.authorizeTabManager(ctx, session, &tab.TabOverview, ROLE_USER_READ_TABS, pq)
+		if err != nil {
+			return models.Tab{}, err
+		}
+
+		return tab, nil
 	})
-	return tab, err
 }
 
 func (h *Handler) AddOrderToTab(ctx context.Context, session *sessions.Session, shopId int, tabId int, data *models.BillOrderCreate) error {
@@ -171,40 +397,313 @@ func (h *Handler) AddOrderToTab(ctx context.Context, session *sessions.Session,
 			return err
 		}
 
-		if !tab.IsActive() {
-			return services.NewDataConflictServiceError(nil)
+		err = h.checkTabActive(ctx, pq, &tab.TabOverview, data.LocationId)
+		if err != nil {
+			return err
 		}
 
-		err = pq.AddOrderToTab(ctx, shopId, tabId, data)
+		items, err := pq.PriceOrder(ctx, shopId, data)
 		if err != nil {
 			return err
 		}
 
-		return nil
-	})
-}
+		adjustments, err := h.priceTabOrder(ctx, pq, &tab.TabOverview, items)
+		if err != nil {
+			return err
+		}
 
-func (h *Handler) RemoveOrderFromTab(ctx context.Context, session *sessions.Session, shopId int, tabId int, data *models.BillOrderCreate) error {
-	return h.WithAuthorize(ctx, session, shopId, ROLE_USER_MANAGE_ORDERS, func(pq *db.PgxQueries) error {
-		err := models.ValidateData(data, h.logger)
+		tip, err := orderTip(&tab.TabOverview, data, items, adjustments)
 		if err != nil {
 			return err
 		}
+		if tip != nil {
+			adjustments = append(adjustments, *tip)
+		}
 
-		tab, err := pq.GetTabById(ctx, shopId, tabId)
+		customer, err := verifyCustomer(&tab.TabOverview, data)
 		if err != nil {
 			return err
 		}
 
-		if !tab.IsActive() {
-			return services.NewDataConflictServiceError(nil)
+		override, err := h.checkOrderLimit(ctx, session, pq, &tab.TabOverview, data, items, adjustments)
+		if err != nil {
+			return err
+		}
+
+		spent, err := h.checkTabBudget(ctx, pq, &tab.TabOverview, items, adjustments)
+		if err != nil {
+			return err
+		}
+
+		var voucher *models.Voucher = nil
+		if tab.VerificationMethod == string(models.VerificationMethodVoucher) {
+			voucher, err = h.checkVoucher(ctx, pq, &tab.TabOverview, data.VoucherCode)
+			if err != nil {
+				return err
+			}
 		}
 
-		err = pq.RemoveOrderFromTab(ctx, shopId, tabId, data)
+		userId, err := session.GetUserId()
 		if err != nil {
 			return err
 		}
 
+		order, err := pq.AddOrderToTab(ctx, shopId, tabId, userId, data.LocationId, customer, items, adjustments, h.clock.Now())
+		if err != nil {
+			return err
+		}
+
+		if override != nil {
+			err = pq.RecordOrderLimitOverride(ctx, shopId, tabId, order.Id, override)
+			if err != nil {
+				return err
+			}
+		}
+
+		if voucher != nil {
+			err = pq.RedeemVoucher(ctx, shopId, tabId, voucher.Id, &order, userId)
+			if err != nil {
+				return err
+			}
+		}
+
+		return h.warnTabBudget(ctx, pq, &tab.TabOverview, spent, spent+models.OrderTotal(items, adjustments))
+	})
+}
+
+// VoidOrder reverses a prior order on the bill it was charged to
+func (h *Handler) VoidOrder(ctx context.Context, session *sessions.Session, shopId int, tabId int, orderId int, data *models.OrderVoid) (models.OrderOverview, error) {
+	err := models.ValidateData(data, h.logger)
+	if err != nil {
+		return models.OrderOverview{}, err
+	}
+
+	return db.WithTxRet(ctx, h.store, func(pq *db.PgxQueries) (models.OrderOverview, error) {
+		err := h.Authorize(ctx, session, shopId, ROLE_USER_MANAGE_ORDERS, pq)
+		if err != nil {
+			return models.OrderOverview{}, err
+		}
+
+		order, err := pq.GetOrderForVoid(ctx, shopId, tabId, orderId)
+		if err != nil {
+			return models.OrderOverview{}, err
+		}
+
+		if order.VoidsOrderId != nil {
+			return models.OrderOverview{}, services.NewDataConflictServiceError(errors.New("Cannot void an order which voids another order"))
+		}
+		if order.IsVoided {
+			return models.OrderOverview{}, services.NewDataConflictServiceError(errors.New("Order has already been voided"))
+		}
+
+		userId, err := session.GetUserId()
+		if err != nil {
+			return models.OrderOverview{}, err
+		}
+
+		h.logger.Info("Voiding order", "shopId", shopId, "tabId", tabId, "orderId", orderId, "userId", userId)
+		void, err := pq.VoidOrder(ctx, shopId, tabId, userId, &order, data.Reason)
+		if err != nil {
+			return models.OrderOverview{}, err
+		}
+
+		// A voided order no longer uses up the voucher it redeemed
+		err = pq.ReleaseVoucherRedemption(ctx, shopId, tabId, orderId)
+		if err != nil {
+			return models.OrderOverview{}, err
+		}
+		return void, nil
+	})
+}
+
+// authorizeTabOwner permits the owner of the tab, or otherwise users with the given roles in the tab's shop
+func (h *Handler) authorizeTabOwner(ctx context.Context, session *sessions.Session, tab *models.TabOverview, roles uint32, pq *db.PgxQueries) error {
+	userId, err := session.GetUserId()
+	if err != nil {
+		return err
+	}
+
+	if userId == tab.OwnerId {
 		return nil
+	}
+
+	return h.Authorize(ctx, session, tab.ShopId, roles, pq)
+}
+
+// authorizeTabManager permits the owner and managers of the tab, or otherwise users with the given roles in the tab's shop
+func (h *Handler) authorizeTabManager(ctx context.Context, session *sessions.Session, tab *models.TabOverview, roles uint32, pq *db.PgxQueries) error {
+	userId, err := session.GetUserId()
+	if err != nil {
+		return err
+	}
+
+	if userId == tab.OwnerId {
+		return nil
+	}
+
+	if len(tab.Managers) > 0 {
+		user, err := pq.GetUser(ctx, userId)
+		if err != nil {
+			return err
+		}
+		if tab.IsManager(user.Email) {
+			return nil
+		}
+	}
+
+	return h.Authorize(ctx, session, tab.ShopId, roles, pq)
+}
+
+// sameTabLocations reports whether the location ids are exactly the tab's current locations, in any order
+func sameTabLocations(tab *models.TabOverview, locationIds []int) bool {
+	current := make([]int, 0, len(tab.Locations))
+	for _, location := range tab.Locations {
+		current = append(current, int(location.Id))
+	}
+	requested := slices.Clone(locationIds)
+	slices.Sort(current)
+	slices.Sort(requested)
+	return slices.Equal(current, requested)
+}
+
+// checkOrderLimit rejects orders exceeding the tab's dollar limit per order, unless the limit
+// is overridden with a reason by a user permitted to manage tabs. It returns the override to be
+// recorded against the order, if any.
+func (h *Handler) checkOrderLimit(ctx context.Context, session *sessions.Session, pq *db.PgxQueries, tab *models.TabOverview, data *models.BillOrderCreate, items []models.ItemOrder, adjustments []models.OrderAdjustment) (*models.OrderLimitOverride, error) {
+	// A limit of zero means that orders are not limited
+	if tab.DollarLimitPerOrder == 0 {
+		return nil, nil
+	}
+
+	// Taxes and service fees are set by the shop, and tips are capped separately, so neither count towards the tab's limit
+	total := models.DiscountedTotal(items, adjustments)
+	if total <= tab.DollarLimitPerOrder {
+		return nil, nil
+	}
+
+	if data.LimitOverrideReason == nil {
+		return nil, services.NewValidationServiceError(errors.New("Order exceeds tab dollar limit per order"), services.ValidationErrors{
+			"items": services.ValidationError{
+				Value: models.OrderLimitExceeded{DollarLimitPerOrder: tab.DollarLimitPerOrder, Total: total},
+				Error: "dollar_limit_per_order",
+			},
+		})
+	}
+
+	err := h.Authorize(ctx, session, tab.ShopId, ROLE_USER_MANAGE_TABS, pq)
+	if err != nil {
+		return nil, err
+	}
+
+	userId, err := session.GetUserId()
+	if err != nil {
+		return nil, err
+	}
+
+	h.logger.Info("Overriding tab dollar limit per order", "shopId", tab.ShopId, "tabId", tab.Id, "userId", userId, "total", total)
+	return &models.OrderLimitOverride{
+		UserId:      userId,
+		Reason:      *data.LimitOverrideReason,
+		DollarLimit: tab.DollarLimitPerOrder,
+		Total:       total,
+	}, nil
+}
+
+// checkTabBudget rejects orders which would take the tab's total spend over its budget,
+// returning the spend on the tab before the order.
+func (h *Handler) checkTabBudget(ctx context.Context, pq *db.PgxQueries, tab *models.TabOverview, items []models.ItemOrder, adjustments []models.OrderAdjustment) (float32, error) {
+	// A budget of zero means that the tab's spend is not limited
+	if tab.TotalBudget == 0 {
+		return 0, nil
+	}
+
+	spent, err := pq.GetTabSpendForUpdate(ctx, tab.ShopId, tab.Id)
+	if err != nil {
+		return 0, err
+	}
+
+	total := models.OrderTotal(items, adjustments)
+	if tab.ExceedsBudget(spent, total) {
+		return 0, services.NewValidationServiceError(errors.New("Order exceeds tab total budget"), services.ValidationErrors{
+			"items": services.ValidationError{
+				Value: models.BudgetExceeded{TotalBudget: tab.TotalBudget, Spent: spent, Total: total},
+				Error: "total_budget",
+			},
+		})
+	}
+
+	return spent, nil
+}
+
+// warnTabBudget emails the tab owner about the highest budget warning threshold reached by an order
+func (h *Handler) warnTabBudget(ctx context.Context, pq *db.PgxQueries, tab *models.TabOverview, before float32, after float32) error {
+	threshold, ok := tab.HighestBudgetWarning(before, after)
+	if !ok {
+		return nil
+	}
+
+	_, data, err := h.tabEmailData(ctx, pq, tab)
+	if err != nil {
+		return err
+	}
+
+	return h.emailUser(ctx, pq, tab.OwnerId, email.TEMPLATE_BUDGET_WARNING, email.BudgetWarningData{
+		TabData:     data,
+		Threshold:   threshold,
+		TotalBudget: tab.TotalBudget,
+		Spent:       after,
 	})
 }
+
+// checkTabActive ensures the tab can be charged at the location at the current time in the shop's time zone
+func (h *Handler) checkTabActive(ctx context.Context, pq *db.PgxQueries, tab *models.TabOverview, locationId int) error {
+	if !tab.HasLocation(locationId) {
+		err := &models.TabInactiveError{Reason: "location", Message: "Tab cannot be charged at this location"}
+		return services.NewServiceError(err, http.StatusConflict, err)
+	}
+
+	shop, err := pq.GetShopById(ctx, tab.ShopId)
+	if err != nil {
+		return err
+	}
+
+	loc, err := shop.Location()
+	if err != nil {
+		h.logger.Error("Invalid shop time zone", "shopId", tab.ShopId, "timeZone", shop.TimeZone)
+		return services.NewInternalServiceError(err)
+	}
+
+	err = tab.CheckActive(h.clock.Now().In(loc))
+	if err != nil {
+		return services.NewServiceError(err, http.StatusConflict, err)
+	}
+
+	return nil
+}
+
+// verifyCustomer resolves who an order is placed for according to the tab's verification method.
+// The customer is optional, but an email which is given must appear in the tab's verification list
+// and is recorded as listed there. Orders without a customer are recorded with an empty one.
+func verifyCustomer(tab *models.TabOverview, data *models.BillOrderCreate) (string, error) {
+	switch models.VerificationMethod(tab.VerificationMethod) {
+	case models.VerificationMethodEmail:
+		if data.CustomerEmail == nil {
+			return "", nil
+		}
+		for _, email := range tab.VerificationList {
+			if strings.EqualFold(email, *data.CustomerEmail) {
+				return email, nil
+			}
+		}
+		return "", services.NewValidationServiceError(errors.New("Customer is not on the tab's verification list"), services.ValidationErrors{
+			"customer_email": services.ValidationError{Value: *data.CustomerEmail, Error: "unverified"},
+		})
+	case models.VerificationMethodSpecify:
+		if data.CustomerName == nil {
+			return "", nil
+		}
+		return strings.TrimSpace(*data.CustomerName), nil
+	default:
+		return "", nil
+	}
+}
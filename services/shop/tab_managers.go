@@ -0,0 +1,166 @@
+package shop
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/WilliamTrojniak/TabAppBackend/db"
+	"github.com/WilliamTrojniak/TabAppBackend/models"
+	"github.com/WilliamTrojniak/TabAppBackend/services"
+	"github.com/WilliamTrojniak/TabAppBackend/services/email"
+	"github.com/WilliamTrojniak/TabAppBackend/services/sessions"
+)
+
+// AddTabManager allows the user with the email to manage the tab's verification list and view its bills
+func (h *Handler) AddTabManager(ctx context.Context, session *sessions.Session, shopId int, tabId int, data *models.TabManagerCreate) error {
+	userId, err := session.GetUserId()
+	if err != nil {
+		return err
+	}
+
+	err = models.ValidateData(data, h.logger)
+	if err != nil {
+		return err
+	}
+
+	return db.WithTx(ctx, h.store, func(pq *db.PgxQueries) error {
+		tab, err := pq.GetTabById(ctx, shopId, tabId)
+		if err != nil {
+			return err
+		}
+
+		err = h.authorizeTabOwner(ctx, session, &tab.TabOverview, ROLE_USER_MANAGE_TABS, pq)
+		if err != nil {
+			return err
+		}
+
+		owner, err := pq.GetUser(ctx, tab.OwnerId)
+		if err != nil {
+			return err
+		}
+		if strings.EqualFold(owner.Email, data.Email) {
+			return services.NewDataConflictServiceError(errors.New("The tab owner cannot also be a manager"))
+		}
+
+		return pq.AddTabManager(ctx, shopId, tabId, data.Email, userId)
+	})
+}
+
+// RemoveTabManager removes a manager from the tab. Managers may remove themselves.
+func (h *Handler) RemoveTabManager(ctx context.Context, session *sessions.Session, shopId int, tabId int, data *models.TabManagerCreate) error {
+	err := models.ValidateData(data, h.logger)
+	if err != nil {
+		return err
+	}
+
+	return db.WithTx(ctx, h.store, func(pq *db.PgxQueries) error {
+		tab, err := pq.GetTabById(ctx, shopId, tabId)
+		if err != nil {
+			return err
+		}
+
+		authErr := h.authorizeTabOwner(ctx, session, &tab.TabOverview, ROLE_USER_MANAGE_TABS, pq)
+		if authErr != nil {
+			user, err := h.users.GetUser(ctx, session)
+			if err != nil {
+				return err
+			}
+			if !strings.EqualFold(user.Email, data.Email) {
+				return authErr
+			}
+		}
+
+		return pq.RemoveTabManager(ctx, shopId, tabId, data.Email)
+	})
+}
+
+// RequestTabOwnershipTransfer asks the user with the email to take over the tab. The tab keeps its
+// current owner until the transfer is accepted.
+func (h *Handler) RequestTabOwnershipTransfer(ctx context.Context, session *sessions.Session, shopId int, tabId int, data *models.TabOwnershipTransferCreate) error {
+	userId, err := session.GetUserId()
+	if err != nil {
+		return err
+	}
+
+	err = models.ValidateData(data, h.logger)
+	if err != nil {
+		return err
+	}
+
+	return db.WithTx(ctx, h.store, func(pq *db.PgxQueries) error {
+		tab, err := pq.GetTabById(ctx, shopId, tabId)
+		if err != nil {
+			return err
+		}
+
+		err = h.authorizeTabOwner(ctx, session, &tab.TabOverview, ROLE_USER_MANAGE_TABS, pq)
+		if err != nil {
+			return err
+		}
+
+		owner, err := pq.GetUser(ctx, tab.OwnerId)
+		if err != nil {
+			return err
+		}
+		if strings.EqualFold(owner.Email, data.Email) {
+			return services.NewDataConflictServiceError(errors.New("The tab is already owned by this user"))
+		}
+
+		err = pq.RequestTabOwnershipTransfer(ctx, shopId, tabId, data.Email, userId)
+		if err != nil {
+			return err
+		}
+
+		_, emailData, err := h.tabEmailData(ctx, pq, &tab.TabOverview)
+		if err != nil {
+			return err
+		}
+		return h.emails.Enqueue(ctx, pq, email.TEMPLATE_TAB_TRANSFER, data.Email, emailData)
+	})
+}
+
+// AcceptTabOwnershipTransfer makes the session user the owner of the tab, if the tab's pending transfer was
+// requested for their email
+func (h *Handler) AcceptTabOwnershipTransfer(ctx context.Context, session *sessions.Session, shopId int, tabId int) error {
+	userId, err := session.GetUserId()
+	if err != nil {
+		return err
+	}
+
+	return db.WithTx(ctx, h.store, func(pq *db.PgxQueries) error {
+		user, err := pq.GetUser(ctx, userId)
+		if err != nil {
+			return err
+		}
+
+		h.logger.Info("Accepting tab ownership transfer", "shopId", shopId, "tabId", tabId, "userId", userId)
+		return pq.AcceptTabOwnershipTransfer(ctx, shopId, tabId, userId, user.Email)
+	})
+}
+
+// CancelTabOwnershipTransfer withdraws the tab's pending transfer. The recipient may also decline it.
+func (h *Handler) CancelTabOwnershipTransfer(ctx context.Context, session *sessions.Session, shopId int, tabId int) error {
+	return db.WithTx(ctx, h.store, func(pq *db.PgxQueries) error {
+		tab, err := pq.GetTabById(ctx, shopId, tabId)
+		if err != nil {
+			return err
+		}
+		if tab.PendingOwnershipTransfer == nil {
+			return services.NewNotFoundServiceError(errors.New("Tab has no pending ownership transfer"))
+		}
+
+		authErr := h.authorizeTabOwner(ctx, session, &tab.TabOverview, ROLE_USER_MANAGE_TABS, pq)
+		if authErr != nil {
+			user, err := h.users.GetUser(ctx, session)
+			if err != nil {
+				return err
+			}
+			if !strings.EqualFold(user.Email, tab.PendingOwnershipTransfer.Email) {
+				return authErr
+			}
+		}
+
+		return pq.CancelTabOwnershipTransfer(ctx, shopId, tabId)
+	})
+}
@@ -0,0 +1,87 @@
+package shop
+
+import (
+	"context"
+	"testing"
+
+	"github.com/WilliamTrojniak/TabAppBackend/db"
+	"github.com/WilliamTrojniak/TabAppBackend/models"
+)
+
+func TestVerifyCustomer(t *testing.T) {
+	str := func(s string) *string { return &s }
+
+	tests := []struct {
+		name     string
+		method   models.VerificationMethod
+		data     models.BillOrderCreate
+		expected string
+		invalid  bool
+	}{
+		{"email without customer", models.VerificationMethodEmail, models.BillOrderCreate{}, "", false},
+		{"listed email", models.VerificationMethodEmail, models.BillOrderCreate{CustomerEmail: str("Alex@Example.com")}, "alex@example.com", false},
+		{"unlisted email", models.VerificationMethodEmail, models.BillOrderCreate{CustomerEmail: str("sam@example.com")}, "", true},
+		{"specify without customer", models.VerificationMethodSpecify, models.BillOrderCreate{}, "", false},
+		{"specified name", models.VerificationMethodSpecify, models.BillOrderCreate{CustomerName: str("  Alex  ")}, "Alex", false},
+		{"voucher ignores customer", models.VerificationMethodVoucher, models.BillOrderCreate{CustomerName: str("Alex")}, "", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tab := &models.TabOverview{VerificationList: []string{"alex@example.com"}}
+			tab.VerificationMethod = string(test.method)
+
+			customer, err := verifyCustomer(tab, &test.data)
+			if (err != nil) != test.invalid {
+				t.Fatalf("expected invalid to be %v, got error %v", test.invalid, err)
+			}
+			if customer != test.expected {
+				t.Errorf("expected customer %q, got %q", test.expected, customer)
+			}
+		})
+	}
+}
+
+// Owners of confirmed tabs request updates, which are held until the shop approves them
+func TestUpdateConfirmedTabAsOwner(t *testing.T) {
+	h := testHandler(t)
+	ctx := context.Background()
+	shopId, tabId, ownerId := createTestTab(t, h, models.TAB_STATUS_CONFIRMED)
+
+	getTab := func() models.Tab {
+		tab, err := db.WithTxRet(ctx, h.store, func(pq *db.PgxQueries) (models.Tab, error) {
+			return pq.GetTabById(ctx, shopId, tabId)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tab
+	}
+
+	tab := getTab()
+	data := models.TabUpdate{TabBase: tab.TabBase, VerificationList: []string{}, LocationIds: []int{}}
+	data.DisplayName = "Renamed Tab"
+
+	err := h.UpdateTab(ctx, testSession(t, h, ownerId), shopId, tabId, &data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tab = getTab()
+	if tab.Status != models.TAB_STATUS_CONFIRMED.String() || tab.DisplayName != "Test Tab" {
+		t.Errorf("expected the confirmed tab to be unchanged, got %v named %q", tab.Status, tab.DisplayName)
+	}
+	if tab.PendingUpdates == nil || tab.PendingUpdates.DisplayName != "Renamed Tab" {
+		t.Errorf("expected the update to be pending, got %+v", tab.PendingUpdates)
+	}
+
+	comments, err := db.WithTxRet(ctx, h.store, func(pq *db.PgxQueries) ([]models.TabComment, error) {
+		return pq.GetTabComments(ctx, shopId, tabId)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comments) == 0 || comments[len(comments)-1].Kind != models.TAB_COMMENT_KIND_UPDATE_REQUESTED.String() {
+		t.Errorf("expected the update request to be recorded, got %+v", comments)
+	}
+}
@@ -0,0 +1,73 @@
+package shop
+
+import (
+	"context"
+	"errors"
+
+	"github.com/WilliamTrojniak/TabAppBackend/db"
+	"github.com/WilliamTrojniak/TabAppBackend/models"
+	"github.com/WilliamTrojniak/TabAppBackend/services"
+	"github.com/WilliamTrojniak/TabAppBackend/services/sessions"
+)
+
+// GetTipsReport totals the tips left on tab orders by member of staff and location
+func (h *Handler) GetTipsReport(ctx context.Context, session *sessions.Session, shopId int, params *models.TipsReportQueryParams) ([]models.TipsReportEntry, error) {
+	if params.From != nil && params.To != nil && params.To.Before(params.From.Date) {
+		return nil, services.NewValidationServiceError(errors.New("Report must end after it starts"), services.ValidationErrors{
+			"to": services.ValidationError{Value: params.To, Error: "endafterstart"},
+		})
+	}
+
+	var entries []models.TipsReportEntry = nil
+	err := h.WithAuthorize(ctx, session, shopId, ROLE_USER_MANAGE_ORDERS, func(pq *db.PgxQueries) error {
+		var err error
+		entries, err = pq.GetTipsReport(ctx, shopId, params)
+		return err
+	})
+	return entries, err
+}
+
+// orderTip returns the tip adjustment requested with an order, if any. Tips must be allowed by the tab
+// and are always capped by its maximum tip percentage of the discounted order, before taxes and fees.
+func orderTip(tab *models.TabOverview, data *models.BillOrderCreate, items []models.ItemOrder, adjustments []models.OrderAdjustment) (*models.OrderAdjustment, error) {
+	if data.TipAmount == nil && data.TipPercent == nil {
+		return nil, nil
+	}
+
+	key := "tip_amount"
+	var value any = data.TipAmount
+	if data.TipPercent != nil {
+		key = "tip_percent"
+		value = data.TipPercent
+	}
+
+	if !tab.AllowTips {
+		return nil, services.NewValidationServiceError(errors.New("Tab does not allow tips"), services.ValidationErrors{
+			key: services.ValidationError{Value: value, Error: "allow_tips"},
+		})
+	}
+
+	base := models.DiscountedTotal(items, adjustments)
+	var tip models.OrderAdjustment
+	if data.TipPercent != nil {
+		tip = models.Tip(base * *data.TipPercent / 100)
+	} else {
+		tip = models.Tip(*data.TipAmount)
+	}
+
+	maxTip := models.Tip(base * tab.MaxTipPercent / 100).Amount
+	if tip.Amount > maxTip {
+		return nil, services.NewValidationServiceError(errors.New("Tip exceeds tab maximum tip"), services.ValidationErrors{
+			key: services.ValidationError{
+				Value: models.TipExceeded{MaxTipPercent: tab.MaxTipPercent, Max: maxTip, Tip: tip.Amount},
+				Error: "max_tip_percent",
+			},
+		})
+	}
+
+	// A percentage of an order with nothing to charge leaves nothing to tip
+	if tip.Amount == 0 {
+		return nil, nil
+	}
+	return &tip, nil
+}
@@ -0,0 +1,88 @@
+package shop
+
+import (
+	"testing"
+
+	"github.com/WilliamTrojniak/TabAppBackend/models"
+)
+
+func TestOrderTip(t *testing.T) {
+	amount := func(a float32) *float32 { return &a }
+	coffee := models.ItemOrder{Quantity: 4}
+	coffee.Id = 1
+	coffee.Name = "Coffee"
+	coffee.BasePrice = amount(2.5)
+	items := []models.ItemOrder{coffee}
+	itemId := 1
+	discount := models.OrderAdjustment{Kind: models.ORDER_ADJUSTMENT_KIND_DISCOUNT.String(), ItemId: &itemId, Amount: -5}
+	tax := models.OrderAdjustment{Kind: models.ORDER_ADJUSTMENT_KIND_TAX.String(), Amount: 1}
+
+	tests := []struct {
+		name        string
+		allowTips   bool
+		data        models.BillOrderCreate
+		adjustments []models.OrderAdjustment
+		// expected is the tip charged, or a negative amount if the tip is rejected
+		expected float32
+		field    string
+	}{
+		{"no tip", false, models.BillOrderCreate{}, nil, 0, ""},
+		{"tips not allowed", false, models.BillOrderCreate{TipAmount: amount(1)}, nil, -1, "tip_amount"},
+		{"fixed tip", true, models.BillOrderCreate{TipAmount: amount(1.5)}, nil, 1.5, ""},
+		{"fixed tip at the cap", true, models.BillOrderCreate{TipAmount: amount(2)}, nil, 2, ""},
+		{"fixed tip above the cap", true, models.BillOrderCreate{TipAmount: amount(2.01)}, nil, -1, "tip_amount"},
+		{"fixed tip rounded to the cent", true, models.BillOrderCreate{TipAmount: amount(1.234)}, nil, 1.23, ""},
+		{"percent tip", true, models.BillOrderCreate{TipPercent: amount(15)}, nil, 1.5, ""},
+		{"percent tip at the cap", true, models.BillOrderCreate{TipPercent: amount(20)}, nil, 2, ""},
+		{"percent tip above the cap", true, models.BillOrderCreate{TipPercent: amount(25)}, nil, -1, "tip_percent"},
+		{"percent tip not allowed", false, models.BillOrderCreate{TipPercent: amount(10)}, nil, -1, "tip_percent"},
+		// The discount halves the order, so the cap is halved too
+		{"cap after discounts", true, models.BillOrderCreate{TipAmount: amount(1.5)}, []models.OrderAdjustment{discount}, -1, "tip_amount"},
+		{"percent after discounts", true, models.BillOrderCreate{TipPercent: amount(10)}, []models.OrderAdjustment{discount}, 0.5, ""},
+		// Taxes are not tipped on, so they do not raise the cap
+		{"cap before taxes", true, models.BillOrderCreate{TipAmount: amount(2.2)}, []models.OrderAdjustment{tax}, -1, "tip_amount"},
+		{"percent before taxes", true, models.BillOrderCreate{TipPercent: amount(10)}, []models.OrderAdjustment{tax}, 1, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tab := &models.TabOverview{}
+			tab.AllowTips = test.allowTips
+			tab.MaxTipPercent = 20
+
+			tip, err := orderTip(tab, &test.data, items, test.adjustments)
+			if test.expected < 0 {
+				fields := invalidFields(t, err)
+				if len(fields) != 1 || fields[0] != test.field {
+					t.Errorf("expected %v to be invalid, got %v", test.field, fields)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if test.expected == 0 {
+				if tip != nil {
+					t.Errorf("expected no tip, got %v", tip.Amount)
+				}
+				return
+			}
+			if tip == nil || tip.Amount != test.expected || tip.Kind != models.ORDER_ADJUSTMENT_KIND_TIP.String() {
+				t.Errorf("expected a tip of %v, got %+v", test.expected, tip)
+			}
+		})
+	}
+}
+
+func TestOrderTipOnEmptyOrder(t *testing.T) {
+	tab := &models.TabOverview{}
+	tab.AllowTips = true
+	tab.MaxTipPercent = 20
+	percent := float32(15)
+
+	tip, err := orderTip(tab, &models.BillOrderCreate{TipPercent: &percent}, nil, nil)
+	if err != nil || tip != nil {
+		t.Errorf("expected no tip on an order with nothing to charge, got %+v and %v", tip, err)
+	}
+}
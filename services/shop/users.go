@@ -6,6 +6,7 @@ import (
 	"github.com/WilliamTrojniak/TabAppBackend/db"
 	"github.com/WilliamTrojniak/TabAppBackend/models"
 	"github.com/WilliamTrojniak/TabAppBackend/services"
+	"github.com/WilliamTrojniak/TabAppBackend/services/email"
 	"github.com/WilliamTrojniak/TabAppBackend/services/sessions"
 )
 
@@ -82,7 +83,12 @@ func (h *Handler) InviteUserToShop(ctx context.Context, session *sessions.Sessio
 			return services.NewDataConflictServiceError(nil)
 		}
 
-		return pq.AddUserToShop(ctx, shopId, userData)
+		err = pq.AddUserToShop(ctx, shopId, userData)
+		if err != nil {
+			return err
+		}
+
+		return h.emails.Enqueue(ctx, pq, email.TEMPLATE_SHOP_INVITE, userData.Email, email.ShopInviteData{ShopId: shopId, ShopName: shop.Name})
 	})
 }
 
@@ -0,0 +1,110 @@
+package shop
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/WilliamTrojniak/TabAppBackend/db"
+	"github.com/WilliamTrojniak/TabAppBackend/models"
+	"github.com/WilliamTrojniak/TabAppBackend/services"
+	"github.com/WilliamTrojniak/TabAppBackend/services/sessions"
+	"github.com/WilliamTrojniak/TabAppBackend/util"
+)
+
+const voucherCodeLength = 10
+
+func (h *Handler) CreateVouchers(ctx context.Context, session *sessions.Session, shopId int, tabId int, data *models.VoucherBatchCreate) ([]models.Voucher, error) {
+	userId, err := session.GetUserId()
+	if err != nil {
+		return nil, err
+	}
+
+	err = models.ValidateData(data, h.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.WithTxRet(ctx, h.store, func(pq *db.PgxQueries) ([]models.Voucher, error) {
+		tab, err := pq.GetTabById(ctx, shopId, tabId)
+		if err != nil {
+			return nil, err
+		}
+
+		err = h.authorizeTabOwner(ctx, session, &tab.TabOverview, ROLE_USER_MANAGE_TABS, pq)
+		if err != nil {
+			return nil, err
+		}
+
+		if tab.VerificationMethod != string(models.VerificationMethodVoucher) {
+			return nil, services.NewDataConflictServiceError(errors.New("Tab does not use voucher verification"))
+		}
+
+		codes := make([]string, 0, data.Count)
+		for range data.Count {
+			code, err := util.RandCode(voucherCodeLength)
+			if err != nil {
+				return nil, services.NewInternalServiceError(err)
+			}
+			codes = append(codes, code)
+		}
+
+		h.logger.Debug("Creating vouchers", "shopId", shopId, "tabId", tabId, "count", data.Count)
+		return pq.CreateVouchers(ctx, shopId, tabId, userId, data, codes)
+	})
+}
+
+func (h *Handler) GetVouchers(ctx context.Context, session *sessions.Session, shopId int, tabId int) ([]models.Voucher, error) {
+	return db.WithTxRet(ctx, h.store, func(pq *db.PgxQueries) ([]models.Voucher, error) {
+		tab, err := pq.GetTabById(ctx, shopId, tabId)
+		if err != nil {
+			return nil, err
+		}
+
+		err = h.authorizeTabManager(ctx, session, &tab.TabOverview, ROLE_USER_READ_TABS, pq)
+		if err != nil {
+			return nil, err
+		}
+
+		return pq.GetVouchers(ctx, shopId, tabId)
+	})
+}
+
+// checkVoucher ensures the code belongs to a voucher of the tab which is unexpired and has uses remaining
+func (h *Handler) checkVoucher(ctx context.Context, pq *db.PgxQueries, tab *models.TabOverview, code *string) (*models.Voucher, error) {
+	if code == nil {
+		return nil, services.NewValidationServiceError(errors.New("Missing voucher code"), services.ValidationErrors{
+			"voucher_code": services.ValidationError{Value: nil, Error: "required"},
+		})
+	}
+
+	voucher, err := pq.GetVoucherForRedemption(ctx, tab.ShopId, tab.Id, *code)
+	if err != nil {
+		var serviceErr *services.ServiceError
+		if errors.As(err, &serviceErr) && serviceErr.StatusCode() == http.StatusNotFound {
+			return nil, services.NewValidationServiceError(err, services.ValidationErrors{
+				"voucher_code": services.ValidationError{Value: *code, Error: "invalid"},
+			})
+		}
+		return nil, err
+	}
+
+	today, err := pq.GetShopDate(ctx, tab.ShopId, h.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	if voucher.IsExpired(today) {
+		return nil, services.NewValidationServiceError(errors.New("Voucher expired"), services.ValidationErrors{
+			"voucher_code": services.ValidationError{Value: *code, Error: "expired"},
+		})
+	}
+
+	if voucher.IsRedeemed() {
+		return nil, services.NewValidationServiceError(errors.New("Voucher already redeemed"), services.ValidationErrors{
+			"voucher_code": services.ValidationError{Value: *code, Error: "redeemed"},
+		})
+	}
+
+	return &voucher, nil
+}
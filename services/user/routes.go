@@ -14,6 +14,7 @@ func (h *Handler) RegisterRoutes(router *http.ServeMux) {
 
 	router.HandleFunc("GET /users", h.handleGetUser)
 	router.HandleFunc("PATCH /users", h.handleUpdateUser)
+	router.HandleFunc("GET /users/tabs", h.handleGetUserTabs)
 
 }
 
@@ -61,3 +62,20 @@ func (h *Handler) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+func (h *Handler) handleGetUserTabs(w http.ResponseWriter, r *http.Request) {
+	session, err := h.sessions.GetSession(r)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	tabs, err := h.GetUserTabs(r.Context(), session)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tabs)
+}
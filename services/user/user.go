@@ -87,6 +87,39 @@ func (h *Handler) UpdateUser(ctx context.Context, session *sessions.Session, use
 	return nil
 }
 
+// GetUserTabs returns the tabs the session user owns, manages or may order on, hiding details only the owner and managers should see
+func (h *Handler) GetUserTabs(ctx context.Context, session *sessions.Session) ([]models.UserTab, error) {
+	userId, err := session.GetUserId()
+	if err != nil {
+		return nil, err
+	}
+
+	tabs, err := db.WithTxRet(ctx, h.store, func(q *db.PgxQueries) ([]models.UserTab, error) {
+		user, err := q.GetUser(ctx, userId)
+		if err != nil {
+			return nil, err
+		}
+
+		return q.GetUserTabs(ctx, userId, user.Email)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range tabs {
+		if !tabs[i].IsOwner && !tabs[i].IsManager {
+			tabs[i].VerificationList = nil
+			tabs[i].Managers = nil
+			tabs[i].PaymentDetails = ""
+			tabs[i].PendingUpdates = nil
+			if tabs[i].CurrentBill != nil {
+				tabs[i].CurrentBill.PaymentDetails = nil
+			}
+		}
+	}
+	return tabs, nil
+}
+
 func (h *Handler) authorizeModifyUser(session *sessions.Session, targetUserId string) error {
 	userId, err := session.GetUserId()
 	if err != nil {